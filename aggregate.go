@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+
+	. "github.com/dave/jennifer/jen"
+	"github.com/iancoleman/strcase"
+)
+
+// CreateAggregate generates a Golang file that contains the results of
+// aggregate queries, and the fields they can be grouped by
+func CreateAggregate(p *Package) error {
+	f := NewFile(p.Name)
+
+	f.PackageComment(fmt.Sprintf("%s contains all the library code for the platform", p.Name))
+	f.PackageComment("This file contains the results of aggregate queries")
+	f.PackageComment(" ** THIS CODE IS MACHINE GENERATED. DO NOT EDIT MANUALLY ** ")
+
+	for _, e := range p.Model.Entities {
+		if e.SupportsOperation("find") {
+			AddEntityAggregateStructs(e, f)
+			AddEntityGroupColumns(e, f)
+		}
+	}
+
+	return f.Save(p.Filename)
+}
+
+// GroupField describes a field that instances of an entity can be
+// grouped by, in aggregate queries
+type GroupField struct {
+	Name     string
+	Field    string
+	Column   string
+	DataType string
+	Type     *Statement
+
+	// Entity is the entity identified by the field, if it holds ids
+	Entity *Entity
+
+	// Enum tells whether the field holds values of an enum
+	Enum bool
+}
+
+// EntityGroupFields returns the fields that instances of the given
+// entity can be grouped by. These are all attributes, and relations
+// stored as a column
+func EntityGroupFields(e *Entity) []*GroupField {
+	fields := []*GroupField{}
+	for _, a := range e.Attributes {
+		gf := &GroupField{
+			Name:     AttributeSortField(a),
+			Field:    a.Name,
+			Column:   AttributeColumnName(a),
+			DataType: AttributeGraphqlFieldDataType(a),
+			Type:     TypeFromAttribute(a),
+			Enum:     a.Enum() != nil,
+		}
+
+		if a.Name == "ID" {
+			gf.Entity = e
+		}
+
+		fields = append(fields, gf)
+	}
+
+	for _, r := range e.Relations {
+		if r.HasModifier("belongsTo") {
+			fields = append(fields, &GroupField{
+				Name:     strcase.ToScreamingSnake(r.Alias()),
+				Field:    r.Alias(),
+				Column:   RelationColumnName(r),
+				DataType: "ID",
+				Type:     TypeFromRelation(r),
+				Entity:   r.target,
+			})
+		}
+	}
+
+	return fields
+}
+
+// AggregateAttributes returns the attributes of the given entity that
+// support numeric aggregates
+func AggregateAttributes(e *Entity) []*Attribute {
+	attributes := []*Attribute{}
+	for _, a := range e.Attributes {
+		if a.Type == "Int" || a.Type == "Float" {
+			attributes = append(attributes, a)
+		}
+	}
+
+	return attributes
+}
+
+// AggregateFunctions returns the numeric aggregate functions computed
+// by aggregate queries, along with their SQL representation
+func AggregateFunctions() [][]string {
+	return [][]string{
+		[]string{"Sum", "SUM"},
+		[]string{"Avg", "AVG"},
+		[]string{"Min", "MIN"},
+		[]string{"Max", "MAX"},
+	}
+}
+
+// AggregateExpressions returns the SQL expressions selected by the
+// aggregate query of the given entity, besides the group columns
+func AggregateExpressions(e *Entity) []string {
+	exprs := []string{"COUNT(*)"}
+	for _, fun := range AggregateFunctions() {
+		for _, a := range AggregateAttributes(e) {
+			exprs = append(exprs, fmt.Sprintf("%s(%s)", fun[1], AttributeColumnName(a)))
+		}
+	}
+
+	return exprs
+}
+
+// EntityAggregateName returns the name of the type that holds the
+// aggregates of a group of instances of the given entity
+func EntityAggregateName(e *Entity) string {
+	return fmt.Sprintf("%sAggregate", e.Name)
+}
+
+// EntityAggregateValuesName returns the name of the type that holds a
+// numeric aggregate for each attribute of the given entity
+func EntityAggregateValuesName(e *Entity) string {
+	return fmt.Sprintf("%sAggregateValues", e.Name)
+}
+
+// EntityGroupName returns the name of the type that holds the values
+// shared by a group of instances of the given entity
+func EntityGroupName(e *Entity) string {
+	return fmt.Sprintf("%sGroup", e.Name)
+}
+
+// EntityGroupFieldName returns the name of the Graphql enum of fields
+// instances of the given entity can be grouped by
+func EntityGroupFieldName(e *Entity) string {
+	return fmt.Sprintf("%sGroupField", e.Name)
+}
+
+// EntityGroupColumnsName returns the name of the generated map of group
+// fields to columns of the given entity
+func EntityGroupColumnsName(e *Entity) string {
+	return fmt.Sprintf("%sGroupColumns", e.Name)
+}
+
+// AddEntityAggregateStructs generates the structs that hold the
+// aggregates of a group of instances of the given entity. Values are
+// pointers, as they are nil for fields not grouped by, and for
+// aggregates over no rows
+func AddEntityAggregateStructs(e *Entity, f *File) {
+	group := EntityGroupName(e)
+	values := EntityAggregateValuesName(e)
+	aggregate := EntityAggregateName(e)
+
+	f.Comment(fmt.Sprintf("%s holds the values shared by a group of instances of type %s. Only the fields the group was built by are set", group, e.Name))
+	f.Type().Id(group).StructFunc(func(g *Group) {
+		for _, gf := range EntityGroupFields(e) {
+			g.Id(gf.Field).Op("*").Add(gf.Type)
+		}
+	})
+
+	f.Comment(fmt.Sprintf("%s holds a numeric aggregate of each numeric attribute of %s", values, e.Name))
+	f.Type().Id(values).StructFunc(func(g *Group) {
+		for _, a := range AggregateAttributes(e) {
+			g.Id(a.Name).Op("*").Float64()
+		}
+	})
+
+	f.Comment(fmt.Sprintf("%s holds the aggregates of a group of instances of type %s", aggregate, e.Name))
+	f.Type().Id(aggregate).StructFunc(func(g *Group) {
+		g.Id("Group").Op("*").Id(group)
+		g.Id("Count").Int()
+
+		for _, fun := range AggregateFunctions() {
+			g.Id(fun[0]).Op("*").Id(values)
+		}
+	})
+}
+
+// AddEntityGroupColumns generates the map of group fields to columns for
+// the given entity
+func AddEntityGroupColumns(e *Entity, f *File) {
+	name := EntityGroupColumnsName(e)
+
+	f.Comment(fmt.Sprintf("%s maps the fields instances of type %s can be grouped by, to their columns", name, e.Name))
+	f.Var().Id(name).Op("=").Map(String()).String().Values(DictFunc(func(d Dict) {
+		for _, gf := range EntityGroupFields(e) {
+			d[Lit(gf.Name)] = Lit(gf.Column)
+		}
+	}))
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"fmt"
+
+	. "github.com/dave/jennifer/jen"
+)
+
+// CreateErrors generates a Golang file that contains the errors
+// returned by the repositories and resolvers, classified by code, and
+// the functions that classify database errors
+func CreateErrors(p *Package) error {
+	f := NewFile(p.Name)
+
+	f.PackageComment(fmt.Sprintf("%s contains all the library code for the platform", p.Name))
+	f.PackageComment("This file contains the errors returned to clients, and their classification")
+	f.PackageComment(" ** THIS CODE IS MACHINE GENERATED. DO NOT EDIT MANUALLY ** ")
+
+	f.ImportAlias("database/sql", "sql")
+
+	AddErrorCodes(f)
+	AddErrorStruct(f)
+	AddNewErrorFun(f)
+	AddClientErrorFun(f)
+
+	for _, db := range Dialects() {
+		AddClassifyDialectErrorFun(db, f)
+	}
+
+	AddClassifyErrorFun(f)
+	AddGraphqlErrorFun(f)
+
+	for _, e := range p.Model.Entities {
+		if len(EntityRepositoryMethods(e)) > 0 {
+			AddEntityColumnFields(e, f)
+		}
+	}
+
+	return f.Save(p.Filename)
+}
+
+// ErrorCodes returns the codes errors are classified by, along with
+// the value sent to clients
+func ErrorCodes() [][]string {
+	return [][]string{
+		[]string{"CodeNotFound", "NOT_FOUND"},
+		[]string{"CodeUniqueViolation", "UNIQUE_VIOLATION"},
+		[]string{"CodeForeignKeyViolation", "FOREIGN_KEY_VIOLATION"},
+		[]string{"CodeValidationFailed", "VALIDATION_FAILED"},
+		[]string{"CodeHookRejected", "HOOK_REJECTED"},
+		[]string{"CodeInternal", "INTERNAL"},
+	}
+}
+
+// AddErrorCodes generates the type of the error codes, and a constant
+// for each of them
+func AddErrorCodes(f *File) {
+	f.Comment("ErrorCode classifies errors. It is sent to clients as the code extension of GraphQL errors")
+	f.Type().Id("ErrorCode").String()
+
+	f.Const().DefsFunc(func(g *Group) {
+		for _, code := range ErrorCodes() {
+			g.Id(code[0]).Id("ErrorCode").Op("=").Lit(code[1])
+		}
+	})
+}
+
+// AddErrorStruct generates the error type returned to clients, which
+// carries its code and the offending field
+func AddErrorStruct(f *File) {
+	f.Comment("Error is an error classified by code, along with the field that caused it, if known. Its message is safe to return to clients, and the underlying error, if any, is kept for logs")
+	f.Type().Id("Error").Struct(
+		Id("Code").Id("ErrorCode"),
+		Id("Field").String(),
+		Id("Message").String(),
+		Id("Err").Error(),
+	)
+
+	f.Comment("Error returns the message of the error")
+	f.Func().Parens(Id("e").Op("*").Id("Error")).Id("Error").Params().String().Block(
+		Return(Id("e").Dot("Message")),
+	)
+
+	f.Comment("Unwrap returns the underlying error, if any")
+	f.Func().Parens(Id("e").Op("*").Id("Error")).Id("Unwrap").Params().Error().Block(
+		Return(Id("e").Dot("Err")),
+	)
+
+	f.Comment("Extensions returns the extensions of the GraphQL error, which hold the code and the field of the error")
+	f.Func().Parens(Id("e").Op("*").Id("Error")).Id("Extensions").Params().Map(String()).Interface().Block(
+		Id("extensions").Op(":=").Map(String()).Interface().Values(Dict{
+			Lit("code"): Id("e").Dot("Code"),
+		}),
+		If(Id("e").Dot("Field").Op("!=").Lit("")).Block(
+			Id("extensions").Index(Lit("field")).Op("=").Id("e").Dot("Field"),
+		),
+		Return(Id("extensions")),
+	)
+}
+
+// AddNewErrorFun generates the function that builds an error of the
+// given code, with a message for clients
+func AddNewErrorFun(f *File) {
+	funName := "NewError"
+
+	f.Comment(fmt.Sprintf("%s returns an error with the given code, raised for the given entity and field, if known. The message is built from the code, rather than the underlying error", funName))
+	f.Func().Id(funName).Params(
+		Id("code").Id("ErrorCode"),
+		Id("entity").String(),
+		Id("field").String(),
+		Id("err").Error(),
+	).Op("*").Id("Error").BlockFunc(func(g *Group) {
+		g.Id("message").Op(":=").Lit("Internal error")
+		g.If(Err().Op("!=").Nil()).Block(
+			Id("message").Op("=").Err().Dot("Error").Call(),
+		)
+
+		g.Switch(Id("code")).Block(
+			Case(Id("CodeNotFound")).Block(
+				Id("message").Op("=").Qual("fmt", "Sprintf").Call(Lit("%s not found"), Id("entity")),
+			),
+			Case(Id("CodeUniqueViolation")).Block(
+				Id("message").Op("=").Qual("fmt", "Sprintf").Call(Lit("%s already exists"), Id("entity")),
+				If(Id("field").Op("!=").Lit("")).Block(
+					Id("message").Op("=").Qual("fmt", "Sprintf").Call(Lit("%s with the same %s already exists"), Id("entity"), Id("field")),
+				),
+			),
+			Case(Id("CodeForeignKeyViolation")).Block(
+				Id("message").Op("=").Qual("fmt", "Sprintf").Call(Lit("%s breaks a relation"), Id("entity")),
+			),
+			Case(Id("CodeValidationFailed")).Block(
+				Id("message").Op("=").Qual("fmt", "Sprintf").Call(Lit("%s is invalid"), Id("entity")),
+				If(Id("field").Op("!=").Lit("")).Block(
+					Id("message").Op("=").Qual("fmt", "Sprintf").Call(Lit("%s has an invalid %s"), Id("entity"), Id("field")),
+				),
+			),
+		)
+
+		g.Return(Op("&").Id("Error").Values(Dict{
+			Id("Code"):    Id("code"),
+			Id("Field"):   Id("field"),
+			Id("Message"): Id("message"),
+			Id("Err"):     Err(),
+		}))
+	})
+}
+
+// AddClientErrorFun generates the function that builds an error with a
+// message of the caller's choosing, for hooks to reject mutations with
+func AddClientErrorFun(f *File) {
+	funName := "ClientError"
+
+	f.Comment(fmt.Sprintf("%s returns an error with the given code, field, if any, and message, which is sent to clients as it is. Hooks return it to reject a mutation with a message of their own", funName))
+	f.Func().Id(funName).Params(
+		Id("code").Id("ErrorCode"),
+		Id("field").String(),
+		Id("message").String(),
+	).Op("*").Id("Error").Block(
+		Return(Op("&").Id("Error").Values(Dict{
+			Id("Code"):    Id("code"),
+			Id("Field"):   Id("field"),
+			Id("Message"): Id("message"),
+		})),
+	)
+}
+
+// ClassifyDialectErrorFunName returns the name of the function that
+// classifies the errors of the driver of the given dialect
+func ClassifyDialectErrorFunName(db string) string {
+	return fmt.Sprintf("Classify%sError", DialectName(db))
+}
+
+// AddClassifyDialectErrorFun generates the function that classifies
+// the constraint violations reported by the driver of the given
+// dialect, and extracts the offending column from them
+func AddClassifyDialectErrorFun(db string, f *File) {
+	funName := ClassifyDialectErrorFunName(db)
+
+	f.Comment(fmt.Sprintf("%s returns the code of the given error, if it is a constraint violation reported by the %s driver, along with the offending column, if known", funName, db))
+	f.Func().Id(funName).Params(
+		Err().Error(),
+	).Parens(List(
+		Id("ErrorCode"),
+		String(),
+		Bool(),
+	)).BlockFunc(func(g *Group) {
+		switch db {
+		case "postgres":
+			g.Var().Id("pqErr").Op("*").Qual("github.com/lib/pq", "Error")
+			g.If(Op("!").Qual("errors", "As").Call(Err(), Op("&").Id("pqErr"))).Block(
+				Return(Lit(""), Lit(""), False()),
+			)
+
+			// violations of keys name the columns in the detail, eg.
+			// Key (email)=(a@b.c) already exists
+			g.Id("column").Op(":=").Id("pqErr").Dot("Column")
+			g.If(Qual("strings", "HasPrefix").Call(Id("pqErr").Dot("Detail"), Lit("Key ("))).Block(
+				Id("key").Op(":=").Qual("strings", "TrimPrefix").Call(Id("pqErr").Dot("Detail"), Lit("Key (")),
+				If(Id("end").Op(":=").Qual("strings", "Index").Call(Id("key"), Lit(")")), Id("end").Op(">=").Lit(0)).Block(
+					Id("column").Op("=").Id("key").Index(Empty(), Id("end")),
+				),
+			)
+
+			g.Switch(Id("pqErr").Dot("Code").Dot("Name").Call()).Block(
+				Case(Lit("unique_violation")).Block(
+					Return(Id("CodeUniqueViolation"), Id("column"), True()),
+				),
+				Case(Lit("foreign_key_violation")).Block(
+					Return(Id("CodeForeignKeyViolation"), Id("column"), True()),
+				),
+				Case(Lit("not_null_violation"), Lit("check_violation"), Lit("string_data_right_truncation"), Lit("invalid_text_representation")).Block(
+					Return(Id("CodeValidationFailed"), Id("column"), True()),
+				),
+			)
+
+		default:
+			g.Var().Id("sqliteErr").Qual("github.com/mattn/go-sqlite3", "Error")
+			g.If(Op("!").Qual("errors", "As").Call(Err(), Op("&").Id("sqliteErr"))).Block(
+				Return(Lit(""), Lit(""), False()),
+			)
+
+			// violations name the columns after the table, eg.
+			// UNIQUE constraint failed: users.email
+			g.Id("column").Op(":=").Lit("")
+			g.If(Id("i").Op(":=").Qual("strings", "LastIndex").Call(Id("sqliteErr").Dot("Error").Call(), Lit(".")), Id("i").Op(">=").Lit(0)).Block(
+				Id("column").Op("=").Id("sqliteErr").Dot("Error").Call().Index(Id("i").Op("+").Lit(1), Empty()),
+			)
+
+			g.Switch(Id("sqliteErr").Dot("ExtendedCode")).Block(
+				Case(
+					Qual("github.com/mattn/go-sqlite3", "ErrConstraintUnique"),
+					Qual("github.com/mattn/go-sqlite3", "ErrConstraintPrimaryKey"),
+				).Block(
+					Return(Id("CodeUniqueViolation"), Id("column"), True()),
+				),
+				Case(Qual("github.com/mattn/go-sqlite3", "ErrConstraintForeignKey")).Block(
+					Return(Id("CodeForeignKeyViolation"), Lit(""), True()),
+				),
+				Case(
+					Qual("github.com/mattn/go-sqlite3", "ErrConstraintNotNull"),
+					Qual("github.com/mattn/go-sqlite3", "ErrConstraintCheck"),
+				).Block(
+					Return(Id("CodeValidationFailed"), Id("column"), True()),
+				),
+			)
+		}
+
+		g.Return(Lit(""), Lit(""), False())
+	})
+}
+
+// AddClassifyErrorFun generates the function that classifies the
+// errors returned by repository functions
+func AddClassifyErrorFun(f *File) {
+	funName := "ClassifyError"
+
+	f.Comment(fmt.Sprintf("%s classifies the given error, returned by a repository function of the given entity. Missing rows and constraint violations are returned as an *Error, whose field is looked up by column in the given map. Other errors are returned as they are", funName))
+	f.Func().Id(funName).Params(
+		Err().Error(),
+		Id("entity").String(),
+		Id("fields").Map(String()).String(),
+	).Error().BlockFunc(func(g *Group) {
+		g.Var().Id("classified").Op("*").Id("Error")
+		g.If(Err().Op("==").Nil().Op("||").Qual("errors", "As").Call(Err(), Op("&").Id("classified"))).Block(
+			Return(Err()),
+		)
+
+		g.If(Qual("errors", "Is").Call(Err(), Qual("database/sql", "ErrNoRows"))).Block(
+			Return(Id("NewError").Call(Id("CodeNotFound"), Id("entity"), Lit(""), Err())),
+		)
+
+		for _, db := range Dialects() {
+			g.If(
+				List(Id("code"), Id("column"), Id("ok")).Op(":=").Id(ClassifyDialectErrorFunName(db)).Call(Err()),
+				Id("ok"),
+			).Block(
+				Return(Id("NewError").Call(Id("code"), Id("entity"), Id("fields").Index(Id("column")), Err())),
+			)
+		}
+
+		g.Return(Err())
+	})
+}
+
+// AddGraphqlErrorFun generates the function that turns errors into
+// the ones returned by resolvers
+func AddGraphqlErrorFun(f *File) {
+	funName := "GraphqlError"
+
+	f.Comment(fmt.Sprintf("%s returns the given error as an *Error, so that its code and field are sent to clients as extensions. Errors that are not classified yet get the given code and message, and are logged, since their text may reveal internals", funName))
+	f.Func().Id(funName).Params(
+		Err().Error(),
+		Id("code").Id("ErrorCode"),
+		Id("msg").String(),
+	).Error().Block(
+		Var().Id("classified").Op("*").Id("Error"),
+		If(Qual("errors", "As").Call(Err(), Op("&").Id("classified"))).Block(
+			Return(Id("classified")),
+		),
+		Qual("log", "Printf").Call(Lit("%s: %v"), Id("msg"), Err()),
+		Return(Op("&").Id("Error").Values(Dict{
+			Id("Code"):    Id("code"),
+			Id("Message"): Id("msg"),
+			Id("Err"):     Err(),
+		})),
+	)
+}
+
+// EntityColumnFieldsName returns the name of the generated map of the
+// columns of the given entity to their Graphql fields
+func EntityColumnFieldsName(e *Entity) string {
+	return fmt.Sprintf("%sColumnFields", e.Name)
+}
+
+// AddEntityColumnFields generates the map of the columns of the given
+// entity to their Graphql fields, which tells clients the field that
+// caused an error reported on a column
+func AddEntityColumnFields(e *Entity, f *File) {
+	name := EntityColumnFieldsName(e)
+
+	f.Comment(fmt.Sprintf("%s maps the columns of %s to their GraphQL fields", name, e.Name))
+	f.Var().Id(name).Op("=").Map(String()).String().Values(DictFunc(func(d Dict) {
+		for _, a := range e.Attributes {
+			d[Lit(AttributeColumnName(a))] = Lit(AttributeGraphqlFieldName(a))
+		}
+
+		for _, r := range e.Relations {
+			if r.HasModifier("belongsTo") || r.HasModifier("hasOne") {
+				d[Lit(RelationColumnName(r))] = Lit(RelationGraphqlFieldName(r))
+			}
+		}
+	}))
+}
+
+// NotFoundError returns a statement that builds an error for an
+// instance of the given entity that no row matches, by the given id
+func NotFoundError(e *Entity, id Code) *Statement {
+	return Op("&").Id("Error").Values(Dict{
+		Id("Code"):    Id("CodeNotFound"),
+		Id("Message"): Qual("fmt", "Sprintf").Call(Lit(fmt.Sprintf("%s %%s not found", e.Name)), id),
+	})
+}
+
+// ValidationError returns a statement that builds an error for a
+// validation failure of the given field, if any, with the given
+// message
+func ValidationError(field string, message Code) *Statement {
+	return Op("&").Id("Error").Values(DictFunc(func(d Dict) {
+		d[Id("Code")] = Id("CodeValidationFailed")
+		if field != "" {
+			d[Id("Field")] = Lit(field)
+		}
+		d[Id("Message")] = message
+	}))
+}
@@ -0,0 +1,477 @@
+package main
+
+import (
+	"fmt"
+
+	. "github.com/dave/jennifer/jen"
+)
+
+// CreateFilter generates a Golang file that contains the filters that
+// can be applied to list queries, and the code that compiles them into
+// SQL WHERE clauses
+func CreateFilter(p *Package) error {
+	f := NewFile(p.Name)
+
+	f.PackageComment(fmt.Sprintf("%s contains all the library code for the platform", p.Name))
+	f.PackageComment("This file contains all the filters that can be applied to list queries")
+	f.PackageComment(" ** THIS CODE IS MACHINE GENERATED. DO NOT EDIT MANUALLY ** ")
+
+	AddSqlConditionFun(f)
+	AddSqlInConditionFun(f)
+	AddSqlContainsConditionFun(f)
+
+	for _, sf := range ScalarFilters() {
+		if sf.Name == sf.Filter {
+			AddScalarFilter(sf, f)
+		}
+	}
+
+	for _, e := range p.Model.Entities {
+		if e.SupportsOperation("find") {
+			AddEntityFilter(e, f)
+		}
+	}
+
+	return f.Save(p.Filename)
+}
+
+// ScalarFilter describes the filter for a Graphql scalar type. Scalars
+// that are stored the same way share the same Golang filter, eg. ID and
+// String. Enum is set for filters of the enums of the model
+type ScalarFilter struct {
+	Name     string
+	Filter   string
+	DataType string
+	Ordered  bool
+	Contains bool
+	Enum     bool
+}
+
+// ScalarFilters returns the filters for all supported Graphql scalar
+// types
+func ScalarFilters() []*ScalarFilter {
+	return []*ScalarFilter{
+		&ScalarFilter{Name: "IDFilter", Filter: "StringFilter", DataType: "ID"},
+		&ScalarFilter{Name: "StringFilter", Filter: "StringFilter", DataType: "String", Ordered: true, Contains: true},
+		&ScalarFilter{Name: "IntFilter", Filter: "IntFilter", DataType: "Int", Ordered: true},
+		&ScalarFilter{Name: "FloatFilter", Filter: "FloatFilter", DataType: "Float", Ordered: true},
+		&ScalarFilter{Name: "BooleanFilter", Filter: "BooleanFilter", DataType: "Boolean"},
+	}
+}
+
+// EnumFilters returns the filters for the enums among the given user
+// defined types
+func EnumFilters(types []*UDType) []*ScalarFilter {
+	filters := []*ScalarFilter{}
+	for _, t := range types {
+		if t.IsEnum() {
+			filters = append(filters, EnumFilter(t))
+		}
+	}
+
+	return filters
+}
+
+// EnumFilter returns the filter for attributes of the given enum.
+// Enums are stored as text, so they share the Golang filter of strings,
+// but clients compare them with values of the enum
+func EnumFilter(t *UDType) *ScalarFilter {
+	return &ScalarFilter{
+		Name:     fmt.Sprintf("%sFilter", t.Name),
+		Filter:   ScalarFilterForDataType("String").Filter,
+		DataType: t.Name,
+		Enum:     true,
+	}
+}
+
+// ScalarFilterForDataType returns the scalar filter to use for
+// attributes of the given Graphql data type. User defined types that
+// are not enums are filtered as strings
+func ScalarFilterForDataType(dataType string) *ScalarFilter {
+	for _, sf := range ScalarFilters() {
+		if sf.DataType == dataType {
+			return sf
+		}
+	}
+
+	return ScalarFilterForDataType("String")
+}
+
+// ScalarFilterDataType returns the Golang data type of the values
+// compared by the given scalar filter
+func ScalarFilterDataType(sf *ScalarFilter) *Statement {
+	return TypeFromDataType(AttributeDatatype(&Attribute{Type: sf.DataType}))
+}
+
+// ScalarFilterOperators returns the operators supported by the given
+// scalar filter, along with their SQL and Golang representations. The
+// "in", "contains" and "isNull" operators are handled separately
+func ScalarFilterOperators(sf *ScalarFilter) [][]string {
+	ops := [][]string{
+		[]string{"Eq", "=", "=="},
+		[]string{"Ne", "<>", "!="},
+	}
+
+	if sf.Ordered {
+		ops = append(ops, []string{"Lt", "<", "<"})
+		ops = append(ops, []string{"Gt", ">", ">"})
+	}
+
+	return ops
+}
+
+// AddScalarFilter generates the struct for the given scalar filter,
+// and the method that compiles it into SQL conditions
+func AddScalarFilter(sf *ScalarFilter, f *File) {
+	name := sf.Filter
+
+	f.Comment(fmt.Sprintf("%s holds the conditions on a single column of type %s. Conditions are combined with AND, and nil conditions are ignored", name, sf.DataType))
+	f.Type().Id(name).StructFunc(func(g *Group) {
+		for _, op := range ScalarFilterOperators(sf) {
+			g.Id(op[0]).Op("*").Add(ScalarFilterDataType(sf))
+		}
+
+		g.Id("In").Index().Add(ScalarFilterDataType(sf))
+
+		if sf.Contains {
+			g.Id("Contains").Op("*").String()
+		}
+
+		g.Id("IsNull").Op("*").Bool()
+	})
+
+	f.Comment("Where compiles the filter into SQL conditions on the given column. Values are appended to the given args, and bound as parameters")
+	f.Func().Parens(Id("f").Op("*").Id(name)).Id("Where").Params(
+		Id("column").String(),
+		Id("args").Op("*").Index().Interface(),
+	).Index().String().BlockFunc(func(g *Group) {
+		g.Id("conds").Op(":=").Index().String().Values()
+		g.If(Id("f").Op("==").Nil()).Block(
+			Return(Id("conds")),
+		)
+
+		for _, op := range ScalarFilterOperators(sf) {
+			g.If(Id("f").Dot(op[0]).Op("!=").Nil()).Block(
+				AppendCondition(Id("SqlCondition").Call(
+					Id("column"),
+					Lit(op[1]),
+					Op("*").Id("f").Dot(op[0]),
+					Id("args"),
+				)),
+			)
+		}
+
+		g.If(Id("f").Dot("In").Op("!=").Nil()).BlockFunc(func(g2 *Group) {
+			g2.Id("values").Op(":=").Index().Interface().Values()
+			g2.For(List(Id("_"), Id("v")).Op(":=").Range().Id("f").Dot("In")).Block(
+				Id("values").Op("=").Append(Id("values"), Id("v")),
+			)
+			g2.Add(AppendCondition(Id("SqlInCondition").Call(
+				Id("column"),
+				Id("values"),
+				Id("args"),
+			)))
+		})
+
+		if sf.Contains {
+			g.If(Id("f").Dot("Contains").Op("!=").Nil()).Block(
+				AppendCondition(Id("SqlContainsCondition").Call(
+					Id("column"),
+					Op("*").Id("f").Dot("Contains"),
+					Id("args"),
+				)),
+			)
+		}
+
+		g.If(Id("f").Dot("IsNull").Op("!=").Nil()).Block(
+			If(Op("*").Id("f").Dot("IsNull")).Block(
+				AppendCondition(Id("column").Op("+").Lit(" IS NULL")),
+			).Else().Block(
+				AppendCondition(Id("column").Op("+").Lit(" IS NOT NULL")),
+			),
+		)
+
+		g.Return(Id("conds"))
+	})
+
+	AddScalarFilterMatchFun(sf, f)
+}
+
+// AddScalarFilterMatchFun generates the method that evaluates the given
+// scalar filter against a value held in memory, rather than in a
+// column. Values held in memory are never null
+func AddScalarFilterMatchFun(sf *ScalarFilter, f *File) {
+	name := sf.Filter
+
+	f.Comment("Match tells whether the given value satisfies all the conditions of the filter. Values in memory are never null, so they only match IsNull when it is false")
+	f.Func().Parens(Id("f").Op("*").Id(name)).Id("Match").Params(
+		Id("value").Add(ScalarFilterDataType(sf)),
+	).Bool().BlockFunc(func(g *Group) {
+		g.If(Id("f").Op("==").Nil()).Block(
+			Return(True()),
+		)
+
+		for _, op := range ScalarFilterOperators(sf) {
+			g.If(Id("f").Dot(op[0]).Op("!=").Nil().Op("&&").Op("!").Parens(
+				Id("value").Op(op[2]).Op("*").Id("f").Dot(op[0]),
+			)).Block(
+				Return(False()),
+			)
+		}
+
+		g.If(Id("f").Dot("In").Op("!=").Nil()).BlockFunc(func(g2 *Group) {
+			g2.Id("found").Op(":=").False()
+			g2.For(List(Id("_"), Id("v")).Op(":=").Range().Id("f").Dot("In")).Block(
+				Id("found").Op("=").Id("found").Op("||").Id("v").Op("==").Id("value"),
+			)
+			g2.If(Op("!").Id("found")).Block(
+				Return(False()),
+			)
+		})
+
+		if sf.Contains {
+			g.If(Id("f").Dot("Contains").Op("!=").Nil().Op("&&").Op("!").Qual("strings", "Contains").Call(
+				Id("value"),
+				Op("*").Id("f").Dot("Contains"),
+			)).Block(
+				Return(False()),
+			)
+		}
+
+		g.Return(Id("f").Dot("IsNull").Op("==").Nil().Op("||").Op("!").Op("*").Id("f").Dot("IsNull"))
+	})
+}
+
+// AppendCondition returns the code that appends the given condition to
+// the conditions in scope
+func AppendCondition(cond *Statement) *Statement {
+	return Id("conds").Op("=").Append(Id("conds"), cond)
+}
+
+// EntityFilterName returns the name of the filter for the given entity
+func EntityFilterName(e *Entity) string {
+	return fmt.Sprintf("%sFilter", e.Name)
+}
+
+// AddEntityFilter generates the filter struct for the given entity,
+// with a scalar filter for each attribute, and for each relation
+// stored as a column. Filters can be nested using And and Or.
+func AddEntityFilter(e *Entity, f *File) {
+	name := EntityFilterName(e)
+
+	f.Comment(fmt.Sprintf("%s holds conditions on entities of type %s. Conditions on fields, and filters in And, are combined with AND. At least one of the filters in Or must match", name, e.Name))
+	f.Type().Id(name).StructFunc(func(g *Group) {
+		g.Id("And").Index().Op("*").Id(name)
+		g.Id("Or").Index().Op("*").Id(name)
+
+		for _, a := range e.Attributes {
+			g.Id(a.Name).Op("*").Id(AttributeScalarFilter(a).Filter)
+		}
+
+		for _, r := range e.Relations {
+			if r.HasModifier("belongsTo") || r.HasModifier("hasOne") {
+				g.Id(r.Alias()).Op("*").Id(ScalarFilterForDataType("ID").Filter)
+			}
+		}
+	})
+
+	f.Comment("Where compiles the filter into a SQL condition. Values are appended to the given args, and bound as parameters. An empty string is returned when there are no conditions")
+	f.Func().Parens(Id("f").Op("*").Id(name)).Id("Where").Params(
+		Id("args").Op("*").Index().Interface(),
+	).String().BlockFunc(func(g *Group) {
+		g.If(Id("f").Op("==").Nil()).Block(
+			Return(Lit("")),
+		)
+
+		g.Id("conds").Op(":=").Index().String().Values()
+
+		for _, a := range e.Attributes {
+			g.Id("conds").Op("=").Append(
+				Id("conds"),
+				Id("f").Dot(a.Name).Dot("Where").Call(Lit(AttributeColumnName(a)), Id("args")).Op("..."),
+			)
+		}
+
+		for _, r := range e.Relations {
+			if r.HasModifier("belongsTo") || r.HasModifier("hasOne") {
+				g.Id("conds").Op("=").Append(
+					Id("conds"),
+					Id("f").Dot(r.Alias()).Dot("Where").Call(Lit(RelationColumnName(r)), Id("args")).Op("..."),
+				)
+			}
+		}
+
+		g.For(List(Id("_"), Id("and")).Op(":=").Range().Id("f").Dot("And")).Block(
+			If(Id("cond").Op(":=").Id("and").Dot("Where").Call(Id("args")), Id("cond").Op("!=").Lit("")).Block(
+				AppendCondition(Id("cond")),
+			),
+		)
+
+		// an empty filter in Or matches all rows
+		g.If(Len(Id("f").Dot("Or")).Op(">").Lit(0)).BlockFunc(func(g2 *Group) {
+			g2.Id("ors").Op(":=").Index().String().Values()
+			g2.For(List(Id("_"), Id("or")).Op(":=").Range().Id("f").Dot("Or")).BlockFunc(func(g3 *Group) {
+				g3.Id("cond").Op(":=").Id("or").Dot("Where").Call(Id("args"))
+				g3.If(Id("cond").Op("==").Lit("")).Block(
+					Id("cond").Op("=").Lit("1=1"),
+				)
+				g3.Id("ors").Op("=").Append(Id("ors"), Id("cond"))
+			})
+			g2.Add(AppendCondition(
+				Lit("(").Op("+").Qual("strings", "Join").Call(Id("ors"), Lit(" OR ")).Op("+").Lit(")"),
+			))
+		})
+
+		g.If(Len(Id("conds")).Op("==").Lit(0)).Block(
+			Return(Lit("")),
+		)
+
+		g.Return(Lit("(").Op("+").Qual("strings", "Join").Call(Id("conds"), Lit(" AND ")).Op("+").Lit(")"))
+	})
+
+	AddEntityFilterMatchFun(e, f)
+}
+
+// AddEntityFilterMatchFun generates the method that evaluates the
+// filter of the given entity against an instance held in memory, with
+// the same semantics as the SQL condition it compiles into
+func AddEntityFilterMatchFun(e *Entity, f *File) {
+	name := EntityFilterName(e)
+
+	f.Comment(fmt.Sprintf("Match tells whether the given %s satisfies the filter. A nil filter matches every instance", e.Name))
+	f.Func().Parens(Id("f").Op("*").Id(name)).Id("Match").Params(
+		Id(e.VarName()).Op("*").Id(e.Name),
+	).Bool().BlockFunc(func(g *Group) {
+		g.If(Id("f").Op("==").Nil()).Block(
+			Return(True()),
+		)
+
+		// enums are filtered as strings
+		for _, a := range e.Attributes {
+			value := Id(e.VarName()).Dot(a.Name)
+			if a.Enum() != nil {
+				value = String().Call(value)
+			}
+
+			g.If(Op("!").Id("f").Dot(a.Name).Dot("Match").Call(value)).Block(
+				Return(False()),
+			)
+		}
+
+		for _, r := range e.Relations {
+			if r.HasModifier("belongsTo") || r.HasModifier("hasOne") {
+				g.If(Op("!").Id("f").Dot(r.Alias()).Dot("Match").Call(Id(e.VarName()).Dot(r.Alias()).Dot("ID"))).Block(
+					Return(False()),
+				)
+			}
+		}
+
+		g.For(List(Id("_"), Id("and")).Op(":=").Range().Id("f").Dot("And")).Block(
+			If(Op("!").Id("and").Dot("Match").Call(Id(e.VarName()))).Block(
+				Return(False()),
+			),
+		)
+
+		g.If(Len(Id("f").Dot("Or")).Op("==").Lit(0)).Block(
+			Return(True()),
+		)
+
+		g.For(List(Id("_"), Id("or")).Op(":=").Range().Id("f").Dot("Or")).Block(
+			If(Id("or").Dot("Match").Call(Id(e.VarName()))).Block(
+				Return(True()),
+			),
+		)
+
+		g.Return(False())
+	})
+}
+
+// AttributeScalarFilter returns the scalar filter for the given
+// attribute
+func AttributeScalarFilter(a *Attribute) *ScalarFilter {
+	if a.Enum() != nil {
+		return EnumFilter(a.Enum())
+	}
+
+	return ScalarFilterForDataType(AttributeGraphqlFieldDataType(a))
+}
+
+// AddSqlConditionFun generates a function that builds a SQL condition
+// that compares a column with a value, bound as a parameter
+func AddSqlConditionFun(f *File) {
+	funName := "SqlCondition"
+
+	f.Comment(fmt.Sprintf("%s returns a condition that compares the given column with the given value, using the given operator. The value is appended to args", funName))
+	f.Func().Id(funName).Params(
+		Id("column").String(),
+		Id("op").String(),
+		Id("value").Interface(),
+		Id("args").Op("*").Index().Interface(),
+	).String().Block(
+		Op("*").Id("args").Op("=").Append(Op("*").Id("args"), Id("value")),
+		Return(Qual("fmt", "Sprintf").Call(
+			Lit("%s %s $%v"),
+			Id("column"),
+			Id("op"),
+			Len(Op("*").Id("args")),
+		)),
+	)
+}
+
+// AddSqlInConditionFun generates a function that builds a SQL
+// condition that checks a column against a list of values
+func AddSqlInConditionFun(f *File) {
+	funName := "SqlInCondition"
+
+	f.Comment(fmt.Sprintf("%s returns a condition that checks the given column is one of the given values. The values are appended to args. An empty list of values matches no rows", funName))
+	f.Func().Id(funName).Params(
+		Id("column").String(),
+		Id("values").Index().Interface(),
+		Id("args").Op("*").Index().Interface(),
+	).String().BlockFunc(func(g *Group) {
+		g.If(Len(Id("values")).Op("==").Lit(0)).Block(
+			Return(Lit("1=0")),
+		)
+
+		g.Id("placeholders").Op(":=").Index().String().Values()
+		g.For(List(Id("_"), Id("v")).Op(":=").Range().Id("values")).Block(
+			Op("*").Id("args").Op("=").Append(Op("*").Id("args"), Id("v")),
+			Id("placeholders").Op("=").Append(
+				Id("placeholders"),
+				Qual("fmt", "Sprintf").Call(Lit("$%v"), Len(Op("*").Id("args"))),
+			),
+		)
+
+		g.Return(Qual("fmt", "Sprintf").Call(
+			Lit("%s IN (%s)"),
+			Id("column"),
+			Qual("strings", "Join").Call(Id("placeholders"), Lit(",")),
+		))
+	})
+}
+
+// AddSqlContainsConditionFun generates a function that builds a SQL
+// condition that checks a column contains a substring. LIKE wildcards
+// in the substring are escaped
+func AddSqlContainsConditionFun(f *File) {
+	funName := "SqlContainsCondition"
+
+	f.Comment(fmt.Sprintf("%s returns a condition that checks the given column contains the given substring. The pattern is appended to args", funName))
+	f.Func().Id(funName).Params(
+		Id("column").String(),
+		Id("value").String(),
+		Id("args").Op("*").Index().Interface(),
+	).String().Block(
+		Id("escaped").Op(":=").Qual("strings", "NewReplacer").Call(
+			Lit(`\`), Lit(`\\`),
+			Lit("%"), Lit(`\%`),
+			Lit("_"), Lit(`\_`),
+		).Dot("Replace").Call(Id("value")),
+		Op("*").Id("args").Op("=").Append(Op("*").Id("args"), Lit("%").Op("+").Id("escaped").Op("+").Lit("%")),
+		Return(Qual("fmt", "Sprintf").Call(
+			Lit(`%s LIKE $%v ESCAPE '\'`),
+			Id("column"),
+			Len(Op("*").Id("args")),
+		)),
+	)
+}
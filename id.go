@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+
+	. "github.com/dave/jennifer/jen"
+)
+
+// CreateIDs generates a Golang file that contains the functions that
+// assign IDs to new instances, for every entity whose IDs are
+// generated by the server rather than by the database
+func CreateIDs(p *Package) error {
+	f := NewFile(p.Name)
+
+	f.PackageComment(fmt.Sprintf("%s contains all the library code for the platform", p.Name))
+	f.PackageComment("This file contains the functions that generate IDs")
+	f.PackageComment(" ** THIS CODE IS MACHINE GENERATED. DO NOT EDIT MANUALLY ** ")
+
+	f.ImportName("github.com/oklog/ulid/v2", "ulid")
+
+	for _, e := range p.Model.Entities {
+		if e.GeneratesID() && !e.GeneratesIDInDatabase() {
+			AddNewIDFun(e, f)
+		}
+	}
+
+	return f.Save(p.Filename)
+}
+
+// NewIDFunName returns the name of the function that generates the ID
+// of a new instance of the given entity
+func NewIDFunName(e *Entity) string {
+	return fmt.Sprintf("New%sID", e.Name)
+}
+
+// AddNewIDFun generates the function that returns a new ID for the
+// given entity, according to its ID strategy
+func AddNewIDFun(e *Entity, f *File) {
+	funName := NewIDFunName(e)
+
+	f.Comment(fmt.Sprintf("%s returns a new %s ID for an instance of type %s", funName, e.IDStrategy, e.Name))
+	f.Func().Id(funName).Params().String().Block(
+		Return(NewIDValue(e)),
+	)
+}
+
+// NewIDValue returns the code that generates a new ID, as a string,
+// according to the ID strategy of the given entity
+func NewIDValue(e *Entity) *Statement {
+	switch e.IDStrategy {
+	case "uuidv4":
+		return Qual("github.com/google/uuid", "NewString").Call()
+
+	case "uuidv7":
+		return Qual("github.com/google/uuid", "Must").Call(
+			Qual("github.com/google/uuid", "NewV7").Call(),
+		).Dot("String").Call()
+
+	case "ulid":
+		return Qual("github.com/oklog/ulid/v2", "Make").Call().Dot("String").Call()
+
+	case "ksuid":
+		return Qual("github.com/segmentio/ksuid", "New").Call().Dot("String").Call()
+
+	default:
+		panic(fmt.Sprintf("IDs of %s are not generated by the server", e.Name))
+	}
+}
+
+// ClientSuppliesID returns whether clients supply the ID of the given
+// entity to the given mutation. IDs generated by the server are only
+// taken by mutations that change existing instances, and by upserts
+// keyed by the ID
+func ClientSuppliesID(e *Entity, mutation string) bool {
+	if !e.GeneratesID() {
+		return true
+	}
+
+	switch mutation {
+	case "create":
+		return false
+
+	case "upsert":
+		return e.UpsertAttribute().Name == "ID"
+
+	default:
+		return true
+	}
+}
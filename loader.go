@@ -0,0 +1,459 @@
+package main
+
+import (
+	"fmt"
+
+	. "github.com/dave/jennifer/jen"
+)
+
+// CreateLoader generates a Golang file that contains the loaders, which
+// batch the lookups of related entities issued while resolving a single
+// request, so that they are fetched with a single query
+func CreateLoader(p *Package) error {
+	f := NewFile(p.Name)
+
+	f.PackageComment(fmt.Sprintf("%s contains all the library code for the platform", p.Name))
+	f.PackageComment("This file contains the loaders that batch lookups of related entities")
+	f.PackageComment(" ** THIS CODE IS MACHINE GENERATED. DO NOT EDIT MANUALLY ** ")
+
+	f.ImportAlias("database/sql", "sql")
+
+	AddLoaderConsts(f)
+	AddLoaderStructs(f)
+	AddNewLoaderFun(f)
+	AddLoadFun(f)
+	AddLoaderDispatchFun(f)
+	AddLoadersStruct(f)
+	AddWithLoadersFun(f)
+	AddLoaderForFun(f)
+	AddClearLoadersFun(f)
+	AddLoaderKeyFun(f)
+	AddLoadersHandlerFun(f)
+
+	for _, e := range p.Model.Entities {
+		if e.SupportsOperation("find") {
+			AddLoadByIDFun(e, f)
+		}
+
+		for _, r := range e.Relations {
+			if r.HasModifier("hasMany") {
+				child := p.Model.EntityForNameOrPanic(r.Entity)
+				inverse := child.RelationForEntityOrPanic(e)
+
+				if child.SupportsOperation("find") {
+					AddLoadByRelationFun(child, inverse, f)
+				}
+			}
+		}
+	}
+
+	return f.Save(p.Filename)
+}
+
+// AddLoaderConsts declares how long loaders wait for more keys before
+// fetching a batch, and how many keys a batch holds at most
+func AddLoaderConsts(f *File) {
+	f.Comment("LoaderWait is how long a loader waits for more keys, before fetching a batch")
+	f.Const().Id("LoaderWait").Op("=").Lit(2).Op("*").Qual("time", "Millisecond")
+
+	f.Comment("LoaderMaxBatch is the maximum number of keys fetched in a single batch")
+	f.Const().Id("LoaderMaxBatch").Op("=").Lit(100)
+}
+
+// AddLoaderStructs generates the loader, its fetch function, and the
+// batches of keys it fetches together
+func AddLoaderStructs(f *File) {
+	f.Comment("LoaderFetchFun fetches the values of a batch of keys. Keys missing from the results have no value")
+	f.Type().Id("LoaderFetchFun").Func().Params(
+		Id("ctx").Qual("context", "Context"),
+		Id("keys").Index().String(),
+	).Parens(List(
+		Map(String()).Interface(),
+		Error(),
+	))
+
+	f.Comment("Loader batches the lookups of keys issued within a short time window, and fetches them together. Results are cached, so every key is fetched once in the lifetime of the loader")
+	f.Type().Id("Loader").Struct(
+		Id("fetch").Id("LoaderFetchFun"),
+		Id("mu").Qual("sync", "Mutex"),
+		Id("batch").Op("*").Id("LoaderBatch"),
+		Id("cache").Map(String()).Op("*").Id("LoaderBatch"),
+	)
+
+	f.Comment("LoaderBatch holds a batch of keys, and their results once fetched")
+	f.Type().Id("LoaderBatch").Struct(
+		Id("keys").Index().String(),
+		Id("done").Chan().Struct(),
+		Id("results").Map(String()).Interface(),
+		Id("err").Error(),
+	)
+}
+
+// AddNewLoaderFun generates the constructor of loaders
+func AddNewLoaderFun(f *File) {
+	funName := "NewLoader"
+
+	f.Comment(fmt.Sprintf("%s returns a loader that fetches batches of keys with the given function", funName))
+	f.Func().Id(funName).Params(
+		Id("fetch").Id("LoaderFetchFun"),
+	).Op("*").Id("Loader").Block(
+		Return(Op("&").Id("Loader").Values(Dict{
+			Id("fetch"): Id("fetch"),
+			Id("cache"): Map(String()).Op("*").Id("LoaderBatch").Values(),
+		})),
+	)
+}
+
+// AddLoadFun generates the function that adds a key to the current
+// batch of a loader, and waits for its value
+func AddLoadFun(f *File) {
+	f.Comment("Load returns the value of the given key. The key is added to the current batch, which is fetched once it is full, or after the loader waited for more keys. A nil value is returned for keys that were not found")
+	f.Func().Parens(Id("l").Op("*").Id("Loader")).Id("Load").Params(
+		Id("ctx").Qual("context", "Context"),
+		Id("key").String(),
+	).Parens(List(
+		Interface(),
+		Error(),
+	)).BlockFunc(func(g *Group) {
+		g.Id("l").Dot("mu").Dot("Lock").Call()
+		g.List(Id("batch"), Id("ok")).Op(":=").Id("l").Dot("cache").Index(Id("key"))
+		g.If(Op("!").Id("ok")).BlockFunc(func(g2 *Group) {
+			g2.If(Id("l").Dot("batch").Op("==").Nil()).Block(
+				Id("l").Dot("batch").Op("=").Op("&").Id("LoaderBatch").Values(Dict{
+					Id("done"): Make(Chan().Struct()),
+				}),
+				Id("pending").Op(":=").Id("l").Dot("batch"),
+				Qual("time", "AfterFunc").Call(
+					Id("LoaderWait"),
+					Func().Params().Block(
+						Id("l").Dot("dispatch").Call(Id("ctx"), Id("pending")),
+					),
+				),
+			)
+
+			g2.Id("batch").Op("=").Id("l").Dot("batch")
+			g2.Id("batch").Dot("keys").Op("=").Append(Id("batch").Dot("keys"), Id("key"))
+			g2.Id("l").Dot("cache").Index(Id("key")).Op("=").Id("batch")
+
+			g2.If(Len(Id("batch").Dot("keys")).Op(">=").Id("LoaderMaxBatch")).Block(
+				Go().Id("l").Dot("dispatch").Call(Id("ctx"), Id("batch")),
+			)
+		})
+		g.Id("l").Dot("mu").Dot("Unlock").Call()
+
+		g.Select().Block(
+			Case(Op("<-").Id("batch").Dot("done")).Block(
+				Return(Id("batch").Dot("results").Index(Id("key")), Id("batch").Dot("err")),
+			),
+			Case(Op("<-").Id("ctx").Dot("Done").Call()).Block(
+				Return(Nil(), Id("ctx").Dot("Err").Call()),
+			),
+		)
+	})
+}
+
+// AddLoaderDispatchFun generates the function that fetches a batch of
+// keys. A batch is dispatched either when full or when the wait is
+// over, whichever happens first, so the second call does nothing
+func AddLoaderDispatchFun(f *File) {
+	f.Comment("dispatch fetches the keys of the given batch, unless it was already dispatched")
+	f.Func().Parens(Id("l").Op("*").Id("Loader")).Id("dispatch").Params(
+		Id("ctx").Qual("context", "Context"),
+		Id("batch").Op("*").Id("LoaderBatch"),
+	).BlockFunc(func(g *Group) {
+		g.Id("l").Dot("mu").Dot("Lock").Call()
+		g.If(Id("l").Dot("batch").Op("!=").Id("batch")).Block(
+			Id("l").Dot("mu").Dot("Unlock").Call(),
+			Return(),
+		)
+		g.Id("l").Dot("batch").Op("=").Nil()
+		g.Id("l").Dot("mu").Dot("Unlock").Call()
+
+		g.List(
+			Id("batch").Dot("results"),
+			Id("batch").Dot("err"),
+		).Op("=").Id("l").Dot("fetch").Call(Id("ctx"), Id("batch").Dot("keys"))
+		g.Close(Id("batch").Dot("done"))
+	})
+}
+
+// AddLoadersStruct generates the struct that holds the loaders of a
+// single request, and its constructor
+func AddLoadersStruct(f *File) {
+	f.Comment("Loaders holds the loaders of a single request, by name")
+	f.Type().Id("Loaders").Struct(
+		Id("mu").Qual("sync", "Mutex"),
+		Id("loaders").Map(String()).Op("*").Id("Loader"),
+	)
+
+	f.Comment("NewLoaders returns an empty set of loaders")
+	f.Func().Id("NewLoaders").Params().Op("*").Id("Loaders").Block(
+		Return(Op("&").Id("Loaders").Values(Dict{
+			Id("loaders"): Map(String()).Op("*").Id("Loader").Values(),
+		})),
+	)
+
+	f.Comment("LoadersContextKey is the key of the loaders in the context of a request")
+	f.Type().Id("LoadersContextKey").Struct()
+}
+
+// AddWithLoadersFun generates the function that stores the loaders of
+// a request in its context
+func AddWithLoadersFun(f *File) {
+	funName := "WithLoaders"
+
+	f.Comment(fmt.Sprintf("%s returns a copy of the given context that holds the given loaders", funName))
+	f.Func().Id(funName).Params(
+		Id("ctx").Qual("context", "Context"),
+		Id("loaders").Op("*").Id("Loaders"),
+	).Qual("context", "Context").Block(
+		Return(Qual("context", "WithValue").Call(
+			Id("ctx"),
+			Id("LoadersContextKey").Values(),
+			Id("loaders"),
+		)),
+	)
+}
+
+// AddLoaderForFun generates the function that returns a loader of the
+// request in scope, by name
+func AddLoaderForFun(f *File) {
+	funName := "LoaderFor"
+
+	f.Comment(fmt.Sprintf("%s returns the loader with the given name, from the loaders held by the given context. The loader is created with the given fetch function, the first time it is requested. Without loaders in the context, a new loader is returned on every call, so lookups are not batched", funName))
+	f.Func().Id(funName).Params(
+		Id("ctx").Qual("context", "Context"),
+		Id("name").String(),
+		Id("fetch").Id("LoaderFetchFun"),
+	).Op("*").Id("Loader").BlockFunc(func(g *Group) {
+		g.List(Id("loaders"), Id("ok")).Op(":=").Id("ctx").Dot("Value").Call(
+			Id("LoadersContextKey").Values(),
+		).Assert(Op("*").Id("Loaders"))
+		g.If(Op("!").Id("ok")).Block(
+			Return(Id("NewLoader").Call(Id("fetch"))),
+		)
+
+		g.Id("loaders").Dot("mu").Dot("Lock").Call()
+		g.Defer().Id("loaders").Dot("mu").Dot("Unlock").Call()
+
+		g.List(Id("loader"), Id("ok")).Op(":=").Id("loaders").Dot("loaders").Index(Id("name"))
+		g.If(Op("!").Id("ok")).Block(
+			Id("loader").Op("=").Id("NewLoader").Call(Id("fetch")),
+			Id("loaders").Dot("loaders").Index(Id("name")).Op("=").Id("loader"),
+		)
+
+		g.Return(Id("loader"))
+	})
+}
+
+// AddClearLoadersFun generates the function that drops the loaders of
+// the request in scope, along with the values they cached, so that
+// lookups made after a write see it
+func AddClearLoadersFun(f *File) {
+	funName := "ClearLoaders"
+
+	f.Comment(fmt.Sprintf("%s drops the loaders held by the given context, and the values they cached. Mutations call it once committed, so that the lookups that follow see their changes", funName))
+	f.Func().Id(funName).Params(
+		Id("ctx").Qual("context", "Context"),
+	).BlockFunc(func(g *Group) {
+		g.List(Id("loaders"), Id("ok")).Op(":=").Id("ctx").Dot("Value").Call(
+			Id("LoadersContextKey").Values(),
+		).Assert(Op("*").Id("Loaders"))
+		g.If(Op("!").Id("ok")).Block(
+			Return(),
+		)
+
+		g.Id("loaders").Dot("mu").Dot("Lock").Call()
+		g.Id("loaders").Dot("loaders").Op("=").Map(String()).Op("*").Id("Loader").Values()
+		g.Id("loaders").Dot("mu").Dot("Unlock").Call()
+	})
+}
+
+// AddLoaderKeyFun generates the function that names a loader after
+// the arguments of its lookups, so that only lookups with the same
+// arguments are batched together
+func AddLoaderKeyFun(f *File) {
+	funName := "LoaderKey"
+
+	f.Comment(fmt.Sprintf("%s returns the name of the loader for lookups of the given kind, with the given arguments", funName))
+	f.Func().Id(funName).Params(
+		Id("kind").String(),
+		Id("args").Op("...").Interface(),
+	).String().Block(
+		Comment("arguments are plain values, which can always be encoded"),
+		List(Id("key"), Id("_")).Op(":=").Qual("encoding/json", "Marshal").Call(Id("args")),
+		Return(Id("kind").Op("+").String().Call(Id("key"))),
+	)
+}
+
+// AddLoadersHandlerFun generates the http middleware that installs a
+// new set of loaders in the context of every request
+func AddLoadersHandlerFun(f *File) {
+	funName := "LoadersHandler"
+
+	f.Comment(fmt.Sprintf("%s wraps the given handler, so that every request is served with its own set of loaders", funName))
+	f.Func().Id(funName).Params(
+		Id("h").Qual("net/http", "Handler"),
+	).Qual("net/http", "Handler").Block(
+		Return(Qual("net/http", "HandlerFunc").Call(
+			Func().Params(
+				Id("w").Qual("net/http", "ResponseWriter"),
+				Id("r").Op("*").Qual("net/http", "Request"),
+			).Block(
+				Id("h").Dot("ServeHTTP").Call(
+					Id("w"),
+					Id("r").Dot("WithContext").Call(
+						Id("WithLoaders").Call(
+							Id("r").Dot("Context").Call(),
+							Id("NewLoaders").Call(),
+						),
+					),
+				),
+			),
+		)),
+	)
+}
+
+// LoadByIDFunName returns the name of the function that loads an
+// instance of the given entity by its id
+func LoadByIDFunName(e *Entity) string {
+	return fmt.Sprintf("Load%sByID", e.Name)
+}
+
+// LoadByRelationFunName returns the name of the function that loads
+// the instances of the given entity by the given relation
+func LoadByRelationFunName(e *Entity, r *Relation) string {
+	return fmt.Sprintf("Load%sBy%s", e.PluralName(), r.Alias())
+}
+
+// AddLoadByIDFun produces the function that loads an instance of the
+// given entity by id, batched with other lookups of the same request
+func AddLoadByIDFun(e *Entity, f *File) {
+	funName := LoadByIDFunName(e)
+	plural := VarName(e.PluralName())
+
+	f.Comment(fmt.Sprintf("%s finds an instance of type %s by id, batched with other lookups of the same request. If no row matches, then this function returns sql.ErrNoRows", funName, e.Name))
+	f.Func().Id(funName).Params(
+		Id("ctx").Qual("context", "Context"),
+		Id("repo").Id("Repository"),
+		Id("id").String(),
+	).Parens(List(
+		Op("*").Id(e.Name),
+		Error(),
+	)).BlockFunc(func(g *Group) {
+		g.Id("loader").Op(":=").Id("LoaderFor").Call(
+			Id("ctx"),
+			Lit(fmt.Sprintf("%sByID", e.Name)),
+			LoaderFetchFunc(func(g2 *Group) {
+				g2.List(Id(plural), Err()).Op(":=").Id("repo").Dot(FindEntitiesByIDsFunName(e)).Call(
+					Id("ctx"),
+					Id("keys"),
+				)
+				g2.If(Err().Op("!=").Nil()).Block(
+					Return(Id("results"), Err()),
+				)
+
+				g2.For(List(Id("_"), Id(e.VarName())).Op(":=").Range().Id(plural)).Block(
+					Id("results").Index(Id(e.VarName()).Dot("ID")).Op("=").Id(e.VarName()),
+				)
+			}),
+		)
+
+		g.List(Id("result"), Err()).Op(":=").Id("loader").Dot("Load").Call(Id("ctx"), Id("id"))
+		g.If(Err().Op("!=").Nil()).Block(
+			Return(Nil(), Err()),
+		)
+
+		g.List(Id(e.VarName()), Id("ok")).Op(":=").Id("result").Assert(Op("*").Id(e.Name))
+		g.If(Op("!").Id("ok")).Block(
+			Return(Nil(), Id("NewError").Call(Id("CodeNotFound"), Lit(e.Name), Lit(""), Qual("database/sql", "ErrNoRows"))),
+		)
+
+		g.Return(Id(e.VarName()), Nil())
+	})
+}
+
+// AddLoadByRelationFun produces the function that loads the instances
+// of the given entity that point to a parent through the given
+// relation, batched with the lookups of other parents of the same
+// request
+func AddLoadByRelationFun(e *Entity, r *Relation, f *File) {
+	funName := LoadByRelationFunName(e, r)
+	plural := VarName(e.PluralName())
+
+	f.Comment(fmt.Sprintf("%s finds a list of instances of type %s by %s, batched with the lookups of other %s ids of the same request that share the same filter, order and page. If no rows match, then this function returns an empty slice", funName, e.Name, r.Alias(), r.Alias()))
+	f.Func().Id(funName).Params(
+		Id("ctx").Qual("context", "Context"),
+		Id("repo").Id("Repository"),
+		Id(r.VarName()).String(),
+		Id("filter").Op("*").Id(EntityFilterName(e)),
+		Id("orderBy").Index().Op("*").Id("SortOrder"),
+		Id("limit").Int32(),
+		Id("offset").Int32(),
+	).Parens(List(
+		Index().Op("*").Id(e.Name),
+		Error(),
+	)).BlockFunc(func(g *Group) {
+		g.Id("loader").Op(":=").Id("LoaderFor").Call(
+			Id("ctx"),
+			Id("LoaderKey").Call(
+				Lit(fmt.Sprintf("%sBy%s", e.PluralName(), r.Alias())),
+				Id("filter"),
+				Id("orderBy"),
+				Id("limit"),
+				Id("offset"),
+			),
+			LoaderFetchFunc(func(g2 *Group) {
+				g2.List(Id(plural), Err()).Op(":=").Id("repo").Dot(FindEntitiesByRelationIDsFunName(e, r)).Call(
+					Id("ctx"),
+					Id("keys"),
+					Id("filter"),
+					Id("orderBy"),
+					Id("limit"),
+					Id("offset"),
+				)
+				g2.If(Err().Op("!=").Nil()).Block(
+					Return(Id("results"), Err()),
+				)
+
+				key := Id(e.VarName()).Dot(r.Alias()).Dot("ID")
+				g2.For(List(Id("_"), Id(e.VarName())).Op(":=").Range().Id(plural)).Block(
+					List(Id("list"), Id("_")).Op(":=").Id("results").Index(key).Assert(Index().Op("*").Id(e.Name)),
+					Id("results").Index(key).Op("=").Append(Id("list"), Id(e.VarName())),
+				)
+			}),
+		)
+
+		g.Id(plural).Op(":=").Index().Op("*").Id(e.Name).Values()
+		g.List(Id("result"), Err()).Op(":=").Id("loader").Dot("Load").Call(Id("ctx"), Id(r.VarName()))
+		g.If(Err().Op("!=").Nil()).Block(
+			Return(Id(plural), Err()),
+		)
+
+		g.If(
+			List(Id("list"), Id("ok")).Op(":=").Id("result").Assert(Index().Op("*").Id(e.Name)),
+			Id("ok"),
+		).Block(
+			Id(plural).Op("=").Id("list"),
+		)
+
+		g.Return(Id(plural), Nil())
+	})
+}
+
+// LoaderFetchFunc builds a fetch function for a loader. The given
+// function generates the code that fills the results map, from the
+// keys in scope
+func LoaderFetchFunc(body func(*Group)) *Statement {
+	return Func().Params(
+		Id("ctx").Qual("context", "Context"),
+		Id("keys").Index().String(),
+	).Parens(List(
+		Map(String()).Interface(),
+		Error(),
+	)).BlockFunc(func(g *Group) {
+		g.Id("results").Op(":=").Map(String()).Interface().Values()
+		body(g)
+		g.Return(Id("results"), Nil())
+	})
+}
@@ -13,20 +13,24 @@ import (
 var (
 	model   *string
 	output  *string
-	db      *string
 	metrics *bool
+	db      *string
 )
 
 func init() {
 	model = flag.String("model", "", "the input model, in yaml format")
 	output = flag.String("output", "", "the output folder")
-	db = flag.String("db", "sqlite3", "the target database type")
 	metrics = flag.Bool("metrics", false, "add Prometheus instrumentation")
+	db = flag.String("db", "", "deprecated, the database dialect is chosen at runtime from the connection string")
 }
 
 func main() {
 	flag.Parse()
 
+	if *db != "" {
+		log.Printf("The --db flag is deprecated and ignored, the dialect is chosen at runtime from the connection string")
+	}
+
 	if *output == "" {
 		log.Fatal("Please specific an output directory")
 	}
@@ -62,11 +66,150 @@ func main() {
 		log.Fatal(fmt.Sprintf("Error generating repo: %v", err))
 	}
 
+	err = CreateStatements(&Package{
+		Name:     packageName,
+		Filename: path.Join(*output, "statements.go"),
+		Model:    model,
+	})
+
+	if err != nil {
+		log.Fatal(fmt.Sprintf("Error generating statements: %v", err))
+	}
+
+	err = CreateReplicas(&Package{
+		Name:     packageName,
+		Filename: path.Join(*output, "replica.go"),
+		Model:    model,
+	})
+
+	if err != nil {
+		log.Fatal(fmt.Sprintf("Error generating replicas: %v", err))
+	}
+
+	err = CreateRepository(&Package{
+		Name:     packageName,
+		Filename: path.Join(*output, "repository.go"),
+		Model:    model,
+	})
+
+	if err != nil {
+		log.Fatal(fmt.Sprintf("Error generating repository: %v", err))
+	}
+
+	err = CreateMemory(&Package{
+		Name:     packageName,
+		Filename: path.Join(*output, "memory.go"),
+		Model:    model,
+	})
+
+	if err != nil {
+		log.Fatal(fmt.Sprintf("Error generating memory: %v", err))
+	}
+
+	err = CreatePatch(&Package{
+		Name:     packageName,
+		Filename: path.Join(*output, "patch.go"),
+		Model:    model,
+	})
+
+	if err != nil {
+		log.Fatal(fmt.Sprintf("Error generating patch: %v", err))
+	}
+
+	err = CreateErrors(&Package{
+		Name:     packageName,
+		Filename: path.Join(*output, "errors.go"),
+		Model:    model,
+	})
+
+	if err != nil {
+		log.Fatal(fmt.Sprintf("Error generating errors: %v", err))
+	}
+
+	err = CreateIDs(&Package{
+		Name:     packageName,
+		Filename: path.Join(*output, "id.go"),
+		Model:    model,
+	})
+
+	if err != nil {
+		log.Fatal(fmt.Sprintf("Error generating ids: %v", err))
+	}
+
+	err = CreatePubSub(&Package{
+		Name:     packageName,
+		Filename: path.Join(*output, "pubsub.go"),
+		Model:    model,
+	})
+
+	if err != nil {
+		log.Fatal(fmt.Sprintf("Error generating pubsub: %v", err))
+	}
+
+	err = CreateNode(&Package{
+		Name:     packageName,
+		Filename: path.Join(*output, "node.go"),
+		Model:    model,
+	})
+
+	if err != nil {
+		log.Fatal(fmt.Sprintf("Error generating node: %v", err))
+	}
+
+	err = CreateFilter(&Package{
+		Name:     packageName,
+		Filename: path.Join(*output, "filter.go"),
+		Model:    model,
+	})
+
+	if err != nil {
+		log.Fatal(fmt.Sprintf("Error generating filter: %v", err))
+	}
+
+	err = CreateOrder(&Package{
+		Name:     packageName,
+		Filename: path.Join(*output, "order.go"),
+		Model:    model,
+	})
+
+	if err != nil {
+		log.Fatal(fmt.Sprintf("Error generating order: %v", err))
+	}
+
+	err = CreatePage(&Package{
+		Name:     packageName,
+		Filename: path.Join(*output, "page.go"),
+		Model:    model,
+	})
+
+	if err != nil {
+		log.Fatal(fmt.Sprintf("Error generating page: %v", err))
+	}
+
+	err = CreateAggregate(&Package{
+		Name:     packageName,
+		Filename: path.Join(*output, "aggregate.go"),
+		Model:    model,
+	})
+
+	if err != nil {
+		log.Fatal(fmt.Sprintf("Error generating aggregate: %v", err))
+	}
+
+	err = CreateLoader(&Package{
+		Name:     packageName,
+		Filename: path.Join(*output, "loader.go"),
+		Model:    model,
+	})
+
+	if err != nil {
+		log.Fatal(fmt.Sprintf("Error generating loader: %v", err))
+	}
+
 	err = CreateSql(&Package{
 		Name:     packageName,
 		Filename: path.Join(*output, "sql.go"),
 		Model:    model,
-		Database: *db,
 	})
 
 	if err != nil {
@@ -103,6 +246,16 @@ func main() {
 		log.Fatal(fmt.Sprintf("Error generating server: %v", err))
 	}
 
+	err = CreateWebsocket(&Package{
+		Name:     packageName,
+		Filename: path.Join(*output, "websocket.go"),
+		Model:    model,
+	})
+
+	if err != nil {
+		log.Fatal(fmt.Sprintf("Error generating websocket: %v", err))
+	}
+
 	err = CreateMonitoring(&Package{
 		Name:     packageName,
 		Filename: path.Join(*output, "monitoring.go"),
@@ -142,7 +295,7 @@ func CreateMain(p *Package) error {
 
 	AddVars(f)
 	AddInit(f)
-	AddMainFun(f)
+	AddMainFun(p.Model, f)
 	return f.Save(p.Filename)
 }
 
@@ -150,6 +303,7 @@ func CreateMain(p *Package) error {
 func AddVars(f *File) {
 	f.Var().DefsFunc(func(vars *Group) {
 		vars.Id("db").Op("*").String()
+		vars.Id("replicaDbs").Op("*").String()
 	})
 }
 
@@ -158,6 +312,7 @@ func AddInit(f *File) {
 	f.Func().Id("init").Params().BlockFunc(func(g *Group) {
 
 		InitFlag("db", "db", "String", "file::memory:?cache=shared", "the database connection string", g)
+		InitFlag("replicaDbs", "replicas", "String", "", "the comma separated connection strings of read replicas", g)
 	})
 }
 
@@ -170,12 +325,13 @@ func InitFlag(varName string, flag string, flatType string, defaultValue string,
 	)
 }
 
-func AddMainFun(f *File) {
+func AddMainFun(m *Model, f *File) {
 	funName := "main"
 
 	f.Func().Id(funName).Params().BlockFunc(func(g *Group) {
 		g.Qual("flag", "Parse").Call()
 
+		g.Id("dialect").Op(":=").Id("DialectFromDsn").Call(Op("*").Id("db"))
 		g.List(
 			Id("db"),
 			Err(),
@@ -185,16 +341,47 @@ func AddMainFun(f *File) {
 		IfErrorLogFatal("Error opening database: %v", g)
 
 		g.Err().Op("=").Id("ExecStatements").Call(
+			Qual("context", "Background").Call(),
 			Id("db"),
-			Id("SqlSchema").Call(),
+			Id("SqlSchema").Call(Id("dialect")),
 		)
 
+		if ModelSupportsSearch(m) {
+			FailWithoutFts5(g)
+		}
+
 		IfErrorLogFatal("Error initializing database: %v", g)
 
+		g.List(
+			Id("stmts"),
+			Err(),
+		).Op(":=").Id("PrepareStatements").Call(
+			Qual("context", "Background").Call(),
+			Id("db"),
+			Id("dialect"),
+		)
+		IfErrorLogFatal("Error preparing statements: %v", g)
+
+		g.List(
+			Id("replicas"),
+			Err(),
+		).Op(":=").Id("NewReplicas").Call(
+			Qual("context", "Background").Call(),
+			Qual("strings", "Split").Call(Op("*").Id("replicaDbs"), Lit(",")),
+		)
+		IfErrorLogFatal("Error opening replicas: %v", g)
+
+		g.Go().Id("replicas").Dot("Watch").Call(Qual("context", "Background").Call())
+
 		g.Id("SetupServer").Call(
 			Id("Schema").Call(),
 			Op("&").Id("Resolver").Values(Dict{
-				Id("Db"): Id("db"),
+				Id("Repo"): Op("&").Id("SqlRepository").Values(Dict{
+					Id("Db"):         Id("db"),
+					Id("Statements"): Id("stmts"),
+					Id("Replicas"):   Id("replicas"),
+				}),
+				Id("Events"): Id("NewPubSub").Call(),
 			}),
 		)
 
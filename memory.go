@@ -0,0 +1,1095 @@
+package main
+
+import (
+	"fmt"
+
+	. "github.com/dave/jennifer/jen"
+)
+
+// CreateMemory generates a Golang file that contains a Repository
+// that holds all instances in memory. It behaves like the database
+// does, honouring unique attributes and relations, so that resolvers
+// and hooks can be tested without one
+func CreateMemory(p *Package) error {
+	f := NewFile(p.Name)
+
+	f.PackageComment(fmt.Sprintf("%s contains all the library code for the platform", p.Name))
+	f.PackageComment("This file contains the in-memory implementation of the repository")
+	f.PackageComment(" ** THIS CODE IS MACHINE GENERATED. DO NOT EDIT MANUALLY ** ")
+
+	f.ImportAlias("database/sql", "sql")
+
+	AddMemoryDataStruct(p.Model, f)
+	AddMemoryRepositoryStruct(f)
+	AddMemoryTransactionStruct(f)
+	AddMemoryCompareValuesFun(f)
+	AddMemoryCompareFun(f)
+	AddMemoryPageFun(f)
+	AddMemoryAggregateFun(f)
+
+	if ModelSupportsSearch(p.Model) {
+		AddMemorySearchTermsFun(f)
+		AddMemorySearchRankFun(f)
+	}
+
+	for _, e := range p.Model.Entities {
+		if len(EntityRepositoryMethods(e)) == 0 {
+			continue
+		}
+
+		AddMemoryCopyFun(e, f)
+
+		if e.SupportsOperation("create") || e.SupportsOperation("update") || e.SupportsOperation("upsert") {
+			AddMemoryCheckFun(e, p.Model, f)
+			AddMemoryInsertFun(e, f)
+			AddMemoryUpdateFun(e, f)
+		}
+
+		if e.SupportsOperation("delete") {
+			AddMemoryRemoveFun(e, p.Model, f)
+		}
+
+		if e.SupportsOperation("find") {
+			AddMemoryFindFun(e, f)
+
+			if e.SupportsCursorPagination() {
+				AddMemoryPageEntitiesFun(e, f)
+			}
+		}
+
+		for _, m := range EntityRepositoryMethods(e) {
+			AddMemoryRepositoryMethod(m, f)
+		}
+	}
+
+	return f.Save(p.Filename)
+}
+
+// AddMemoryDataStruct generates the struct that holds the instances of
+// every entity in the model, by id, along with the last ID assigned to
+// entities whose IDs come from a sequence, and the functions that
+// create and copy it
+func AddMemoryDataStruct(m *Model, f *File) {
+	f.Comment("MemoryData holds the instances of every entity, by id, and the last ID of every sequence")
+	f.Type().Id("MemoryData").StructFunc(func(g *Group) {
+		for _, e := range m.Entities {
+			g.Id(e.PluralName()).Map(String()).Op("*").Id(e.Name)
+		}
+
+		for _, e := range m.Entities {
+			if e.GeneratesIDInDatabase() {
+				g.Id(MemorySequenceName(e)).Int64()
+			}
+		}
+	})
+
+	f.Comment("NewMemoryData returns data that holds no instances")
+	f.Func().Id("NewMemoryData").Params().Op("*").Id("MemoryData").Block(
+		Return(Op("&").Id("MemoryData").Values(DictFunc(func(d Dict) {
+			for _, e := range m.Entities {
+				d[Id(e.PluralName())] = Map(String()).Op("*").Id(e.Name).Values()
+			}
+		}))),
+	)
+
+	f.Comment("Clone returns a copy of the data. Instances are shared, as they are never modified once stored")
+	f.Func().Parens(Id("d").Op("*").Id("MemoryData")).Id("Clone").Params().Op("*").Id("MemoryData").BlockFunc(func(g *Group) {
+		g.Id("c").Op(":=").Id("NewMemoryData").Call()
+		for _, e := range m.Entities {
+			g.For(List(Id("id"), Id(e.VarName())).Op(":=").Range().Id("d").Dot(e.PluralName())).Block(
+				Id("c").Dot(e.PluralName()).Index(Id("id")).Op("=").Id(e.VarName()),
+			)
+		}
+
+		for _, e := range m.Entities {
+			if e.GeneratesIDInDatabase() {
+				g.Id("c").Dot(MemorySequenceName(e)).Op("=").Id("d").Dot(MemorySequenceName(e))
+			}
+		}
+
+		g.Return(Id("c"))
+	})
+}
+
+// MemorySequenceName returns the name of the field that holds the last
+// ID assigned to an instance of the given entity
+func MemorySequenceName(e *Entity) string {
+	return fmt.Sprintf("%sSequence", e.Name)
+}
+
+// AddMemoryRepositoryStruct generates the in-memory Repository, and
+// the methods that open transactions and lock it for writes
+func AddMemoryRepositoryStruct(f *File) {
+	f.Comment("MemoryRepository is a Repository that holds all instances in memory. Unique attributes and relations are enforced like the database does, so that resolvers and hooks can be tested without one. Transactions are serialized")
+	f.Type().Id("MemoryRepository").Struct(
+		Id("mu").Qual("sync", "RWMutex"),
+		Id("txMu").Qual("sync", "Mutex"),
+		Id("data").Op("*").Id("MemoryData"),
+	)
+
+	f.Comment("NewMemoryRepository returns a repository that holds no instances")
+	f.Func().Id("NewMemoryRepository").Params().Op("*").Id("MemoryRepository").Block(
+		Return(Op("&").Id("MemoryRepository").Values(Dict{
+			Id("data"): Id("NewMemoryData").Call(),
+		})),
+	)
+
+	f.Comment("Begin opens a transaction on a copy of the data, once other transactions are done. Changes are swapped in when the transaction is committed")
+	f.Func().Parens(Id("r").Op("*").Id("MemoryRepository")).Id("Begin").Params(
+		Id("ctx").Qual("context", "Context"),
+	).Parens(List(
+		Id("Transaction"),
+		Error(),
+	)).Block(
+		Id("r").Dot("txMu").Dot("Lock").Call(),
+		Id("r").Dot("mu").Dot("RLock").Call(),
+		Defer().Id("r").Dot("mu").Dot("RUnlock").Call(),
+		Return(Op("&").Id("MemoryTransaction").Values(Dict{
+			Id("MemoryRepository"): Op("&").Id("MemoryRepository").Values(Dict{
+				Id("data"): Id("r").Dot("data").Dot("Clone").Call(),
+			}),
+			Id("parent"): Id("r"),
+		}), Nil()),
+	)
+
+	f.Comment("write locks the repository for changes, once open transactions are done. The returned function unlocks it")
+	f.Func().Parens(Id("r").Op("*").Id("MemoryRepository")).Id("write").Params().Func().Params().Block(
+		Id("r").Dot("txMu").Dot("Lock").Call(),
+		Id("r").Dot("mu").Dot("Lock").Call(),
+		Return(Func().Params().Block(
+			Id("r").Dot("mu").Dot("Unlock").Call(),
+			Id("r").Dot("txMu").Dot("Unlock").Call(),
+		)),
+	)
+}
+
+// AddMemoryTransactionStruct generates the Transaction of the
+// in-memory repository
+func AddMemoryTransactionStruct(f *File) {
+	f.Comment("MemoryTransaction is a Transaction of a MemoryRepository. It works on its own copy of the data, and holds the repository until it is committed or rolled back")
+	f.Type().Id("MemoryTransaction").Struct(
+		Op("*").Id("MemoryRepository"),
+		Id("parent").Op("*").Id("MemoryRepository"),
+		Id("done").Bool(),
+	)
+
+	f.Comment("Begin fails, as transactions cannot be nested")
+	f.Func().Parens(Id("t").Op("*").Id("MemoryTransaction")).Id("Begin").Params(
+		Id("ctx").Qual("context", "Context"),
+	).Parens(List(
+		Id("Transaction"),
+		Error(),
+	)).Block(
+		Return(Nil(), Qual("fmt", "Errorf").Call(Lit("Nested transactions are not supported"))),
+	)
+
+	f.Comment("Commit swaps the data of the transaction into the repository")
+	f.Func().Parens(Id("t").Op("*").Id("MemoryTransaction")).Id("Commit").Params().Error().Block(
+		If(Id("t").Dot("done")).Block(
+			Return(Qual("database/sql", "ErrTxDone")),
+		),
+		Id("t").Dot("done").Op("=").True(),
+		Id("t").Dot("parent").Dot("mu").Dot("Lock").Call(),
+		Id("t").Dot("parent").Dot("data").Op("=").Id("t").Dot("data"),
+		Id("t").Dot("parent").Dot("mu").Dot("Unlock").Call(),
+		Id("t").Dot("parent").Dot("txMu").Dot("Unlock").Call(),
+		Return(Nil()),
+	)
+
+	f.Comment("Rollback discards the data of the transaction. It fails with sql.ErrTxDone once the transaction is committed")
+	f.Func().Parens(Id("t").Op("*").Id("MemoryTransaction")).Id("Rollback").Params().Error().Block(
+		If(Id("t").Dot("done")).Block(
+			Return(Qual("database/sql", "ErrTxDone")),
+		),
+		Id("t").Dot("done").Op("=").True(),
+		Id("t").Dot("parent").Dot("txMu").Dot("Unlock").Call(),
+		Return(Nil()),
+	)
+}
+
+// AddMemoryCompareValuesFun generates a function that compares two
+// values of any of the Golang types attributes are held in
+func AddMemoryCompareValuesFun(f *File) {
+	funName := "MemoryCompareValues"
+
+	less := func(t *Statement) []Code {
+		return []Code{
+			List(Id("b"), Id("_")).Op(":=").Id("b").Assert(t),
+			If(Id("a").Op("<").Id("b")).Block(Return(Lit(-1))),
+			If(Id("a").Op(">").Id("b")).Block(Return(Lit(1))),
+		}
+	}
+
+	f.Comment(fmt.Sprintf("%s returns a negative number, zero or a positive number when a is less than, equal to or greater than b. Both values must be of the same type", funName))
+	f.Func().Id(funName).Params(
+		Id("a").Interface(),
+		Id("b").Interface(),
+	).Int().BlockFunc(func(g *Group) {
+		g.Switch(Id("a").Op(":=").Id("a").Assert(Type())).Block(
+			Case(String()).Block(less(String())...),
+			Case(Int()).Block(less(Int())...),
+			Case(Float64()).Block(less(Float64())...),
+			Case(Bool()).Block(
+				List(Id("b"), Id("_")).Op(":=").Id("b").Assert(Bool()),
+				If(Op("!").Id("a").Op("&&").Id("b")).Block(Return(Lit(-1))),
+				If(Id("a").Op("&&").Op("!").Id("b")).Block(Return(Lit(1))),
+			),
+		)
+
+		g.Return(Lit(0))
+	})
+}
+
+// AddMemoryCompareFun generates a function that compares two lists of
+// values of sort fields, as SQL ORDER BY clauses do
+func AddMemoryCompareFun(f *File) {
+	funName := "MemoryCompare"
+
+	f.Comment(fmt.Sprintf("%s compares two lists of values of the given sort orders, in the direction of each order. The first values that differ decide", funName))
+	f.Func().Id(funName).Params(
+		Id("orders").Index().Op("*").Id("SortOrder"),
+		Id("a").Index().Interface(),
+		Id("b").Index().Interface(),
+	).Int().Block(
+		For(List(Id("i"), Id("o")).Op(":=").Range().Id("orders")).Block(
+			Id("c").Op(":=").Id("MemoryCompareValues").Call(Id("a").Index(Id("i")), Id("b").Index(Id("i"))),
+			If(Id("o").Dot("Direction").Op("==").Lit("DESC")).Block(
+				Id("c").Op("=").Op("-").Id("c"),
+			),
+			If(Id("c").Op("!=").Lit(0)).Block(
+				Return(Id("c")),
+			),
+		),
+		Return(Lit(0)),
+	)
+}
+
+// AddMemoryPageFun generates a function that applies a limit and an
+// offset to a list held in memory
+func AddMemoryPageFun(f *File) {
+	funName := "MemoryPage"
+
+	f.Comment(fmt.Sprintf("%s returns the bounds of the page selected by the given limit and offset, from a list of the given length", funName))
+	f.Func().Id(funName).Params(
+		Id("length").Int(),
+		Id("limit").Int32(),
+		Id("offset").Int32(),
+	).Parens(List(Int(), Int())).Block(
+		Id("start").Op(":=").Int().Call(Id("offset")),
+		If(Id("start").Op("<").Lit(0)).Block(
+			Id("start").Op("=").Lit(0),
+		),
+		If(Id("start").Op(">").Id("length")).Block(
+			Id("start").Op("=").Id("length"),
+		),
+		Id("end").Op(":=").Id("length"),
+		If(Id("limit").Op(">=").Lit(0).Op("&&").Int64().Call(Id("start")).Op("+").Int64().Call(Id("limit")).Op("<").Int64().Call(Id("length"))).Block(
+			Id("end").Op("=").Id("start").Op("+").Int().Call(Id("limit")),
+		),
+		Return(Id("start"), Id("end")),
+	)
+}
+
+// AddMemoryAggregateFun generates a function that folds a value into
+// a numeric aggregate
+func AddMemoryAggregateFun(f *File) {
+	funName := "MemoryAggregate"
+
+	f.Comment(fmt.Sprintf("%s folds the given value into the given aggregate, computed by the given function. Averages are folded as sums, to be divided by the count once all values are folded", funName))
+	f.Func().Id(funName).Params(
+		Id("fun").String(),
+		Id("aggregate").Op("*").Float64(),
+		Id("value").Float64(),
+	).Op("*").Float64().Block(
+		If(Id("aggregate").Op("==").Nil()).Block(
+			Return(Op("&").Id("value")),
+		),
+		Id("result").Op(":=").Op("*").Id("aggregate"),
+		Switch(Id("fun")).Block(
+			Case(Lit("Min")).Block(
+				If(Id("value").Op("<").Id("result")).Block(
+					Id("result").Op("=").Id("value"),
+				),
+			),
+			Case(Lit("Max")).Block(
+				If(Id("value").Op(">").Id("result")).Block(
+					Id("result").Op("=").Id("value"),
+				),
+			),
+			Default().Block(
+				Id("result").Op("+=").Id("value"),
+			),
+		),
+		Return(Op("&").Id("result")),
+	)
+}
+
+// AddMemorySearchTermsFun generates a function that splits text into
+// the terms matched by full text search
+func AddMemorySearchTermsFun(f *File) {
+	funName := "MemorySearchTerms"
+
+	f.Comment(fmt.Sprintf("%s splits the given text into lower case words, as full text search does", funName))
+	f.Func().Id(funName).Params(
+		Id("text").String(),
+	).Index().String().Block(
+		Return(Qual("strings", "FieldsFunc").Call(
+			Qual("strings", "ToLower").Call(Id("text")),
+			Func().Params(Id("c").Rune()).Bool().Block(
+				Return(Op("!").Qual("unicode", "IsLetter").Call(Id("c")).Op("&&").Op("!").Qual("unicode", "IsNumber").Call(Id("c"))),
+			),
+		)),
+	)
+}
+
+// AddMemorySearchRankFun generates a function that ranks text against
+// a full text search query
+func AddMemorySearchRankFun(f *File) {
+	funName := "MemorySearchRank"
+
+	f.Comment(fmt.Sprintf("%s returns the number of occurrences of the words of the given query in the given text, or zero unless every word occurs", funName))
+	f.Func().Id(funName).Params(
+		Id("text").String(),
+		Id("query").String(),
+	).Int().Block(
+		Id("counts").Op(":=").Map(String()).Int().Values(),
+		For(List(Id("_"), Id("term")).Op(":=").Range().Id("MemorySearchTerms").Call(Id("text"))).Block(
+			Id("counts").Index(Id("term")).Op("++"),
+		),
+		Id("rank").Op(":=").Lit(0),
+		For(List(Id("_"), Id("term")).Op(":=").Range().Id("MemorySearchTerms").Call(Id("query"))).Block(
+			If(Id("counts").Index(Id("term")).Op("==").Lit(0)).Block(
+				Return(Lit(0)),
+			),
+			Id("rank").Op("+=").Id("counts").Index(Id("term")),
+		),
+		Return(Id("rank")),
+	)
+}
+
+// MemoryCopyFunName returns the name of the function that copies
+// instances of the given entity
+func MemoryCopyFunName(e *Entity) string {
+	return fmt.Sprintf("copy%s", e.Name)
+}
+
+// AddMemoryCopyFun generates the function that copies an instance of
+// the given entity, so that instances held in memory are never shared
+// with callers. Relations are copied as they are read from the
+// database, holding only the id
+func AddMemoryCopyFun(e *Entity, f *File) {
+	funName := MemoryCopyFunName(e)
+
+	f.Comment(fmt.Sprintf("%s returns a copy of the given %s, that shares no relations with it", funName, e.Name))
+	f.Func().Id(funName).Params(
+		Id(e.VarName()).Op("*").Id(e.Name),
+	).Op("*").Id(e.Name).BlockFunc(func(g *Group) {
+		g.Id("c").Op(":=").Op("*").Id(e.VarName())
+		for _, r := range e.Relations {
+			if r.HasModifier("belongsTo") || r.HasModifier("hasOne") {
+				g.Id("c").Dot(r.Alias()).Op("=").Op("&").Id(r.Entity).Values()
+				g.If(Id(e.VarName()).Dot(r.Alias()).Op("!=").Nil()).Block(
+					Id("c").Dot(r.Alias()).Dot("ID").Op("=").Id(e.VarName()).Dot(r.Alias()).Dot("ID"),
+				)
+			}
+		}
+
+		g.Return(Op("&").Id("c"))
+	})
+}
+
+// AddMemoryCheckFun generates the method that checks an instance of
+// the given entity against the constraints of its table: unique
+// attributes, and foreign keys. Errors read like the ones of sqlite
+func AddMemoryCheckFun(e *Entity, m *Model, f *File) {
+	funName := fmt.Sprintf("check%s", e.Name)
+	v := e.VarName()
+
+	f.Comment(fmt.Sprintf("%s returns an error if the given %s breaks a unique attribute or a relation, as the database would", funName, e.Name))
+	f.Func().Parens(Id("r").Op("*").Id("MemoryRepository")).Id(funName).Params(
+		Id(v).Op("*").Id(e.Name),
+	).Error().BlockFunc(func(g *Group) {
+		unique := []*Attribute{}
+		for _, a := range e.Attributes {
+			if a.Name != "ID" && a.HasModifier("unique") {
+				unique = append(unique, a)
+			}
+		}
+
+		if len(unique) > 0 {
+			g.For(List(Id("_"), Id("other")).Op(":=").Range().Id("r").Dot("data").Dot(e.PluralName())).BlockFunc(func(g2 *Group) {
+				for _, a := range unique {
+					g2.If(Id("other").Dot("ID").Op("!=").Id(v).Dot("ID").Op("&&").Id("other").Dot(a.Name).Op("==").Id(v).Dot(a.Name)).Block(
+						Return(MemoryError("CodeUniqueViolation", e, AttributeGraphqlFieldName(a))),
+					)
+				}
+			})
+		}
+
+		for _, r := range e.Relations {
+			if r.HasModifier("belongsTo") || r.HasModifier("hasOne") {
+				target := m.EntityForNameOrPanic(r.Entity)
+				g.If(Id(v).Dot(r.Alias()).Op("==").Nil().Op("||").Id("r").Dot("data").Dot(target.PluralName()).Index(Id(v).Dot(r.Alias()).Dot("ID")).Op("==").Nil()).Block(
+					Return(MemoryError("CodeForeignKeyViolation", e, RelationGraphqlFieldName(r))),
+				)
+			}
+		}
+
+		g.Return(Nil())
+	})
+}
+
+// AddMemoryInsertFun generates the method that stores a new instance
+// of the given entity
+func AddMemoryInsertFun(e *Entity, f *File) {
+	funName := fmt.Sprintf("insert%s", e.Name)
+	v := e.VarName()
+
+	f.Comment(fmt.Sprintf("%s stores a copy of the given %s, unless its id is taken or it breaks a constraint", funName, e.Name))
+	if e.GeneratesIDInDatabase() {
+		f.Comment("Like the database does, it assigns the next ID of the sequence to the given instance")
+	}
+	f.Func().Parens(Id("r").Op("*").Id("MemoryRepository")).Id(funName).Params(
+		Id(v).Op("*").Id(e.Name),
+	).Error().BlockFunc(func(g *Group) {
+		if e.GeneratesIDInDatabase() {
+			g.Id("r").Dot("data").Dot(MemorySequenceName(e)).Op("++")
+			g.Id(v).Dot("ID").Op("=").Qual("strconv", "FormatInt").Call(Id("r").Dot("data").Dot(MemorySequenceName(e)), Lit(10))
+		}
+
+		g.If(
+			List(Id("_"), Id("ok")).Op(":=").Id("r").Dot("data").Dot(e.PluralName()).Index(Id(v).Dot("ID")),
+			Id("ok"),
+		).Block(
+			Return(MemoryError("CodeUniqueViolation", e, "id")),
+		)
+		g.If(Err().Op(":=").Id("r").Dot(fmt.Sprintf("check%s", e.Name)).Call(Id(v)), Err().Op("!=").Nil()).Block(
+			Return(Err()),
+		)
+		g.Id("r").Dot("data").Dot(e.PluralName()).Index(Id(v).Dot("ID")).Op("=").Id(MemoryCopyFunName(e)).Call(Id(v))
+		g.Return(Nil())
+	})
+}
+
+// AddMemoryUpdateFun generates the method that replaces a stored
+// instance of the given entity
+func AddMemoryUpdateFun(e *Entity, f *File) {
+	funName := fmt.Sprintf("update%s", e.Name)
+	v := e.VarName()
+
+	f.Comment(fmt.Sprintf("%s replaces the stored %s with a copy of the given one, unless it breaks a constraint. Like the Update function of the database, it returns a not found error if there is none", funName, e.Name))
+	f.Func().Parens(Id("r").Op("*").Id("MemoryRepository")).Id(funName).Params(
+		Id(v).Op("*").Id(e.Name),
+	).Error().Block(
+		If(
+			List(Id("_"), Id("ok")).Op(":=").Id("r").Dot("data").Dot(e.PluralName()).Index(Id(v).Dot("ID")),
+			Op("!").Id("ok"),
+		).Block(
+			Return(NotFoundError(e, Id(v).Dot("ID"))),
+		),
+		If(Err().Op(":=").Id("r").Dot(fmt.Sprintf("check%s", e.Name)).Call(Id(v)), Err().Op("!=").Nil()).Block(
+			Return(Err()),
+		),
+		Id("r").Dot("data").Dot(e.PluralName()).Index(Id(v).Dot("ID")).Op("=").Id(MemoryCopyFunName(e)).Call(Id(v)),
+		Return(Nil()),
+	)
+}
+
+// MemoryError returns a statement that builds an error of the given
+// code for the given entity and field, like the ones classified from
+// database errors
+func MemoryError(code string, e *Entity, field string) *Statement {
+	return Id("NewError").Call(Id(code), Lit(e.Name), Lit(field), Nil())
+}
+
+// AddMemoryRemoveFun generates the method that removes a stored
+// instance of the given entity, unless instances of other entities
+// still point at it
+func AddMemoryRemoveFun(e *Entity, m *Model, f *File) {
+	funName := fmt.Sprintf("remove%s", e.Name)
+
+	f.Comment(fmt.Sprintf("%s removes the %s with the given id, unless other instances point at it. Like a DELETE statement, it does nothing if there is none", funName, e.Name))
+	f.Func().Parens(Id("r").Op("*").Id("MemoryRepository")).Id(funName).Params(
+		Id("id").String(),
+	).Error().BlockFunc(func(g *Group) {
+		g.If(
+			List(Id("_"), Id("ok")).Op(":=").Id("r").Dot("data").Dot(e.PluralName()).Index(Id("id")),
+			Op("!").Id("ok"),
+		).Block(
+			Return(Nil()),
+		)
+
+		for _, e2 := range m.Entities {
+			for _, r := range e2.Relations {
+				if r.Entity == e.Name && (r.HasModifier("belongsTo") || r.HasModifier("hasOne")) {
+					g.For(List(Id("_"), Id("other")).Op(":=").Range().Id("r").Dot("data").Dot(e2.PluralName())).Block(
+						If(Id("other").Dot(r.Alias()).Dot("ID").Op("==").Id("id")).Block(
+							Return(MemoryError("CodeForeignKeyViolation", e, "")),
+						),
+					)
+				}
+			}
+		}
+
+		g.Delete(Id("r").Dot("data").Dot(e.PluralName()), Id("id"))
+		g.Return(Nil())
+	})
+}
+
+// MemoryFindFunName returns the name of the method that lists stored
+// instances of the given entity
+func MemoryFindFunName(e *Entity) string {
+	return fmt.Sprintf("find%s", e.PluralName())
+}
+
+// AddMemoryFindFun generates the method that lists copies of the
+// stored instances of the given entity that match a condition and a
+// filter, sorted like the database would. Sort orders are completed
+// with the ID, so that results are always in the same order
+func AddMemoryFindFun(e *Entity, f *File) {
+	funName := MemoryFindFunName(e)
+	plural := VarName(e.PluralName())
+	v := e.VarName()
+
+	f.Comment(fmt.Sprintf("%s returns copies of the instances of type %s that satisfy the given condition, if any, and the given filter. Results are sorted by the given orders, completed with the ID, which are returned as well", funName, e.Name))
+	f.Func().Parens(Id("r").Op("*").Id("MemoryRepository")).Id(funName).Params(
+		Id("match").Func().Params(Op("*").Id(e.Name)).Bool(),
+		Id("filter").Op("*").Id(EntityFilterName(e)),
+		Id("orderBy").Index().Op("*").Id("SortOrder"),
+	).Parens(List(
+		Index().Op("*").Id(e.Name),
+		Index().Op("*").Id("SortOrder"),
+		Error(),
+	)).Block(
+		Id(plural).Op(":=").Index().Op("*").Id(e.Name).Values(),
+		If(
+			List(Id("_"), Err()).Op(":=").Id("SqlOrderBy").Call(
+				Id("orderBy"),
+				Id(EntitySortColumnsName(e)),
+				Lit(AttributeColumnName(e.PreferredSort())),
+			),
+			Err().Op("!=").Nil(),
+		).Block(
+			Return(Id(plural), Nil(), Err()),
+		),
+		Id("orders").Op(":=").Id("KeysetOrders").Call(
+			Id("orderBy"),
+			Lit(AttributeSortField(e.PreferredSort())),
+			Lit(AttributeSortField(&Attribute{Name: "ID"})),
+		),
+		Line(),
+		Id("r").Dot("mu").Dot("RLock").Call(),
+		For(List(Id("_"), Id(v)).Op(":=").Range().Id("r").Dot("data").Dot(e.PluralName())).Block(
+			If(Parens(Id("match").Op("==").Nil().Op("||").Id("match").Call(Id(v))).Op("&&").Id("filter").Dot("Match").Call(Id(v))).Block(
+				Id(plural).Op("=").Append(Id(plural), Id(MemoryCopyFunName(e)).Call(Id(v))),
+			),
+		),
+		Id("r").Dot("mu").Dot("RUnlock").Call(),
+		Line(),
+		Qual("sort", "Slice").Call(Id(plural), Func().Params(Id("i"), Id("j").Int()).Bool().Block(
+			Return(Id("MemoryCompare").Call(
+				Id("orders"),
+				Id(EntitySortValuesFunName(e)).Call(Id(plural).Index(Id("i")), Id("orders")),
+				Id(EntitySortValuesFunName(e)).Call(Id(plural).Index(Id("j")), Id("orders")),
+			).Op("<").Lit(0)),
+		)),
+		Return(Id(plural), Id("orders"), Nil()),
+	)
+}
+
+// MemoryPageEntitiesFunName returns the name of the function that
+// selects a page of instances of the given entity held in memory
+func MemoryPageEntitiesFunName(e *Entity) string {
+	return fmt.Sprintf("page%s", e.PluralName())
+}
+
+// AddMemoryPageEntitiesFun generates the function that selects a page
+// of sorted instances of the given entity, with the same cursors as
+// the keyset pagination of the database
+func AddMemoryPageEntitiesFun(e *Entity, f *File) {
+	funName := MemoryPageEntitiesFunName(e)
+	plural := VarName(e.PluralName())
+	v := e.VarName()
+
+	f.Comment(fmt.Sprintf("%s selects the given page of the given instances of type %s, sorted by the given orders", funName, e.Name))
+	f.Func().Id(funName).Params(
+		Id(plural).Index().Op("*").Id(e.Name),
+		Id("orders").Index().Op("*").Id("SortOrder"),
+		Id("page").Op("*").Id("Page"),
+	).Parens(List(
+		Op("*").Id(EntityConnectionName(e)),
+		Error(),
+	)).BlockFunc(func(g *Group) {
+		g.Id("connection").Op(":=").Op("&").Id(EntityConnectionName(e)).Values(Dict{
+			Id("Edges"):    Index().Op("*").Id(EntityEdgeName(e)).Values(),
+			Id("PageInfo"): Op("&").Id("PageInfo").Values(),
+		})
+
+		g.List(Id("limit"), Id("backward"), Err()).Op(":=").Id("PageSize").Call(Id("page"))
+		g.If(Err().Op("!=").Nil()).Block(
+			Return(Id("connection"), Err()),
+		)
+
+		for _, cursor := range [][]string{{"After", ">"}, {"Before", "<"}} {
+			g.If(Id("page").Dot(cursor[0]).Op("!=").Nil()).Block(
+				List(Id("values"), Err()).Op(":=").Id(EntityCursorValuesFunName(e)).Call(
+					Op("*").Id("page").Dot(cursor[0]),
+					Id("orders"),
+				),
+				If(Err().Op("!=").Nil()).Block(
+					Return(Id("connection"), Err()),
+				),
+				Id("selected").Op(":=").Index().Op("*").Id(e.Name).Values(),
+				For(List(Id("_"), Id(v)).Op(":=").Range().Id(plural)).Block(
+					If(Id("MemoryCompare").Call(
+						Id("orders"),
+						Id(EntitySortValuesFunName(e)).Call(Id(v), Id("orders")),
+						Id("values"),
+					).Op(cursor[1]).Lit(0)).Block(
+						Id("selected").Op("=").Append(Id("selected"), Id(v)),
+					),
+				),
+				Id(plural).Op("=").Id("selected"),
+			)
+		}
+
+		g.Id("more").Op(":=").Len(Id(plural)).Op(">").Int().Call(Id("limit"))
+		g.If(Id("more").Op("&&").Id("backward")).Block(
+			Id(plural).Op("=").Id(plural).Index(Len(Id(plural)).Op("-").Int().Call(Id("limit")), Empty()),
+		).Else().If(Id("more")).Block(
+			Id(plural).Op("=").Id(plural).Index(Empty(), Id("limit")),
+		)
+
+		g.Id("cursors").Op(":=").Index().String().Values()
+		g.For(List(Id("_"), Id(v)).Op(":=").Range().Id(plural)).Block(
+			Id("cursor").Op(":=").Id("EncodeCursor").Call(
+				Id("orders"),
+				Id(EntitySortValuesFunName(e)).Call(Id(v), Id("orders")),
+			),
+			Id("cursors").Op("=").Append(Id("cursors"), Id("cursor")),
+			Id("connection").Dot("Edges").Op("=").Append(Id("connection").Dot("Edges"), Op("&").Id(EntityEdgeName(e)).Values(Dict{
+				Id("Cursor"): Id("cursor"),
+				Id("Node"):   Id(v),
+			})),
+		)
+
+		g.Id("connection").Dot("PageInfo").Op("=").Id("NewPageInfo").Call(Id("page"), Id("more"), Id("cursors"))
+		g.Return(Id("connection"), Nil())
+	})
+}
+
+// AddMemoryRepositoryMethod generates the method of MemoryRepository
+// that implements the given repository method
+func AddMemoryRepositoryMethod(m *RepositoryMethod, f *File) {
+	e := m.Entity
+
+	f.Comment(fmt.Sprintf("%s is the in-memory version of the %s repository function", m.Name, m.Name))
+	f.Func().Parens(Id("r").Op("*").Id("MemoryRepository")).Id(m.Name).Params(
+		RepositoryMethodParams(m)...,
+	).Parens(List(m.Results...)).BlockFunc(func(g *Group) {
+		switch m.Kind {
+		case "create":
+			g.Defer().Id("r").Dot("write").Call().Call()
+			g.Return(Id(e.VarName()), Id("r").Dot("insert"+e.Name).Call(Id(e.VarName())))
+
+		case "update":
+			g.Defer().Id("r").Dot("write").Call().Call()
+			g.Return(Id(e.VarName()), Id("r").Dot("update"+e.Name).Call(Id(e.VarName())))
+
+		case "patch":
+			AddMemoryPatchBody(e, g)
+
+		case "createMany":
+			AddMemoryManyBody(e, "insert"+e.Name, g)
+
+		case "updateMany":
+			AddMemoryManyBody(e, "update"+e.Name, g)
+
+		case "delete":
+			g.Defer().Id("r").Dot("write").Call().Call()
+			g.List(Id("stored"), Id("ok")).Op(":=").Id("r").Dot("data").Dot(e.PluralName()).Index(Id("id"))
+			g.If(Op("!").Id("ok")).Block(
+				EmptyStructForEntity(e),
+				Return(Id(e.VarName()), MemoryError("CodeNotFound", e, "")),
+			)
+			g.Return(Id(MemoryCopyFunName(e)).Call(Id("stored")), Id("r").Dot("remove"+e.Name).Call(Id("id")))
+
+		case "deleteMany":
+			plural := VarName(e.PluralName())
+			g.Defer().Id("r").Dot("write").Call().Call()
+			g.Id(plural).Op(":=").Index().Op("*").Id(e.Name).Values()
+			g.For(List(Id("_"), Id("id")).Op(":=").Range().Id("ids")).BlockFunc(func(g2 *Group) {
+				g2.List(Id("stored"), Id("ok")).Op(":=").Id("r").Dot("data").Dot(e.PluralName()).Index(Id("id"))
+				g2.If(Op("!").Id("ok")).Block(
+					Return(Id(plural), NotFoundError(e, Id("id"))),
+				)
+				g2.If(Err().Op(":=").Id("r").Dot("remove"+e.Name).Call(Id("id")), Err().Op("!=").Nil()).Block(
+					Return(Id(plural), Err()),
+				)
+				g2.Id(plural).Op("=").Append(Id(plural), Id(MemoryCopyFunName(e)).Call(Id("stored")))
+			})
+			g.Return(Id(plural), Nil())
+
+		case "upsert":
+			AddMemoryUpsertBody(e, g)
+
+		case "findByAttribute":
+			AddMemoryFindByAttributeBody(e, m.Attribute, g)
+
+		case "findListByAttribute", "findListByRelation", "findAll":
+			AddMemoryFindListBody(e, MemoryMatchFunc(e, m), g)
+
+		case "findConnectionByAttribute", "findConnectionByRelation", "findAllConnection":
+			g.List(Id(VarName(e.PluralName())), Id("orders"), Err()).Op(":=").Id("r").Dot(MemoryFindFunName(e)).Call(
+				MemoryMatchFunc(e, m),
+				Id("filter"),
+				Id("orderBy"),
+			)
+			g.If(Err().Op("!=").Nil()).Block(
+				Return(Nil(), Err()),
+			)
+			g.Return(Id(MemoryPageEntitiesFunName(e)).Call(Id(VarName(e.PluralName())), Id("orders"), Id("page")))
+
+		case "findByIDs":
+			plural := VarName(e.PluralName())
+			g.Id("r").Dot("mu").Dot("RLock").Call()
+			g.Defer().Id("r").Dot("mu").Dot("RUnlock").Call()
+			g.Id(plural).Op(":=").Index().Op("*").Id(e.Name).Values()
+			g.For(List(Id("_"), Id("id")).Op(":=").Range().Id("ids")).Block(
+				If(
+					List(Id(e.VarName()), Id("ok")).Op(":=").Id("r").Dot("data").Dot(e.PluralName()).Index(Id("id")),
+					Id("ok"),
+				).Block(
+					Id(plural).Op("=").Append(Id(plural), Id(MemoryCopyFunName(e)).Call(Id(e.VarName()))),
+				),
+			)
+			g.Return(Id(plural), Nil())
+
+		case "findListByRelationIDs":
+			AddMemoryFindListByRelationIDsBody(e, m.Relation, g)
+
+		case "count":
+			g.List(Id(VarName(e.PluralName())), Id("_"), Err()).Op(":=").Id("r").Dot(MemoryFindFunName(e)).Call(
+				Nil(),
+				Id("filter"),
+				Nil(),
+			)
+			g.Return(Len(Id(VarName(e.PluralName()))), Err())
+
+		case "aggregate":
+			AddMemoryAggregateBody(e, g)
+
+		case "search":
+			AddMemorySearchBody(e, g)
+		}
+	})
+}
+
+// MemoryMatchFunc returns the condition that instances must satisfy to
+// be returned by the given list method, or nil when all of them are
+func MemoryMatchFunc(e *Entity, m *RepositoryMethod) *Statement {
+	var value *Statement
+	switch {
+	case m.Attribute != nil:
+		value = Id(e.VarName()).Dot(m.Attribute.Name).Op("==").Id(m.Attribute.VarName())
+	case m.Relation != nil:
+		value = Id(e.VarName()).Dot(m.Relation.Alias()).Dot("ID").Op("==").Id(m.Relation.VarName())
+	default:
+		return Nil()
+	}
+
+	return Func().Params(Id(e.VarName()).Op("*").Id(e.Name)).Bool().Block(
+		Return(value),
+	)
+}
+
+// AddMemoryManyBody produces the body of a bulk method, that applies
+// the given method to every instance in turn
+func AddMemoryManyBody(e *Entity, fun string, g *Group) {
+	plural := VarName(e.PluralName())
+
+	g.Defer().Id("r").Dot("write").Call().Call()
+	g.For(List(Id("_"), Id(e.VarName())).Op(":=").Range().Id(plural)).BlockFunc(func(g2 *Group) {
+		g2.If(Err().Op(":=").Id("r").Dot(fun).Call(Id(e.VarName())), Err().Op("!=").Nil()).Block(
+			Return(Id(plural), Err()),
+		)
+	})
+	g.Return(Id(plural), Nil())
+}
+
+// AddMemoryPatchBody produces the body of the patch method, which
+// applies the patch to a copy of the stored instance, and replaces it
+func AddMemoryPatchBody(e *Entity, g *Group) {
+	g.Defer().Id("r").Dot("write").Call().Call()
+
+	g.List(Id("stored"), Id("ok")).Op(":=").Id("r").Dot("data").Dot(e.PluralName()).Index(Id("id"))
+	g.If(Op("!").Id("ok")).Block(
+		EmptyStructForEntity(e),
+		Return(Id(e.VarName()), MemoryError("CodeNotFound", e, "")),
+	)
+
+	g.Id(e.VarName()).Op(":=").Id("patch").Dot("Apply").Call(Id(MemoryCopyFunName(e)).Call(Id("stored")))
+	g.Return(Id(e.VarName()), Id("r").Dot("update"+e.Name).Call(Id(e.VarName())))
+}
+
+// AddMemoryUpsertBody produces the body of the upsert method, which
+// updates the instance with the same upsert key, if any, and inserts
+// the given one otherwise
+func AddMemoryUpsertBody(e *Entity, g *Group) {
+	v := e.VarName()
+	key := e.UpsertAttribute()
+
+	g.Defer().Id("r").Dot("write").Call().Call()
+
+	if key.Name == "ID" {
+		g.If(
+			List(Id("_"), Id("ok")).Op(":=").Id("r").Dot("data").Dot(e.PluralName()).Index(Id(v).Dot("ID")),
+			Id("ok"),
+		).Block(
+			Return(Id(v), Id("r").Dot("update"+e.Name).Call(Id(v))),
+		)
+	} else {
+		g.For(List(Id("_"), Id("existing")).Op(":=").Range().Id("r").Dot("data").Dot(e.PluralName())).Block(
+			If(Id("existing").Dot(key.Name).Op("==").Id(v).Dot(key.Name)).Block(
+				Id(v).Dot("ID").Op("=").Id("existing").Dot("ID"),
+				Return(Id(v), Id("r").Dot("update"+e.Name).Call(Id(v))),
+			),
+		)
+	}
+
+	g.Return(Id(v), Id("r").Dot("insert"+e.Name).Call(Id(v)))
+}
+
+// AddMemoryFindByAttributeBody produces the body of the method that
+// finds an instance of the given entity by a unique attribute
+func AddMemoryFindByAttributeBody(e *Entity, a *Attribute, g *Group) {
+	v := e.VarName()
+
+	g.Id("r").Dot("mu").Dot("RLock").Call()
+	g.Defer().Id("r").Dot("mu").Dot("RUnlock").Call()
+
+	if a.Name == "ID" {
+		g.If(
+			List(Id(v), Id("ok")).Op(":=").Id("r").Dot("data").Dot(e.PluralName()).Index(Id(a.VarName())),
+			Id("ok"),
+		).Block(
+			Return(Id(MemoryCopyFunName(e)).Call(Id(v)), Nil()),
+		)
+	} else {
+		g.For(List(Id("_"), Id(v)).Op(":=").Range().Id("r").Dot("data").Dot(e.PluralName())).Block(
+			If(Id(v).Dot(a.Name).Op("==").Id(a.VarName())).Block(
+				Return(Id(MemoryCopyFunName(e)).Call(Id(v)), Nil()),
+			),
+		)
+	}
+
+	g.Add(EmptyStructForEntity(e))
+	g.Return(Id(v), MemoryError("CodeNotFound", e, ""))
+}
+
+// AddMemoryFindListBody produces the body of a method that finds a
+// sorted and paginated list of instances of the given entity, that
+// satisfy the given condition
+func AddMemoryFindListBody(e *Entity, match *Statement, g *Group) {
+	plural := VarName(e.PluralName())
+
+	g.List(Id(plural), Id("_"), Err()).Op(":=").Id("r").Dot(MemoryFindFunName(e)).Call(
+		match,
+		Id("filter"),
+		Id("orderBy"),
+	)
+	g.If(Err().Op("!=").Nil()).Block(
+		Return(Id(plural), Err()),
+	)
+
+	g.List(Id("start"), Id("end")).Op(":=").Id("MemoryPage").Call(Len(Id(plural)), Id("limit"), Id("offset"))
+	g.Return(Id(plural).Index(Id("start").Op(":").Id("end")), Nil())
+}
+
+// AddMemoryFindListByRelationIDsBody produces the body of the method
+// that finds the instances of the given entity related to any of a
+// list of ids, paginated for every id separately, and sorted by id
+func AddMemoryFindListByRelationIDsBody(e *Entity, r *Relation, g *Group) {
+	plural := VarName(e.PluralName())
+
+	g.Id(plural).Op(":=").Index().Op("*").Id(e.Name).Values()
+	g.Id("sorted").Op(":=").Append(Index().String().Values(), Id("ids").Op("..."))
+	g.Qual("sort", "Strings").Call(Id("sorted"))
+	g.For(List(Id("i"), Id("id")).Op(":=").Range().Id("sorted")).Block(
+		If(Id("i").Op(">").Lit(0).Op("&&").Id("id").Op("==").Id("sorted").Index(Id("i").Op("-").Lit(1))).Block(
+			Continue(),
+		),
+		List(Id("related"), Id("_"), Err()).Op(":=").Id("r").Dot(MemoryFindFunName(e)).Call(
+			Func().Params(Id(e.VarName()).Op("*").Id(e.Name)).Bool().Block(
+				Return(Id(e.VarName()).Dot(r.Alias()).Dot("ID").Op("==").Id("id")),
+			),
+			Id("filter"),
+			Id("orderBy"),
+		),
+		If(Err().Op("!=").Nil()).Block(
+			Return(Id(plural), Err()),
+		),
+		List(Id("start"), Id("end")).Op(":=").Id("MemoryPage").Call(Len(Id("related")), Id("limit"), Id("offset")),
+		Id(plural).Op("=").Append(Id(plural), Id("related").Index(Id("start").Op(":").Id("end")).Op("...")),
+	)
+	g.Return(Id(plural), Nil())
+}
+
+// NewAggregateForEntity returns the code that builds an empty
+// aggregate of the given entity
+func NewAggregateForEntity(e *Entity) *Statement {
+	return Op("&").Id(EntityAggregateName(e)).Values(DictFunc(func(d Dict) {
+		d[Id("Group")] = Op("&").Id(EntityGroupName(e)).Values()
+		for _, fun := range AggregateFunctions() {
+			d[Id(fun[0])] = Op("&").Id(EntityAggregateValuesName(e)).Values()
+		}
+	}))
+}
+
+// AddMemoryAggregateBody produces the body of the method that computes
+// aggregates of instances of the given entity, grouped by the given
+// fields. Groups are sorted by their values, like the database does
+func AddMemoryAggregateBody(e *Entity, g *Group) {
+	plural := VarName(e.PluralName())
+	v := e.VarName()
+
+	g.Id("aggregates").Op(":=").Index().Op("*").Id(EntityAggregateName(e)).Values()
+	g.Id("orders").Op(":=").Index().Op("*").Id("SortOrder").Values()
+	g.For(List(Id("_"), Id("field")).Op(":=").Range().Id("groupBy")).Block(
+		If(
+			List(Id("_"), Id("ok")).Op(":=").Id(EntityGroupColumnsName(e)).Index(Id("field")),
+			Op("!").Id("ok"),
+		).Block(
+			Return(Id("aggregates"), ValidationError("groupBy", Qual("fmt", "Sprintf").Call(Lit("Unknown group field %s"), Id("field")))),
+		),
+		Id("orders").Op("=").Append(Id("orders"), Op("&").Id("SortOrder").Values(Dict{
+			Id("Field"):     Id("field"),
+			Id("Direction"): Lit(SortDirections()[0]),
+		})),
+	)
+
+	g.List(Id(plural), Id("_"), Err()).Op(":=").Id("r").Dot(MemoryFindFunName(e)).Call(Nil(), Id("filter"), Nil())
+	g.If(Err().Op("!=").Nil()).Block(
+		Return(Id("aggregates"), Err()),
+	)
+
+	g.Id("groups").Op(":=").Map(String()).Op("*").Id(EntityAggregateName(e)).Values()
+	g.Id("values").Op(":=").Map(Op("*").Id(EntityAggregateName(e))).Index().Interface().Values()
+	g.For(List(Id("_"), Id(v)).Op(":=").Range().Id(plural)).BlockFunc(func(g2 *Group) {
+		g2.Id("a").Op(":=").Add(NewAggregateForEntity(e))
+		g2.Id("group").Op(":=").Index().Interface().Values()
+		g2.For(List(Id("_"), Id("field")).Op(":=").Range().Id("groupBy")).Block(
+			Switch(Id("field")).BlockFunc(func(g3 *Group) {
+				for _, gf := range EntityGroupFields(e) {
+					value := Id(v).Dot(gf.Field)
+					for _, r := range e.Relations {
+						if r.Alias() == gf.Field {
+							value = Id(v).Dot(gf.Field).Dot("ID")
+						}
+					}
+
+					g3.Case(Lit(gf.Name)).Block(
+						Id("value").Op(":=").Add(value),
+						Id("a").Dot("Group").Dot(gf.Field).Op("=").Op("&").Id("value"),
+						Id("group").Op("=").Append(Id("group"), Id("value")),
+					)
+				}
+			}),
+		)
+
+		g2.List(Id("key"), Id("_")).Op(":=").Qual("encoding/json", "Marshal").Call(Id("group"))
+		g2.If(
+			List(Id("existing"), Id("ok")).Op(":=").Id("groups").Index(String().Call(Id("key"))),
+			Id("ok"),
+		).Block(
+			Id("a").Op("=").Id("existing"),
+		).Else().Block(
+			Id("groups").Index(String().Call(Id("key"))).Op("=").Id("a"),
+			Id("values").Index(Id("a")).Op("=").Id("group"),
+			Id("aggregates").Op("=").Append(Id("aggregates"), Id("a")),
+		)
+
+		g2.Id("a").Dot("Count").Op("++")
+		for _, fun := range AggregateFunctions() {
+			for _, a := range AggregateAttributes(e) {
+				g2.Id("a").Dot(fun[0]).Dot(a.Name).Op("=").Id("MemoryAggregate").Call(
+					Lit(fun[0]),
+					Id("a").Dot(fun[0]).Dot(a.Name),
+					Float64().Call(Id(v).Dot(a.Name)),
+				)
+			}
+		}
+	})
+
+	// without groups, there is a single aggregate, even over no rows
+	g.If(Len(Id("groupBy")).Op("==").Lit(0).Op("&&").Len(Id("aggregates")).Op("==").Lit(0)).Block(
+		Id("aggregates").Op("=").Append(Id("aggregates"), NewAggregateForEntity(e)),
+	)
+
+	if len(AggregateAttributes(e)) > 0 {
+		g.For(List(Id("_"), Id("a")).Op(":=").Range().Id("aggregates")).BlockFunc(func(g2 *Group) {
+			for _, a := range AggregateAttributes(e) {
+				g2.If(Id("a").Dot("Avg").Dot(a.Name).Op("!=").Nil()).Block(
+					Id("avg").Op(":=").Op("*").Id("a").Dot("Avg").Dot(a.Name).Op("/").Float64().Call(Id("a").Dot("Count")),
+					Id("a").Dot("Avg").Dot(a.Name).Op("=").Op("&").Id("avg"),
+				)
+			}
+		})
+	}
+
+	g.Qual("sort", "Slice").Call(Id("aggregates"), Func().Params(Id("i"), Id("j").Int()).Bool().Block(
+		Return(Id("MemoryCompare").Call(
+			Id("orders"),
+			Id("values").Index(Id("aggregates").Index(Id("i"))),
+			Id("values").Index(Id("aggregates").Index(Id("j"))),
+		).Op("<").Lit(0)),
+	))
+	g.Return(Id("aggregates"), Nil())
+}
+
+// AddMemorySearchBody produces the body of the method that searches
+// instances of the given entity. Instances are ranked by the number of
+// occurrences of the words of the query in their searchable
+// attributes, and then sorted by id
+func AddMemorySearchBody(e *Entity, g *Group) {
+	plural := VarName(e.PluralName())
+	v := e.VarName()
+
+	text := Empty()
+	for i, a := range SearchableAttributes(e) {
+		if i > 0 {
+			text = text.Op("+").Lit(" ").Op("+")
+		}
+		text = text.Id(v).Dot(a.Name)
+	}
+
+	g.List(Id(plural), Id("_"), Err()).Op(":=").Id("r").Dot(MemoryFindFunName(e)).Call(
+		Nil(),
+		Nil(),
+		Index().Op("*").Id("SortOrder").Values(Op("&").Id("SortOrder").Values(Dict{
+			Id("Field"): Lit(AttributeSortField(&Attribute{Name: "ID"})),
+		})),
+	)
+	g.If(Err().Op("!=").Nil()).Block(
+		Return(Id(plural), Err()),
+	)
+
+	g.Id("matched").Op(":=").Index().Op("*").Id(e.Name).Values()
+	g.Id("ranks").Op(":=").Map(String()).Int().Values()
+	g.For(List(Id("_"), Id(v)).Op(":=").Range().Id(plural)).Block(
+		If(
+			Id("rank").Op(":=").Id("MemorySearchRank").Call(text, Id("query")),
+			Id("rank").Op(">").Lit(0),
+		).Block(
+			Id("ranks").Index(Id(v).Dot("ID")).Op("=").Id("rank"),
+			Id("matched").Op("=").Append(Id("matched"), Id(v)),
+		),
+	)
+
+	g.Qual("sort", "SliceStable").Call(Id("matched"), Func().Params(Id("i"), Id("j").Int()).Bool().Block(
+		Return(Id("ranks").Index(Id("matched").Index(Id("i")).Dot("ID")).Op(">").Id("ranks").Index(Id("matched").Index(Id("j")).Dot("ID"))),
+	))
+
+	g.List(Id("start"), Id("end")).Op(":=").Id("MemoryPage").Call(Len(Id("matched")), Id("limit"), Id("offset"))
+	g.Return(Id("matched").Index(Id("start").Op(":").Id("end")), Nil())
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	. "github.com/dave/jennifer/jen"
+	"github.com/iancoleman/strcase"
 )
 
 // CreateModel generates a Golang file that contains all the necessary
@@ -16,6 +17,7 @@ func CreateModel(p *Package) error {
 	f.PackageComment("This file contains all the functions that implement the model")
 	f.PackageComment(" ** THIS CODE IS MACHINE GENERATED. DO NOT EDIT MANUALLY ** ")
 
+	AddModelEnums(p.Model.Types, f)
 	AddModelStructs(p.Model.Entities, f)
 
 	return f.Save(p.Filename)
@@ -32,25 +34,102 @@ func AddModelStructs(entities []*Entity, f *File) {
 // AddModelStruct is a helper function that generates the Golang struct
 // that represents the model for the given entity
 func AddModelStruct(f *File, e *Entity) {
+	AddDescriptionComment(e.Description, f.Group)
 	f.Type().Id(e.Name).StructFunc(func(g *Group) {
 
 		// Add a struct field for each entity attribute
 		for _, a := range e.Attributes {
+			AddDescriptionComment(a.Description, g)
 			TypedFromAttribute(g.Id(a.Name), a)
 		}
 
 		// Add a struct field for each relation. We we built a pointer
 		// type for each entity we point at
 		for _, r := range e.Relations {
+			AddDescriptionComment(r.Description, g)
 			g.Id(r.Alias()).Op("*").Id(r.Entity)
 		}
 	})
 }
 
+// AddDescriptionComment adds the given description, from the model, as
+// a comment with one line per line of the description
+func AddDescriptionComment(desc string, g *Group) {
+	desc = strings.TrimSpace(desc)
+	if desc == "" {
+		return
+	}
+
+	for _, line := range strings.Split(desc, "\n") {
+		g.Comment(line)
+	}
+}
+
+// AddModelEnums generates a Golang type for each enum of the model,
+// along with a constant for each of its values
+func AddModelEnums(types []*UDType, f *File) {
+	for _, t := range types {
+		if t.IsEnum() {
+			AddModelEnum(t, f)
+		}
+	}
+}
+
+// AddModelEnum generates the Golang type of the given enum, the
+// constants of its values, and the methods that validate and parse
+// them
+func AddModelEnum(t *UDType, f *File) {
+	if t.Description != "" {
+		AddDescriptionComment(t.Description, f.Group)
+	} else {
+		f.Comment(fmt.Sprintf("%s is an enum of the model", t.Name))
+	}
+	f.Type().Id(t.Name).String()
+
+	f.Const().DefsFunc(func(g *Group) {
+		for _, v := range t.Values {
+			AddDescriptionComment(t.ValueDescription(v), g)
+			g.Id(EnumValueName(t, v)).Id(t.Name).Op("=").Lit(v)
+		}
+	})
+
+	f.Comment(fmt.Sprintf("Valid returns whether the value is one of the values of %s", t.Name))
+	f.Func().Parens(Id("v").Id(t.Name)).Id("Valid").Params().Bool().Block(
+		Switch(Id("v")).Block(
+			Case(ListFunc(func(g *Group) {
+				for _, v := range t.Values {
+					g.Id(EnumValueName(t, v))
+				}
+			})).Block(
+				Return(True()),
+			),
+		),
+
+		Return(False()),
+	)
+
+	funName := fmt.Sprintf("Parse%s", t.Name)
+	f.Comment(fmt.Sprintf("%s converts the given string into a %s, or fails if it is not one of its values", funName, t.Name))
+	f.Func().Id(funName).Params(Id("s").String()).Parens(List(Id(t.Name), Error())).Block(
+		Id("v").Op(":=").Id(t.Name).Call(Id("s")),
+		If(Op("!").Id("v").Dot("Valid").Call()).Block(
+			Return(Lit(""), Qual("fmt", "Errorf").Call(Lit(fmt.Sprintf("invalid %s %%q", t.Name)), Id("s"))),
+		),
+
+		Return(Id("v"), Nil()),
+	)
+}
+
+// EnumValueName returns the name of the constant that holds the given
+// value of the given enum
+func EnumValueName(t *UDType, v string) string {
+	return fmt.Sprintf("%s%s", t.Name, strcase.ToCamel(v))
+}
+
 // TypedFromAttribute appends the appropiate Golang type to the given
 // statement, according to the type of the given attribute
 func TypedFromAttribute(s *Statement, a *Attribute) *Statement {
-	return TypedFromDataType(s, AttributeDatatype(a))
+	return s.Add(TypeFromAttribute(a))
 }
 
 // TypedFromDataType appends the appropiate Golang type to the given
@@ -63,6 +142,10 @@ func TypedFromDataType(s *Statement, dataType string) *Statement {
 // TypeFromAttribute returns the Golang type statement for the given
 // attribute
 func TypeFromAttribute(a *Attribute) *Statement {
+	if a.Enum() != nil {
+		return Id(a.Enum().Name)
+	}
+
 	return TypeFromDataType(AttributeDatatype(a))
 }
 
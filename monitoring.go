@@ -26,10 +26,21 @@ func AddMetrics(p *Package, f *File) {
 			DefineMetricsForCreateMutation(e, vars)
 			DefineMetricsForUpdateMutation(e, vars)
 			DefineMetricsForDeleteMutation(e, vars)
+			DefineMetricsForBulkMutations(e, vars)
+
+			if e.SupportsOperation("upsert") {
+				DefineMetricsForUpsertMutation(e, vars)
+			}
+
+			if SupportsPatch(e) {
+				DefineMetricsForPatchMutation(e, vars)
+			}
 
 			for _, a := range e.Attributes {
 				if a.HasModifier("indexed") && a.HasModifier("unique") {
 					DefineMetricsForFinderByAttribute(e, a, vars)
+				} else if a.HasModifier("indexed") {
+					DefineMetricsForListFinderByAttribute(e, a, vars)
 				}
 			}
 
@@ -40,6 +51,11 @@ func AddMetrics(p *Package, f *File) {
 			}
 
 			DefineMetricsForFinderForAll(e, vars)
+			DefineMetricsForAggregateQueries(e, vars)
+
+			if SupportsSearch(e) {
+				DefineMetricsForSearchQuery(e, vars)
+			}
 
 		}
 	})
@@ -58,10 +74,21 @@ func AddMetricsRegistrations(p *Package, f *File) {
 			RegisterMetricsForCreateMutation(e, g)
 			RegisterMetricsForUpdateMutation(e, g)
 			RegisterMetricsForDeleteMutation(e, g)
+			RegisterMetricsForBulkMutations(e, g)
+
+			if e.SupportsOperation("upsert") {
+				RegisterMetricsForUpsertMutation(e, g)
+			}
+
+			if SupportsPatch(e) {
+				RegisterMetricsForPatchMutation(e, g)
+			}
 
 			for _, a := range e.Attributes {
 				if a.HasModifier("indexed") && a.HasModifier("unique") {
 					RegisterMetricsForFinderByAttribute(e, a, g)
+				} else if a.HasModifier("indexed") {
+					RegisterMetricsForListFinderByAttribute(e, a, g)
 				}
 			}
 
@@ -72,6 +99,11 @@ func AddMetricsRegistrations(p *Package, f *File) {
 			}
 
 			RegisterMetricsForFinderForAll(e, g)
+			RegisterMetricsForAggregateQueries(e, g)
+
+			if SupportsSearch(e) {
+				RegisterMetricsForSearchQuery(e, g)
+			}
 
 		}
 
@@ -162,6 +194,93 @@ func RegisterMetricsForDeleteMutation(e *Entity, g *Group) {
 	RegisterMetric(DeleteMutationErrorCounterName(e), g)
 }
 
+// DefineMetricsForUpsertMutation defines the histograms and counters
+// that will hold metrics when upserting instances of the given entity
+func DefineMetricsForUpsertMutation(e *Entity, vars *Group) {
+
+	// an histogram, to track latencies
+	vars.Id(UpsertMutationHistogramName(e)).Op("=").Add(
+		HistogramDefinition(
+			UpsertMutationHistogramName(e),
+			UpsertMutationHistogramHelp(e),
+		),
+	)
+
+	// a counter, to track errors
+	vars.Id(UpsertMutationErrorCounterName(e)).Op("=").Add(
+		CounterDefinition(
+			UpsertMutationErrorCounterName(e),
+			UpsertMutationErrorCounterHelp(e),
+		),
+	)
+}
+
+// RegisterMetricsForUpsertMutation registers the histograms and counters
+// that will hold metrics when upserting instances of the given entity
+func RegisterMetricsForUpsertMutation(e *Entity, g *Group) {
+	RegisterMetric(UpsertMutationHistogramName(e), g)
+	RegisterMetric(UpsertMutationErrorCounterName(e), g)
+}
+
+// DefineMetricsForBulkMutations defines the histograms and counters
+// that will hold metrics when creating, updating or deleting lists of
+// instances of the given entity
+func DefineMetricsForBulkMutations(e *Entity, vars *Group) {
+
+	vars.Id(CreateManyMutationHistogramName(e)).Op("=").Add(
+		HistogramDefinition(
+			CreateManyMutationHistogramName(e),
+			CreateManyMutationHistogramHelp(e),
+		),
+	)
+
+	vars.Id(CreateManyMutationErrorCounterName(e)).Op("=").Add(
+		CounterDefinition(
+			CreateManyMutationErrorCounterName(e),
+			CreateManyMutationErrorCounterHelp(e),
+		),
+	)
+
+	vars.Id(UpdateManyMutationHistogramName(e)).Op("=").Add(
+		HistogramDefinition(
+			UpdateManyMutationHistogramName(e),
+			UpdateManyMutationHistogramHelp(e),
+		),
+	)
+
+	vars.Id(UpdateManyMutationErrorCounterName(e)).Op("=").Add(
+		CounterDefinition(
+			UpdateManyMutationErrorCounterName(e),
+			UpdateManyMutationErrorCounterHelp(e),
+		),
+	)
+
+	vars.Id(DeleteManyMutationHistogramName(e)).Op("=").Add(
+		HistogramDefinition(
+			DeleteManyMutationHistogramName(e),
+			DeleteManyMutationHistogramHelp(e),
+		),
+	)
+
+	vars.Id(DeleteManyMutationErrorCounterName(e)).Op("=").Add(
+		CounterDefinition(
+			DeleteManyMutationErrorCounterName(e),
+			DeleteManyMutationErrorCounterHelp(e),
+		),
+	)
+}
+
+// RegisterMetricsForBulkMutations registers the histograms and counters
+// that will hold metrics for bulk mutations of the given entity
+func RegisterMetricsForBulkMutations(e *Entity, g *Group) {
+	RegisterMetric(CreateManyMutationHistogramName(e), g)
+	RegisterMetric(CreateManyMutationErrorCounterName(e), g)
+	RegisterMetric(UpdateManyMutationHistogramName(e), g)
+	RegisterMetric(UpdateManyMutationErrorCounterName(e), g)
+	RegisterMetric(DeleteManyMutationHistogramName(e), g)
+	RegisterMetric(DeleteManyMutationErrorCounterName(e), g)
+}
+
 // DefineMetricsForFinderByAttribute defines the histograms and counters
 // that will hold metrics when finding instances of the given entity by
 // the given attribute
@@ -215,6 +334,28 @@ func DefineMetricsForFinderByRelation(e *Entity, r *Relation, vars *Group) {
 	)
 }
 
+// DefineMetricsForListFinderByAttribute defines the histograms and
+// counters that will hold metrics when finding lists of instances of
+// the given entity by the given attribute
+func DefineMetricsForListFinderByAttribute(e *Entity, a *Attribute, vars *Group) {
+
+	// an histogram, to track latencies
+	vars.Id(FindListByAttributeQueryHistogramName(e, a)).Op("=").Add(
+		HistogramDefinition(
+			FindListByAttributeQueryHistogramName(e, a),
+			fmt.Sprintf("Elapsed time in milliseconds to find lists of entities of type %s by %s", e.Name, a.Name),
+		),
+	)
+
+	// a counter, to track errors
+	vars.Id(FindListByAttributeQueryErrorCounterName(e, a)).Op("=").Add(
+		CounterDefinition(
+			FindListByAttributeQueryErrorCounterName(e, a),
+			fmt.Sprintf("Errors when finding lists of entities of type %s by %s", e.Name, a.Name),
+		),
+	)
+}
+
 // DefineMetricsForFinderForAll defines the histograms and counters
 // that will hold metrics when find all instances of the given
 // entity
@@ -246,6 +387,14 @@ func RegisterMetricsForFinderByRelation(e *Entity, r *Relation, g *Group) {
 	RegisterMetric(FindByRelationQueryErrorCounterName(e, r), g)
 }
 
+// RegisterMetricsForListFinderByAttribute registers the histograms and
+// counters that will hold metrics when finding lists of instances of
+// the given entity by the given attribute
+func RegisterMetricsForListFinderByAttribute(e *Entity, a *Attribute, g *Group) {
+	RegisterMetric(FindListByAttributeQueryHistogramName(e, a), g)
+	RegisterMetric(FindListByAttributeQueryErrorCounterName(e, a), g)
+}
+
 // RegisterMetricsForFinderForAll registers the histograms and counters
 // that will hold metrics when findind all instances of a given
 // entity
@@ -254,6 +403,176 @@ func RegisterMetricsForFinderForAll(e *Entity, g *Group) {
 	RegisterMetric(FindAllQueryErrorCounterName(e), g)
 }
 
+// DefineMetricsForAggregateQueries defines the histograms and counters
+// that will hold metrics when counting and aggregating instances of
+// the given entity
+func DefineMetricsForAggregateQueries(e *Entity, vars *Group) {
+	vars.Id(CountQueryHistogramName(e)).Op("=").Add(
+		HistogramDefinition(
+			CountQueryHistogramName(e),
+			fmt.Sprintf("Elapsed time in milliseconds to count entities of type %s", e.Name),
+		),
+	)
+
+	vars.Id(CountQueryErrorCounterName(e)).Op("=").Add(
+		CounterDefinition(
+			CountQueryErrorCounterName(e),
+			fmt.Sprintf("Errors when counting entities of type %s", e.Name),
+		),
+	)
+
+	vars.Id(AggregateQueryHistogramName(e)).Op("=").Add(
+		HistogramDefinition(
+			AggregateQueryHistogramName(e),
+			fmt.Sprintf("Elapsed time in milliseconds to aggregate entities of type %s", e.Name),
+		),
+	)
+
+	vars.Id(AggregateQueryErrorCounterName(e)).Op("=").Add(
+		CounterDefinition(
+			AggregateQueryErrorCounterName(e),
+			fmt.Sprintf("Errors when aggregating entities of type %s", e.Name),
+		),
+	)
+}
+
+// RegisterMetricsForAggregateQueries registers the histograms and
+// counters that will hold metrics when counting and aggregating
+// instances of the given entity
+func RegisterMetricsForAggregateQueries(e *Entity, g *Group) {
+	RegisterMetric(CountQueryHistogramName(e), g)
+	RegisterMetric(CountQueryErrorCounterName(e), g)
+	RegisterMetric(AggregateQueryHistogramName(e), g)
+	RegisterMetric(AggregateQueryErrorCounterName(e), g)
+}
+
+// DefineMetricsForPatchMutation defines the histograms and counters
+// that will hold metrics when patching instances of the given entity
+func DefineMetricsForPatchMutation(e *Entity, vars *Group) {
+	vars.Id(PatchMutationHistogramName(e)).Op("=").Add(
+		HistogramDefinition(
+			PatchMutationHistogramName(e),
+			fmt.Sprintf("Elapsed time in milliseconds to patch entities of type %s", e.Name),
+		),
+	)
+
+	vars.Id(PatchMutationErrorCounterName(e)).Op("=").Add(
+		CounterDefinition(
+			PatchMutationErrorCounterName(e),
+			fmt.Sprintf("Errors when patching entities of type %s", e.Name),
+		),
+	)
+}
+
+// RegisterMetricsForPatchMutation registers the histograms and counters
+// that will hold metrics when patching instances of the given entity
+func RegisterMetricsForPatchMutation(e *Entity, g *Group) {
+	RegisterMetric(PatchMutationHistogramName(e), g)
+	RegisterMetric(PatchMutationErrorCounterName(e), g)
+}
+
+// PatchMutationHistogramName returns the variable name of the metric
+// that observes latencies for the patch mutation for the given entity
+func PatchMutationHistogramName(e *Entity) string {
+	return strcase.ToSnake(fmt.Sprintf("%sLatencies", GraphqlPatchMutationName(e)))
+}
+
+// PatchMutationErrorCounterName returns the variable name of the
+// metric that counts errors for the patch mutation for the given entity
+func PatchMutationErrorCounterName(e *Entity) string {
+	return strcase.ToSnake(fmt.Sprintf("%sErrors", GraphqlPatchMutationName(e)))
+}
+
+// DefineMetricsForSearchQuery defines the histograms and counters that
+// will hold metrics when searching instances of the given entity
+func DefineMetricsForSearchQuery(e *Entity, vars *Group) {
+	vars.Id(SearchQueryHistogramName(e)).Op("=").Add(
+		HistogramDefinition(
+			SearchQueryHistogramName(e),
+			fmt.Sprintf("Elapsed time in milliseconds to search entities of type %s", e.Name),
+		),
+	)
+
+	vars.Id(SearchQueryErrorCounterName(e)).Op("=").Add(
+		CounterDefinition(
+			SearchQueryErrorCounterName(e),
+			fmt.Sprintf("Errors when searching entities of type %s", e.Name),
+		),
+	)
+}
+
+// RegisterMetricsForSearchQuery registers the histograms and counters
+// that will hold metrics when searching instances of the given entity
+func RegisterMetricsForSearchQuery(e *Entity, g *Group) {
+	RegisterMetric(SearchQueryHistogramName(e), g)
+	RegisterMetric(SearchQueryErrorCounterName(e), g)
+}
+
+// SearchQueryHistogramName returns the variable name of the metric that
+// observes latencies for the query that searches instances of the
+// given entity
+func SearchQueryHistogramName(e *Entity) string {
+	return strcase.ToSnake(fmt.Sprintf("%sLatencies", GraphqlSearchQueryName(e)))
+}
+
+// SearchQueryErrorCounterName returns the variable name of the metric
+// that counts errors for the query that searches instances of the given
+// entity
+func SearchQueryErrorCounterName(e *Entity) string {
+	return strcase.ToSnake(fmt.Sprintf("%sErrors", GraphqlSearchQueryName(e)))
+}
+
+// CountQueryHistogramName returns the variable name of the metric that
+// observes latencies for the query that counts instances of the given
+// entity
+func CountQueryHistogramName(e *Entity) string {
+	return strcase.ToSnake(fmt.Sprintf("%sLatencies", GraphqlCountQueryName(e)))
+}
+
+// CountQueryErrorCounterName returns the variable name of the metric
+// that counts errors for the query that counts instances of the given
+// entity
+func CountQueryErrorCounterName(e *Entity) string {
+	return strcase.ToSnake(fmt.Sprintf("%sErrors", GraphqlCountQueryName(e)))
+}
+
+// AggregateQueryHistogramName returns the variable name of the metric
+// that observes latencies for the query that aggregates instances of
+// the given entity
+func AggregateQueryHistogramName(e *Entity) string {
+	return strcase.ToSnake(fmt.Sprintf("%sLatencies", GraphqlAggregateQueryName(e)))
+}
+
+// AggregateQueryErrorCounterName returns the variable name of the
+// metric that counts errors for the query that aggregates instances of
+// the given entity
+func AggregateQueryErrorCounterName(e *Entity) string {
+	return strcase.ToSnake(fmt.Sprintf("%sErrors", GraphqlAggregateQueryName(e)))
+}
+
+// MutationErrorCounterName returns the name of the metric that counts
+// errors for the given mutation of the given entity
+func MutationErrorCounterName(e *Entity, mutation string) string {
+	switch mutation {
+	case "update":
+		return UpdateMutationErrorCounterName(e)
+	case "patch":
+		return PatchMutationErrorCounterName(e)
+	case "delete":
+		return DeleteMutationErrorCounterName(e)
+	case "upsert":
+		return UpsertMutationErrorCounterName(e)
+	case "createMany":
+		return CreateManyMutationErrorCounterName(e)
+	case "updateMany":
+		return UpdateManyMutationErrorCounterName(e)
+	case "deleteMany":
+		return DeleteManyMutationErrorCounterName(e)
+	default:
+		return CreateMutationErrorCounterName(e)
+	}
+}
+
 // CreateMutationHistogramName returns the variable name of the metric that
 // observes latencies for the create mutation for the given entity
 func CreateMutationHistogramName(e *Entity) string {
@@ -356,6 +675,145 @@ func DeleteMutationErrorCounterHelp(e *Entity) string {
 	return fmt.Sprintf("Errors when deleting entities of type %s", e.Name)
 }
 
+// UpsertMutationHistogramName returns the variable name of the metric that
+// observes latencies for the upsert mutation for the given entity
+func UpsertMutationHistogramName(e *Entity) string {
+	return strcase.ToSnake(
+		fmt.Sprintf("%s%s",
+			GraphqlUpsertMutationName(e),
+			"Latencies",
+		),
+	)
+}
+
+// UpsertMutationHistogramHelp returns the help for the metric that
+// keeps track of latencies for the upsert mutation for the given entity
+func UpsertMutationHistogramHelp(e *Entity) string {
+	return fmt.Sprintf("Elapsed time in milliseconds to upsert entities of type %s", e.Name)
+}
+
+// UpsertMutationErrorCounterName returns the name of the metric that
+// counts errors for the upsert mutation for the given entity
+func UpsertMutationErrorCounterName(e *Entity) string {
+	return strcase.ToSnake(
+		fmt.Sprintf("%s%s",
+			GraphqlUpsertMutationName(e),
+			"Errors",
+		),
+	)
+}
+
+// UpsertMutationErrorCounterHelp returns the help for the metric that
+// counts errors for the upsert mutation for the given entity
+func UpsertMutationErrorCounterHelp(e *Entity) string {
+	return fmt.Sprintf("Errors when upserting entities of type %s", e.Name)
+}
+
+// CreateManyMutationHistogramName returns the variable name of the metric that
+// observes latencies for the bulk create mutation for the given entity
+func CreateManyMutationHistogramName(e *Entity) string {
+	return strcase.ToSnake(
+		fmt.Sprintf("%s%s",
+			GraphqlCreateManyMutationName(e),
+			"Latencies",
+		),
+	)
+}
+
+// CreateManyMutationHistogramHelp returns the help for the metric that
+// keeps track of latencies for the bulk create mutation for the given entity
+func CreateManyMutationHistogramHelp(e *Entity) string {
+	return fmt.Sprintf("Elapsed time in milliseconds to create lists of entities of type %s", e.Name)
+}
+
+// UpdateManyMutationHistogramName returns the variable name of the metric that
+// observes latencies for the bulk update mutation for the given entity
+func UpdateManyMutationHistogramName(e *Entity) string {
+	return strcase.ToSnake(
+		fmt.Sprintf("%s%s",
+			GraphqlUpdateManyMutationName(e),
+			"Latencies",
+		),
+	)
+}
+
+// UpdateManyMutationHistogramHelp returns the help for the metric that
+// keeps track of latencies for the bulk update mutation for the given entity
+func UpdateManyMutationHistogramHelp(e *Entity) string {
+	return fmt.Sprintf("Elapsed time in milliseconds to update lists of entities of type %s", e.Name)
+}
+
+// DeleteManyMutationHistogramName returns the variable name of the metric that
+// observes latencies for the bulk delete mutation for the given entity
+func DeleteManyMutationHistogramName(e *Entity) string {
+	return strcase.ToSnake(
+		fmt.Sprintf("%s%s",
+			GraphqlDeleteManyMutationName(e),
+			"Latencies",
+		),
+	)
+}
+
+// DeleteManyMutationHistogramHelp returns the help for the metric that
+// keeps track of latencies for the bulk delete mutation for the given entity
+func DeleteManyMutationHistogramHelp(e *Entity) string {
+	return fmt.Sprintf("Elapsed time in milliseconds to delete lists of entities of type %s", e.Name)
+}
+
+// CreateManyMutationErrorCounterName returns the variable name of the
+// metric that counts errors for the bulk create mutation for the given
+// entity
+func CreateManyMutationErrorCounterName(e *Entity) string {
+	return strcase.ToSnake(
+		fmt.Sprintf("%s%s",
+			GraphqlCreateManyMutationName(e),
+			"Errors",
+		),
+	)
+}
+
+// CreateManyMutationErrorCounterHelp returns the help for the metric
+// that counts errors for the bulk create mutation for the given entity
+func CreateManyMutationErrorCounterHelp(e *Entity) string {
+	return fmt.Sprintf("Errors when creating lists of entities of type %s", e.Name)
+}
+
+// UpdateManyMutationErrorCounterName returns the variable name of the
+// metric that counts errors for the bulk update mutation for the given
+// entity
+func UpdateManyMutationErrorCounterName(e *Entity) string {
+	return strcase.ToSnake(
+		fmt.Sprintf("%s%s",
+			GraphqlUpdateManyMutationName(e),
+			"Errors",
+		),
+	)
+}
+
+// UpdateManyMutationErrorCounterHelp returns the help for the metric
+// that counts errors for the bulk update mutation for the given entity
+func UpdateManyMutationErrorCounterHelp(e *Entity) string {
+	return fmt.Sprintf("Errors when updating lists of entities of type %s", e.Name)
+}
+
+// DeleteManyMutationErrorCounterName returns the variable name of the
+// metric that counts errors for the bulk delete mutation for the given
+// entity
+func DeleteManyMutationErrorCounterName(e *Entity) string {
+	return strcase.ToSnake(
+		fmt.Sprintf("%s%s",
+			GraphqlDeleteManyMutationName(e),
+			"Errors",
+		),
+	)
+}
+
+// DeleteManyMutationErrorCounterHelp returns the help for the metric
+// that counts errors for the bulk delete mutation for the given entity
+func DeleteManyMutationErrorCounterHelp(e *Entity) string {
+	return fmt.Sprintf("Errors when deleting lists of entities of type %s", e.Name)
+}
+
 // FindByAttributeQueryHistogramName returns the variable name of the metric that
 // observes latencies for the finder query for the given entity by the
 // given attribute
@@ -392,6 +850,30 @@ func FindByAttributeQueryErrorCounterHelp(e *Entity, a *Attribute) string {
 	return fmt.Sprintf("Errors when finding entities of type %s by %s", e.Name, a.Name)
 }
 
+// FindListByAttributeQueryHistogramName returns the name of the metric
+// that keeps track of latencies for the query that finds lists of
+// instances of the given entity by the given attribute
+func FindListByAttributeQueryHistogramName(e *Entity, a *Attribute) string {
+	return strcase.ToSnake(
+		fmt.Sprintf("%s%s",
+			GraphqlFindListByAttributeQueryName(e, a),
+			"Latencies",
+		),
+	)
+}
+
+// FindListByAttributeQueryErrorCounterName returns the name of the
+// metric that counts errors for the query that finds lists of instances
+// of the given entity by the given attribute
+func FindListByAttributeQueryErrorCounterName(e *Entity, a *Attribute) string {
+	return strcase.ToSnake(
+		fmt.Sprintf("%s%s",
+			GraphqlFindListByAttributeQueryName(e, a),
+			"Errors",
+		),
+	)
+}
+
 // FindByRelationQueryHistogramName returns the variable name of the metric that
 // observes latencies for the finder query for the given entity by the
 // given relation
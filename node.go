@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+
+	. "github.com/dave/jennifer/jen"
+)
+
+// CreateNode generates a Golang file that contains the functions that
+// encode and decode Relay global IDs, and the resolvers of the Node
+// interface, if the model follows Relay conventions
+func CreateNode(p *Package) error {
+	f := NewFile(p.Name)
+
+	f.PackageComment(fmt.Sprintf("%s contains all the library code for the platform", p.Name))
+	f.PackageComment("This file contains the Relay global IDs and Node resolvers")
+	f.PackageComment(" ** THIS CODE IS MACHINE GENERATED. DO NOT EDIT MANUALLY ** ")
+
+	if p.Model.Relay {
+		AddGlobalIDFuns(f)
+	}
+
+	if p.Model.ImplementsNode() {
+		AddNodeResolver(p.Model, f)
+		AddNodeQueryResolverFuns(p.Model, f)
+	}
+
+	return f.Save(p.Filename)
+}
+
+// AddGlobalIDFuns generates the functions that encode the ID of an
+// instance into a global ID, qualified with the name of its type, and
+// decode it back
+func AddGlobalIDFuns(f *File) {
+	f.Comment("GlobalID returns the global ID of the instance of the given type with the given id")
+	f.Func().Id("GlobalID").Params(
+		Id("typ").String(),
+		Id("id").String(),
+	).Qual("github.com/graph-gophers/graphql-go", "ID").Block(
+		Return(Qual("github.com/graph-gophers/graphql-go", "ID").Call(
+			Qual("encoding/base64", "StdEncoding").Dot("EncodeToString").Call(
+				Index().Byte().Call(Id("typ").Op("+").Lit(":").Op("+").Id("id")),
+			),
+		)),
+	)
+
+	f.Comment("ParseGlobalID returns the type and the id held by the given global ID. Both are empty if it is not a global ID")
+	f.Func().Id("ParseGlobalID").Params(
+		Id("id").Qual("github.com/graph-gophers/graphql-go", "ID"),
+	).Parens(List(String(), String())).Block(
+		List(Id("data"), Err()).Op(":=").Qual("encoding/base64", "StdEncoding").Dot("DecodeString").Call(String().Call(Id("id"))),
+		If(Err().Op("!=").Nil()).Block(
+			Return(Lit(""), Lit("")),
+		),
+
+		Id("parts").Op(":=").Qual("strings", "SplitN").Call(String().Call(Id("data")), Lit(":"), Lit(2)),
+		If(Len(Id("parts")).Op("!=").Lit(2)).Block(
+			Return(Lit(""), Lit("")),
+		),
+
+		Return(Id("parts").Index(Lit(0)), Id("parts").Index(Lit(1))),
+	)
+
+	f.Comment("LocalID returns the id held by the given global ID, if it identifies an instance of the given type. Otherwise, it returns an empty id, which matches no instance")
+	f.Func().Id("LocalID").Params(
+		Id("typ").String(),
+		Id("id").Qual("github.com/graph-gophers/graphql-go", "ID"),
+	).String().Block(
+		List(Id("t"), Id("local")).Op(":=").Id("ParseGlobalID").Call(Id("id")),
+		If(Id("t").Op("!=").Id("typ")).Block(
+			Return(Lit("")),
+		),
+
+		Return(Id("local")),
+	)
+}
+
+// AddNodeResolver generates the resolver of the Node interface, which
+// wraps the resolver of any type that implements it
+func AddNodeResolver(m *Model, f *File) {
+	resolver := GraphqlResolverForType("Node")
+
+	f.Comment(fmt.Sprintf("%s resolves an instance of any type that implements Node", resolver))
+	f.Type().Id(resolver).Struct(
+		Id("Node").Interface(
+			Id("ID").Params(Qual("context", "Context")).Qual("github.com/graph-gophers/graphql-go", "ID"),
+		),
+	)
+
+	f.Comment("ID returns the global ID of the instance")
+	f.Func().Parens(Id("r").Op("*").Id(resolver)).Id("ID").Params(
+		Id("ctx").Qual("context", "Context"),
+	).Qual("github.com/graph-gophers/graphql-go", "ID").Block(
+		Return(Id("r").Dot("Node").Dot("ID").Call(Id("ctx"))),
+	)
+
+	for _, e := range m.Entities {
+		if !e.ImplementsNode() {
+			continue
+		}
+
+		res := GraphqlResolverForEntity(e)
+		funName := fmt.Sprintf("To%s", e.Name)
+
+		f.Comment(fmt.Sprintf("%s returns the resolver of the instance, if it is of type %s", funName, e.Name))
+		f.Func().Parens(Id("r").Op("*").Id(resolver)).Id(funName).Params().Parens(List(
+			Op("*").Id(res),
+			Bool(),
+		)).Block(
+			List(Id("res"), Id("ok")).Op(":=").Id("r").Dot("Node").Assert(Op("*").Id(res)),
+			Return(Id("res"), Id("ok")),
+		)
+	}
+}
+
+// AddNodeQueryResolverFuns generates the resolvers of the queries that
+// find instances of any type by global ID. Instances are loaded with
+// the loaders of the request, so that lookups of the same type are
+// batched
+func AddNodeQueryResolverFuns(m *Model, f *File) {
+	resolver := GraphqlResolverForType("Node")
+
+	ResolverFun(GraphqlNodeQuery(), func(g *Group) {
+		g.Return(Id("r").Dot("node").Call(Id("ctx"), Id("args").Dot("Id")))
+	}, f)
+
+	fun := GraphqlNodesQuery()
+	f.Func().Parens(Id("r").Op("*").Id("Resolver")).Id("Nodes").Params(
+		Id("ctx").Qual("context", "Context"),
+		GraphqlResolverArgs(fun),
+	).Parens(List(
+		Index().Op("*").Id(resolver),
+		Error(),
+	)).Block(
+		Id("nodes").Op(":=").Make(Index().Op("*").Id(resolver), Len(Id("args").Dot("Ids"))),
+		Id("errs").Op(":=").Make(Index().Error(), Len(Id("args").Dot("Ids"))),
+
+		// nodes are found concurrently, so that loaders batch them
+		Var().Id("wg").Qual("sync", "WaitGroup"),
+		For(List(Id("n"), Id("id")).Op(":=").Range().Id("args").Dot("Ids")).Block(
+			Id("wg").Dot("Add").Call(Lit(1)),
+			Go().Func().Params(
+				Id("n").Int(),
+				Id("id").Qual("github.com/graph-gophers/graphql-go", "ID"),
+			).Block(
+				Defer().Id("wg").Dot("Done").Call(),
+				List(Id("nodes").Index(Id("n")), Id("errs").Index(Id("n"))).Op("=").Id("r").Dot("node").Call(Id("ctx"), Id("id")),
+			).Call(Id("n"), Id("id")),
+		),
+		Id("wg").Dot("Wait").Call(),
+
+		For(List(Id("_"), Err()).Op(":=").Range().Id("errs")).Block(
+			If(Err().Op("!=").Nil()).Block(
+				Return(Nil(), Err()),
+			),
+		),
+
+		Return(Id("nodes"), Nil()),
+	)
+
+	f.Comment("node finds the instance identified by the given global ID. It returns nil if there is no such instance")
+	f.Func().Parens(Id("r").Op("*").Id("Resolver")).Id("node").Params(
+		Id("ctx").Qual("context", "Context"),
+		Id("id").Qual("github.com/graph-gophers/graphql-go", "ID"),
+	).Parens(List(
+		Op("*").Id(resolver),
+		Error(),
+	)).BlockFunc(func(g *Group) {
+		g.List(Id("typ"), Id("local")).Op(":=").Id("ParseGlobalID").Call(Id("id"))
+
+		g.Switch(Id("typ")).BlockFunc(func(g2 *Group) {
+			for _, e := range m.Entities {
+				if !e.ImplementsNode() {
+					continue
+				}
+
+				g2.Case(Lit(e.Name)).Block(
+					List(Id(e.VarName()), Err()).Op(":=").Id(LoadByIDFunName(e)).Call(
+						Id("ctx"),
+						Id("r").Dot("Repo"),
+						Id("local"),
+					),
+					If(Err().Op("!=").Nil()).Block(
+						Return(Nil(), Id("NodeError").Call(Err())),
+					),
+					Return(
+						Op("&").Id(resolver).Values(Dict{
+							Id("Node"): Op("&").Id(GraphqlResolverForEntity(e)).Values(Dict{
+								Id("Repo"): Id("r").Dot("Repo"),
+								Id("Data"): Id(e.VarName()),
+							}),
+						}),
+						Nil(),
+					),
+				)
+			}
+		})
+
+		g.Return(Nil(), Nil())
+	})
+
+	f.Comment("NodeError returns the error of a node lookup, or nil if it did not find the instance, which is resolved as null")
+	f.Func().Id("NodeError").Params(
+		Err().Error(),
+	).Error().Block(
+		Var().Id("classified").Op("*").Id("Error"),
+		If(Qual("errors", "As").Call(Err(), Op("&").Id("classified")).Op("&&").Id("classified").Dot("Code").Op("==").Id("CodeNotFound")).Block(
+			Return(Nil()),
+		),
+
+		Return(Id("GraphqlError").Call(Err(), Id("CodeInternal"), Lit("Error finding node"))),
+	)
+}
+
+// MaybeValidateGlobalID adds the code that rejects the global ID given
+// by the client for a new instance of the given entity, if it does not
+// identify an instance of that entity. The error is counted for the
+// given mutation, unless the caller counts it
+func MaybeValidateGlobalID(e *Entity, mutation string, counted bool, g *Group) {
+	if !e.UsesGlobalIDs() || !ClientSuppliesID(e, mutation) {
+		return
+	}
+
+	g.If(Id(e.VarName()).Dot("ID").Op("==").Lit("")).BlockFunc(func(g2 *Group) {
+		if !counted {
+			g2.Id(MutationErrorCounterName(e, mutation)).Dot("Inc").Call()
+		}
+		g2.Return(Nil(), ValidationError("id", Lit(fmt.Sprintf("Invalid global ID of %s", e.Name))))
+	})
+}
+
+// MaybeRejectUnknownGlobalID adds the code that returns a not found
+// error when the global ID given by the client for an existing instance
+// of the given entity does not identify an instance of that entity, so
+// that the mutation does not run for an empty id
+func MaybeRejectUnknownGlobalID(e *Entity, mutation string, globalID Code, g *Group) {
+	if !e.UsesGlobalIDs() {
+		return
+	}
+
+	g.If(Id(e.VarName()).Dot("ID").Op("==").Lit("")).Block(
+		Id(MutationErrorCounterName(e, mutation)).Dot("Inc").Call(),
+		Return(Nil(), NotFoundError(e, globalID)),
+	)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+
+	. "github.com/dave/jennifer/jen"
+	"github.com/iancoleman/strcase"
+)
+
+// CreateOrder generates a Golang file that contains the sort fields
+// of every entity, and the code that compiles them into SQL ORDER BY
+// clauses
+func CreateOrder(p *Package) error {
+	f := NewFile(p.Name)
+
+	f.PackageComment(fmt.Sprintf("%s contains all the library code for the platform", p.Name))
+	f.PackageComment("This file contains the sort orders that can be applied to list queries")
+	f.PackageComment(" ** THIS CODE IS MACHINE GENERATED. DO NOT EDIT MANUALLY ** ")
+
+	AddSortOrderStruct(f)
+	AddSqlOrderByFun(f)
+
+	for _, e := range p.Model.Entities {
+		if e.SupportsOperation("find") {
+			AddEntitySortColumns(e, f)
+			AddEntitySortValuesFun(e, f)
+		}
+	}
+
+	return f.Save(p.Filename)
+}
+
+// SortDirections returns the supported sort directions. The first one
+// is the default
+func SortDirections() []string {
+	return []string{"ASC", "DESC"}
+}
+
+// SortDirectionName returns the name of the Graphql enum of sort
+// directions
+func SortDirectionName() string {
+	return "SortDirection"
+}
+
+// EntitySortFieldName returns the name of the Graphql enum of fields
+// that instances of the given entity can be sorted by
+func EntitySortFieldName(e *Entity) string {
+	return fmt.Sprintf("%sSortField", e.Name)
+}
+
+// EntityOrderName returns the name of the Graphql input that sorts
+// instances of the given entity by a single field
+func EntityOrderName(e *Entity) string {
+	return fmt.Sprintf("%sOrder", e.Name)
+}
+
+// EntitySortColumnsName returns the name of the generated map of sort
+// fields to columns of the given entity
+func EntitySortColumnsName(e *Entity) string {
+	return fmt.Sprintf("%sSortColumns", e.Name)
+}
+
+// EntitySortValuesFunName returns the name of the function that
+// extracts the values of sort fields from instances of the given entity
+func EntitySortValuesFunName(e *Entity) string {
+	return fmt.Sprintf("%sSortValues", e.Name)
+}
+
+// AttributeSortField returns the value of the sort field enum for the
+// given attribute
+func AttributeSortField(a *Attribute) string {
+	return strcase.ToScreamingSnake(a.Name)
+}
+
+// AddSortOrderStruct generates the struct that holds a single sort
+// field, as passed to the repository
+func AddSortOrderStruct(f *File) {
+	f.Comment("SortOrder sorts results by a single field, in the given direction. An empty direction sorts in ascending order")
+	f.Type().Id("SortOrder").Struct(
+		Id("Field").String(),
+		Id("Direction").String(),
+	)
+}
+
+// AddSqlOrderByFun generates a function that compiles a list of sort
+// orders into the expressions of an ORDER BY clause. Only fields found
+// in the given map of columns are accepted, so that the resulting
+// SQL never contains values provided by the client
+func AddSqlOrderByFun(f *File) {
+	funName := "SqlOrderBy"
+
+	f.Comment(fmt.Sprintf("%s returns the ORDER BY expressions for the given sort orders. Fields are mapped to columns using the given map, and unknown fields or directions are rejected. Results are sorted by the given fallback column when no order is given", funName))
+	f.Func().Id(funName).Params(
+		Id("orders").Index().Op("*").Id("SortOrder"),
+		Id("columns").Map(String()).String(),
+		Id("fallback").String(),
+	).Parens(List(
+		String(),
+		Error(),
+	)).BlockFunc(func(g *Group) {
+		g.Id("exprs").Op(":=").Index().String().Values()
+
+		g.For(List(Id("_"), Id("o")).Op(":=").Range().Id("orders")).BlockFunc(func(g2 *Group) {
+			g2.List(Id("column"), Id("ok")).Op(":=").Id("columns").Index(Id("o").Dot("Field"))
+			g2.If(Op("!").Id("ok")).Block(
+				Return(Lit(""), ValidationError("orderBy", Qual("fmt", "Sprintf").Call(Lit("Unknown sort field %s"), Id("o").Dot("Field")))),
+			)
+
+			g2.Id("direction").Op(":=").Id("o").Dot("Direction")
+			g2.Switch(Id("direction")).BlockFunc(func(g3 *Group) {
+				g3.Case(Lit("")).Block(
+					Id("direction").Op("=").Lit(SortDirections()[0]),
+				)
+				g3.CaseFunc(func(g4 *Group) {
+					for _, d := range SortDirections() {
+						g4.Lit(d)
+					}
+				}).Block()
+				g3.Default().Block(
+					Return(Lit(""), ValidationError("orderBy", Qual("fmt", "Sprintf").Call(Lit("Unknown sort direction %s"), Id("direction")))),
+				)
+			})
+
+			g2.Id("exprs").Op("=").Append(Id("exprs"), Id("column").Op("+").Lit(" ").Op("+").Id("direction"))
+		})
+
+		g.If(Len(Id("exprs")).Op("==").Lit(0)).Block(
+			Id("exprs").Op("=").Append(Id("exprs"), Id("fallback").Op("+").Lit(" ").Op("+").Lit(SortDirections()[0])),
+		)
+
+		g.Return(Qual("strings", "Join").Call(Id("exprs"), Lit(", ")), Nil())
+	})
+}
+
+// AddEntitySortColumns generates the map of sort fields to columns for
+// the given entity. Every attribute can be used to sort results
+func AddEntitySortColumns(e *Entity, f *File) {
+	name := EntitySortColumnsName(e)
+
+	f.Comment(fmt.Sprintf("%s maps the fields instances of type %s can be sorted by, to their columns", name, e.Name))
+	f.Var().Id(name).Op("=").Map(String()).String().Values(DictFunc(func(d Dict) {
+		for _, a := range e.Attributes {
+			d[Lit(AttributeSortField(a))] = Lit(AttributeColumnName(a))
+		}
+	}))
+}
+
+// AddEntitySortValuesFun generates a function that returns the values
+// of the given sort fields of an instance of the given entity
+func AddEntitySortValuesFun(e *Entity, f *File) {
+	funName := EntitySortValuesFunName(e)
+
+	f.Comment(fmt.Sprintf("%s returns the values of the given sort fields of the given %s", funName, e.Name))
+	f.Func().Id(funName).Params(
+		Id(e.VarName()).Op("*").Id(e.Name),
+		Id("orders").Index().Op("*").Id("SortOrder"),
+	).Index().Interface().BlockFunc(func(g *Group) {
+		g.Id("values").Op(":=").Index().Interface().Values()
+		g.For(List(Id("_"), Id("o")).Op(":=").Range().Id("orders")).Block(
+			Switch(Id("o").Dot("Field")).BlockFunc(func(g2 *Group) {
+				// enums are compared as strings
+				for _, a := range e.Attributes {
+					value := Id(e.VarName()).Dot(a.Name)
+					if a.Enum() != nil {
+						value = String().Call(value)
+					}
+
+					g2.Case(Lit(AttributeSortField(a))).Block(
+						Id("values").Op("=").Append(Id("values"), value),
+					)
+				}
+			}),
+		)
+
+		g.Return(Id("values"))
+	})
+}
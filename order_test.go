@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// floatOrderModel has a Float attribute whose values sort differently
+// as text and as numbers
+const floatOrderModel = `entities:
+  - name: User
+    traits:
+      - id
+    attributes:
+      - name: Score
+        type: Float
+`
+
+// floatOrderTest runs inside the generated app, and sorts the same
+// users by score with both repositories
+const floatOrderTest = `package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFloatOrder(t *testing.T) {
+	ctx := context.Background()
+	db, err := NewDb("file::memory:?cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ExecStatements(ctx, db, SqlSchema(Sqlite3)); err != nil {
+		t.Fatal(err)
+	}
+	stmts, err := PrepareStatements(ctx, db, Sqlite3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replicas, err := NewReplicas(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repos := map[string]Repository{
+		"sql":    &SqlRepository{Db: db, Replicas: replicas, Statements: stmts},
+		"memory": NewMemoryRepository(),
+	}
+	for name, repo := range repos {
+		for id, score := range map[string]float64{"a": 100, "b": 9, "c": 10.5} {
+			if _, err := repo.CreateUser(ctx, &User{ID: id, Score: score}); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+		}
+
+		users, err := repo.FindAllUsers(ctx, nil, []*SortOrder{{Field: "SCORE", Direction: "ASC"}}, 10, 0)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got := ""
+		for _, u := range users {
+			got += u.ID
+		}
+		if got != "bca" {
+			t.Errorf("%s sorts users by score as %s, want bca", name, got)
+		}
+	}
+}
+`
+
+// TestFloatOrderMatchesMemoryRepository generates an app with a Float
+// attribute, and checks that both of its repositories sort it as a
+// number
+func TestFloatOrderMatchesMemoryRepository(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a generated app")
+	}
+
+	dir := t.TempDir()
+	model := filepath.Join(dir, "model.yml")
+	out := filepath.Join(dir, "app")
+	write(t, model, floatOrderModel)
+	if err := os.Mkdir(out, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	run(t, ".", "go", "run", ".", "--model="+model, "--output="+out)
+	write(t, filepath.Join(out, "order_test.go"), floatOrderTest)
+	run(t, out, "go", "mod", "init", "floatorder")
+	run(t, out, "go", "mod", "tidy")
+	run(t, out, "go", "test", "-run", "TestFloatOrder", ".")
+}
+
+func write(t *testing.T, name, content string) {
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func run(t *testing.T, dir string, name string, args ...string) {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("%s %v: %v\n%s", name, args, err, out)
+	}
+}
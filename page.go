@@ -0,0 +1,385 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	. "github.com/dave/jennifer/jen"
+)
+
+// CreatePage generates a Golang file that contains the types used by
+// cursor based pagination, the code that encodes and decodes cursors,
+// and the code that compiles them into SQL keyset conditions
+func CreatePage(p *Package) error {
+	f := NewFile(p.Name)
+
+	f.PackageComment(fmt.Sprintf("%s contains all the library code for the platform", p.Name))
+	f.PackageComment("This file contains the types and functions used by cursor based pagination")
+	f.PackageComment(" ** THIS CODE IS MACHINE GENERATED. DO NOT EDIT MANUALLY ** ")
+
+	AddPageStructs(f)
+	AddPageSizeFun(f)
+	AddNewPageInfoFun(f)
+	AddEncodeCursorFun(f)
+	AddDecodeCursorFun(f)
+	AddKeysetOrdersFun(f)
+	AddReverseSortOrdersFun(f)
+	AddSqlKeysetConditionFun(f)
+
+	for _, e := range p.Model.Entities {
+		if e.SupportsOperation("find") && e.SupportsCursorPagination() {
+			AddEntityConnectionStructs(e, f)
+			AddEntityCursorValuesFun(e, f)
+		}
+	}
+
+	return f.Save(p.Filename)
+}
+
+// EntityConnectionName returns the name of the type that holds a page
+// of instances of the given entity
+func EntityConnectionName(e *Entity) string {
+	return fmt.Sprintf("%sConnection", e.Name)
+}
+
+// EntityEdgeName returns the name of the type that holds an instance
+// of the given entity, and its cursor
+func EntityEdgeName(e *Entity) string {
+	return fmt.Sprintf("%sEdge", e.Name)
+}
+
+// EntityCursorValuesFunName returns the name of the function that
+// decodes cursors of the given entity
+func EntityCursorValuesFunName(e *Entity) string {
+	return fmt.Sprintf("%sCursorValues", e.Name)
+}
+
+// AddPageStructs generates the structs that select a page of results,
+// and describe the selected page
+func AddPageStructs(f *File) {
+	f.Comment("Page selects a page of results. First and After select results forwards, while Last and Before select results backwards")
+	f.Type().Id("Page").Struct(
+		Id("First").Op("*").Int32(),
+		Id("After").Op("*").String(),
+		Id("Last").Op("*").Int32(),
+		Id("Before").Op("*").String(),
+	)
+
+	f.Comment("PageInfo describes a page of results, and holds the cursors of its first and last results")
+	f.Type().Id("PageInfo").Struct(
+		Id("HasNextPage").Bool(),
+		Id("HasPreviousPage").Bool(),
+		Id("StartCursor").Op("*").String(),
+		Id("EndCursor").Op("*").String(),
+	)
+
+	f.Comment("Cursor is the decoded representation of a cursor. It holds the sort orders it was built for, and the values of the sort fields of a single result")
+	f.Type().Id("Cursor").Struct(
+		Id("Orders").Index().String(),
+		Id("Values").Index().Qual("encoding/json", "RawMessage"),
+	)
+}
+
+// AddPageSizeFun generates a function that validates the given page,
+// and returns the number of results it selects
+func AddPageSizeFun(f *File) {
+	funName := "PageSize"
+
+	f.Comment(fmt.Sprintf("%s returns the number of results selected by the given page, and whether they are selected backwards. Exactly one of First and Last must be given", funName))
+	f.Func().Id(funName).Params(
+		Id("page").Op("*").Id("Page"),
+	).Parens(List(
+		Int32(),
+		Bool(),
+		Error(),
+	)).BlockFunc(func(g *Group) {
+		g.If(Id("page").Op("==").Nil().Op("||").Parens(
+			Id("page").Dot("First").Op("==").Nil().Op("&&").Id("page").Dot("Last").Op("==").Nil(),
+		)).Block(
+			Return(Lit(0), False(), ValidationError("", Lit("Either first or last must be given"))),
+		)
+
+		g.If(Id("page").Dot("First").Op("!=").Nil().Op("&&").Id("page").Dot("Last").Op("!=").Nil()).Block(
+			Return(Lit(0), False(), ValidationError("", Lit("First and last cannot be combined"))),
+		)
+
+		for _, field := range []string{"First", "Last"} {
+			g.If(Id("page").Dot(field).Op("!=").Nil()).Block(
+				If(Op("*").Id("page").Dot(field).Op("<").Lit(0)).Block(
+					Return(Lit(0), False(), ValidationError(strings.ToLower(field), Lit(fmt.Sprintf("%s cannot be negative", field)))),
+				),
+				Return(Op("*").Id("page").Dot(field), Lit(field == "Last"), Nil()),
+			)
+		}
+
+		g.Return(Lit(0), False(), Nil())
+	})
+}
+
+// AddNewPageInfoFun generates a function that describes a page of
+// results
+func AddNewPageInfoFun(f *File) {
+	funName := "NewPageInfo"
+
+	f.Comment(fmt.Sprintf("%s describes a page of results with the given cursors. More tells whether there are results past the end of the page, in the direction it was selected", funName))
+	f.Func().Id(funName).Params(
+		Id("page").Op("*").Id("Page"),
+		Id("more").Bool(),
+		Id("cursors").Index().String(),
+	).Op("*").Id("PageInfo").BlockFunc(func(g *Group) {
+		g.Id("info").Op(":=").Op("&").Id("PageInfo").Values(Dict{
+			Id("HasNextPage"):     Id("more"),
+			Id("HasPreviousPage"): Id("page").Dot("After").Op("!=").Nil(),
+		})
+
+		g.If(Id("page").Dot("Last").Op("!=").Nil()).Block(
+			Id("info").Dot("HasNextPage").Op("=").Id("page").Dot("Before").Op("!=").Nil(),
+			Id("info").Dot("HasPreviousPage").Op("=").Id("more"),
+		)
+
+		g.If(Len(Id("cursors")).Op(">").Lit(0)).Block(
+			Id("info").Dot("StartCursor").Op("=").Op("&").Id("cursors").Index(Lit(0)),
+			Id("info").Dot("EndCursor").Op("=").Op("&").Id("cursors").Index(Len(Id("cursors")).Op("-").Lit(1)),
+		)
+
+		g.Return(Id("info"))
+	})
+}
+
+// AddEncodeCursorFun generates a function that builds opaque cursors
+func AddEncodeCursorFun(f *File) {
+	funName := "EncodeCursor"
+
+	f.Comment(fmt.Sprintf("%s returns an opaque cursor that holds the given values of the given sort orders", funName))
+	f.Func().Id(funName).Params(
+		Id("orders").Index().Op("*").Id("SortOrder"),
+		Id("values").Index().Interface(),
+	).String().BlockFunc(func(g *Group) {
+		g.Id("c").Op(":=").Op("&").Id("Cursor").Values()
+		g.For(List(Id("i"), Id("o")).Op(":=").Range().Id("orders")).Block(
+			List(Id("v"), Id("_")).Op(":=").Qual("encoding/json", "Marshal").Call(Id("values").Index(Id("i"))),
+			Id("c").Dot("Orders").Op("=").Append(Id("c").Dot("Orders"), Id("o").Dot("Field").Op("+").Lit(" ").Op("+").Id("o").Dot("Direction")),
+			Id("c").Dot("Values").Op("=").Append(Id("c").Dot("Values"), Id("v")),
+		)
+
+		g.List(Id("data"), Id("_")).Op(":=").Qual("encoding/json", "Marshal").Call(Id("c"))
+		g.Return(Qual("encoding/base64", "URLEncoding").Dot("EncodeToString").Call(Id("data")))
+	})
+}
+
+// AddDecodeCursorFun generates a function that decodes opaque cursors,
+// and checks they were built for the sort orders in use
+func AddDecodeCursorFun(f *File) {
+	funName := "DecodeCursor"
+
+	f.Comment(fmt.Sprintf("%s decodes the given cursor, and checks it was built for the given sort orders", funName))
+	f.Func().Id(funName).Params(
+		Id("cursor").String(),
+		Id("orders").Index().Op("*").Id("SortOrder"),
+	).Parens(List(
+		Op("*").Id("Cursor"),
+		Error(),
+	)).BlockFunc(func(g *Group) {
+		invalid := Return(Nil(), ValidationError("", Qual("fmt", "Sprintf").Call(Lit("Invalid cursor %s"), Id("cursor"))))
+
+		g.List(Id("data"), Err()).Op(":=").Qual("encoding/base64", "URLEncoding").Dot("DecodeString").Call(Id("cursor"))
+		g.If(Err().Op("!=").Nil()).Block(invalid)
+
+		g.Id("c").Op(":=").Op("&").Id("Cursor").Values()
+		g.If(
+			Err().Op(":=").Qual("encoding/json", "Unmarshal").Call(Id("data"), Id("c")),
+			Err().Op("!=").Nil(),
+		).Block(invalid)
+
+		g.If(Len(Id("c").Dot("Values")).Op("!=").Len(Id("c").Dot("Orders"))).Block(invalid)
+
+		mismatch := Return(Nil(), ValidationError("", Qual("fmt", "Sprintf").Call(Lit("Cursor %s does not match the sort order"), Id("cursor"))))
+		g.If(Len(Id("c").Dot("Orders")).Op("!=").Len(Id("orders"))).Block(mismatch)
+
+		g.For(List(Id("i"), Id("o")).Op(":=").Range().Id("orders")).Block(
+			If(Id("c").Dot("Orders").Index(Id("i")).Op("!=").Id("o").Dot("Field").Op("+").Lit(" ").Op("+").Id("o").Dot("Direction")).Block(
+				mismatch,
+			),
+		)
+
+		g.Return(Id("c"), Nil())
+	})
+}
+
+// AddKeysetOrdersFun generates a function that completes the given
+// sort orders, so that every result has a distinct position
+func AddKeysetOrdersFun(f *File) {
+	funName := "KeysetOrders"
+
+	f.Comment(fmt.Sprintf("%s returns the given sort orders, with their directions set, or the fallback field if none are given. The unique field is appended unless already present, so that every result has a distinct position", funName))
+	f.Func().Id(funName).Params(
+		Id("orders").Index().Op("*").Id("SortOrder"),
+		Id("fallback").String(),
+		Id("unique").String(),
+	).Index().Op("*").Id("SortOrder").BlockFunc(func(g *Group) {
+		g.If(Len(Id("orders")).Op("==").Lit(0)).Block(
+			Id("orders").Op("=").Index().Op("*").Id("SortOrder").Values(
+				Op("&").Id("SortOrder").Values(Dict{Id("Field"): Id("fallback")}),
+			),
+		)
+
+		g.Id("keyset").Op(":=").Index().Op("*").Id("SortOrder").Values()
+		g.Id("found").Op(":=").False()
+		g.For(List(Id("_"), Id("o")).Op(":=").Range().Id("orders")).Block(
+			Id("direction").Op(":=").Id("o").Dot("Direction"),
+			If(Id("direction").Op("==").Lit("")).Block(
+				Id("direction").Op("=").Lit(SortDirections()[0]),
+			),
+			Id("keyset").Op("=").Append(Id("keyset"), Op("&").Id("SortOrder").Values(Dict{
+				Id("Field"):     Id("o").Dot("Field"),
+				Id("Direction"): Id("direction"),
+			})),
+			Id("found").Op("=").Id("found").Op("||").Id("o").Dot("Field").Op("==").Id("unique"),
+		)
+
+		g.If(Op("!").Id("found")).Block(
+			Id("keyset").Op("=").Append(Id("keyset"), Op("&").Id("SortOrder").Values(Dict{
+				Id("Field"):     Id("unique"),
+				Id("Direction"): Lit(SortDirections()[0]),
+			})),
+		)
+
+		g.Return(Id("keyset"))
+	})
+}
+
+// AddReverseSortOrdersFun generates a function that flips the
+// direction of sort orders, used to select results backwards
+func AddReverseSortOrdersFun(f *File) {
+	funName := "ReverseSortOrders"
+
+	f.Comment(fmt.Sprintf("%s returns the given sort orders, in the opposite direction", funName))
+	f.Func().Id(funName).Params(
+		Id("orders").Index().Op("*").Id("SortOrder"),
+	).Index().Op("*").Id("SortOrder").BlockFunc(func(g *Group) {
+		g.Id("reversed").Op(":=").Index().Op("*").Id("SortOrder").Values()
+		g.For(List(Id("_"), Id("o")).Op(":=").Range().Id("orders")).Block(
+			Id("direction").Op(":=").Lit(SortDirections()[1]),
+			If(Id("o").Dot("Direction").Op("==").Lit(SortDirections()[1])).Block(
+				Id("direction").Op("=").Lit(SortDirections()[0]),
+			),
+			Id("reversed").Op("=").Append(Id("reversed"), Op("&").Id("SortOrder").Values(Dict{
+				Id("Field"):     Id("o").Dot("Field"),
+				Id("Direction"): Id("direction"),
+			})),
+		)
+
+		g.Return(Id("reversed"))
+	})
+}
+
+// AddSqlKeysetConditionFun generates a function that builds the SQL
+// condition that selects results after, or before, a cursor
+func AddSqlKeysetConditionFun(f *File) {
+	funName := "SqlKeysetCondition"
+
+	f.Comment(fmt.Sprintf("%s returns a condition that matches the rows after the given values of the given sort orders, or before them if after is false. Fields are mapped to columns using the given map, and values are appended to args", funName))
+	f.Func().Id(funName).Params(
+		Id("orders").Index().Op("*").Id("SortOrder"),
+		Id("columns").Map(String()).String(),
+		Id("values").Index().Interface(),
+		Id("after").Bool(),
+		Id("args").Op("*").Index().Interface(),
+	).String().BlockFunc(func(g *Group) {
+		g.Id("ors").Op(":=").Index().String().Values()
+
+		// a row comes after the cursor when it has the same values for
+		// the first sort fields, and a greater value for the next one
+		g.For(List(Id("i"), Id("o")).Op(":=").Range().Id("orders")).BlockFunc(func(g2 *Group) {
+			g2.Id("conds").Op(":=").Index().String().Values()
+			g2.For(Id("j").Op(":=").Lit(0), Id("j").Op("<").Id("i"), Id("j").Op("++")).Block(
+				AppendCondition(Id("SqlCondition").Call(
+					Id("columns").Index(Id("orders").Index(Id("j")).Dot("Field")),
+					Lit("="),
+					Id("values").Index(Id("j")),
+					Id("args"),
+				)),
+			)
+
+			g2.Id("op").Op(":=").Lit(">")
+			g2.If(Parens(Id("o").Dot("Direction").Op("==").Lit(SortDirections()[1])).Op("==").Id("after")).Block(
+				Id("op").Op("=").Lit("<"),
+			)
+
+			g2.Add(AppendCondition(Id("SqlCondition").Call(
+				Id("columns").Index(Id("o").Dot("Field")),
+				Id("op"),
+				Id("values").Index(Id("i")),
+				Id("args"),
+			)))
+
+			g2.Id("ors").Op("=").Append(
+				Id("ors"),
+				Lit("(").Op("+").Qual("strings", "Join").Call(Id("conds"), Lit(" AND ")).Op("+").Lit(")"),
+			)
+		})
+
+		g.Return(Lit("(").Op("+").Qual("strings", "Join").Call(Id("ors"), Lit(" OR ")).Op("+").Lit(")"))
+	})
+}
+
+// AddEntityConnectionStructs generates the structs that hold a page of
+// instances of the given entity
+func AddEntityConnectionStructs(e *Entity, f *File) {
+	edge := EntityEdgeName(e)
+	connection := EntityConnectionName(e)
+
+	f.Comment(fmt.Sprintf("%s holds an instance of type %s, and the cursor that points at it", edge, e.Name))
+	f.Type().Id(edge).Struct(
+		Id("Cursor").String(),
+		Id("Node").Op("*").Id(e.Name),
+	)
+
+	f.Comment(fmt.Sprintf("%s holds a page of instances of type %s", connection, e.Name))
+	f.Type().Id(connection).Struct(
+		Id("Edges").Index().Op("*").Id(edge),
+		Id("PageInfo").Op("*").Id("PageInfo"),
+	)
+}
+
+// AddEntityCursorValuesFun generates a function that decodes a cursor
+// of the given entity into typed values of the sort fields
+func AddEntityCursorValuesFun(e *Entity, f *File) {
+	funName := EntityCursorValuesFunName(e)
+
+	f.Comment(fmt.Sprintf("%s decodes the given cursor into the values of the given sort fields of %s", funName, e.Name))
+	f.Func().Id(funName).Params(
+		Id("cursor").String(),
+		Id("orders").Index().Op("*").Id("SortOrder"),
+	).Parens(List(
+		Index().Interface(),
+		Error(),
+	)).BlockFunc(func(g *Group) {
+		g.List(Id("c"), Err()).Op(":=").Id("DecodeCursor").Call(Id("cursor"), Id("orders"))
+		g.If(Err().Op("!=").Nil()).Block(
+			Return(Nil(), Err()),
+		)
+
+		// values are decoded into an instance of the entity, so that
+		// they get the same types as the fields they were read from
+		g.Id(e.VarName()).Op(":=").Op("&").Id(e.Name).Values()
+		g.For(List(Id("i"), Id("o")).Op(":=").Range().Id("orders")).BlockFunc(func(g2 *Group) {
+			g2.Switch(Id("o").Dot("Field")).BlockFunc(func(g3 *Group) {
+				for _, a := range e.Attributes {
+					g3.Case(Lit(AttributeSortField(a))).Block(
+						Err().Op("=").Qual("encoding/json", "Unmarshal").Call(
+							Id("c").Dot("Values").Index(Id("i")),
+							Op("&").Id(e.VarName()).Dot(a.Name),
+						),
+					)
+				}
+			})
+
+			g2.If(Err().Op("!=").Nil()).Block(
+				Return(Nil(), ValidationError("", Qual("fmt", "Sprintf").Call(Lit("Invalid cursor %s"), Id("cursor")))),
+			)
+		})
+
+		g.Return(Id(EntitySortValuesFunName(e)).Call(Id(e.VarName()), Id("orders")), Nil())
+	})
+}
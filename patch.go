@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+
+	. "github.com/dave/jennifer/jen"
+)
+
+// CreatePatch generates a Golang file that contains the patches of
+// every entity that can be patched, which hold the values of the
+// fields changed by a partial update
+func CreatePatch(p *Package) error {
+	f := NewFile(p.Name)
+
+	f.PackageComment(fmt.Sprintf("%s contains all the library code for the platform", p.Name))
+	f.PackageComment("This file contains the patches used in partial updates")
+	f.PackageComment(" ** THIS CODE IS MACHINE GENERATED. DO NOT EDIT MANUALLY ** ")
+
+	for _, e := range p.Model.Entities {
+		if SupportsPatch(e) {
+			AddEntityPatchStruct(e, f)
+			AddEntityPatchApplyFun(e, f)
+			AddNewEntityPatchFun(e, f)
+		}
+	}
+
+	return f.Save(p.Filename)
+}
+
+// SupportsPatch returns whether instances of the given entity can be
+// partially updated. The stored instance is found by its ID before it
+// is patched, so that hooks receive both
+func SupportsPatch(e *Entity) bool {
+	if !e.SupportsOperation("update") || !e.SupportsOperation("find") {
+		return false
+	}
+
+	for _, a := range e.Attributes {
+		if a.Name == "ID" {
+			return a.HasModifier("unique") && a.HasModifier("indexed")
+		}
+	}
+
+	return false
+}
+
+// PatchAttributes returns the attributes of the given entity that can
+// be changed by a patch
+func PatchAttributes(e *Entity) []*Attribute {
+	attributes := []*Attribute{}
+	for _, a := range e.Attributes {
+		if a.Name != "ID" {
+			attributes = append(attributes, a)
+		}
+	}
+
+	return attributes
+}
+
+// PatchRelations returns the relations of the given entity that can be
+// changed by a patch, which are the ones stored as a column
+func PatchRelations(e *Entity) []*Relation {
+	relations := []*Relation{}
+	for _, r := range e.Relations {
+		if r.HasModifier("belongsTo") || r.HasModifier("hasOne") {
+			relations = append(relations, r)
+		}
+	}
+
+	return relations
+}
+
+// EntityPatchName returns the name of the struct that holds a patch
+// for the given entity
+func EntityPatchName(e *Entity) string {
+	return fmt.Sprintf("%sPatch", e.Name)
+}
+
+// AddEntityPatchStruct generates the struct that holds the values of
+// the fields of the given entity changed by a patch. Every field is a
+// pointer, which is nil when the field is left unchanged
+func AddEntityPatchStruct(e *Entity, f *File) {
+	name := EntityPatchName(e)
+
+	f.Comment(fmt.Sprintf("%s holds the values of the fields of a %s changed by a partial update. Nil fields are left unchanged", name, e.Name))
+	f.Type().Id(name).StructFunc(func(g *Group) {
+		for _, a := range PatchAttributes(e) {
+			g.Id(a.Name).Op("*").Add(TypeFromAttribute(a))
+		}
+
+		for _, r := range PatchRelations(e) {
+			g.Id(r.Alias()).Op("*").Id(r.Entity)
+		}
+	})
+}
+
+// AddEntityPatchApplyFun generates the method that applies a patch to
+// an instance of the given entity
+func AddEntityPatchApplyFun(e *Entity, f *File) {
+	v := e.VarName()
+
+	f.Comment(fmt.Sprintf("Apply returns a copy of the given %s, with the fields set in the patch changed", e.Name))
+	f.Func().Parens(Id("p").Op("*").Id(EntityPatchName(e))).Id("Apply").Params(
+		Id(v).Op("*").Id(e.Name),
+	).Op("*").Id(e.Name).BlockFunc(func(g *Group) {
+		g.Id("patched").Op(":=").Op("*").Id(v)
+
+		for _, a := range PatchAttributes(e) {
+			g.If(Id("p").Dot(a.Name).Op("!=").Nil()).Block(
+				Id("patched").Dot(a.Name).Op("=").Op("*").Id("p").Dot(a.Name),
+			)
+		}
+
+		for _, r := range PatchRelations(e) {
+			g.If(Id("p").Dot(r.Alias()).Op("!=").Nil()).Block(
+				Id("patched").Dot(r.Alias()).Op("=").Id("p").Dot(r.Alias()),
+			)
+		}
+
+		g.Return(Op("&").Id("patched"))
+	})
+}
+
+// NewEntityPatchFunName returns the name of the function that builds
+// a patch from the changes between two instances of the given entity
+func NewEntityPatchFunName(e *Entity) string {
+	return fmt.Sprintf("New%s", EntityPatchName(e))
+}
+
+// AddNewEntityPatchFun generates the function that builds the patch
+// that changes a stored instance of the given entity into a patched
+// one. Relations are compared by their ids
+func AddNewEntityPatchFun(e *Entity, f *File) {
+	funName := NewEntityPatchFunName(e)
+
+	f.Comment(fmt.Sprintf("%s returns the patch that changes the given stored %s into the patched one. Only the fields that differ are set", funName, e.Name))
+	f.Func().Id(funName).Params(
+		Id("stored").Op("*").Id(e.Name),
+		Id("patched").Op("*").Id(e.Name),
+	).Op("*").Id(EntityPatchName(e)).BlockFunc(func(g *Group) {
+		g.Id("patch").Op(":=").Op("&").Id(EntityPatchName(e)).Values()
+
+		for _, a := range PatchAttributes(e) {
+			g.If(Id("patched").Dot(a.Name).Op("!=").Id("stored").Dot(a.Name)).Block(
+				Id("value").Op(":=").Id("patched").Dot(a.Name),
+				Id("patch").Dot(a.Name).Op("=").Op("&").Id("value"),
+			)
+		}
+
+		// a patch cannot unset a relation, so only new ones are kept
+		for _, r := range PatchRelations(e) {
+			field := Id("patched").Dot(r.Alias())
+			g.If(field.Clone().Op("!=").Nil().Op("&&").Parens(
+				Id("stored").Dot(r.Alias()).Op("==").Nil().Op("||").Id("stored").Dot(r.Alias()).Dot("ID").Op("!=").Add(field.Clone()).Dot("ID"),
+			)).Block(
+				Id("patch").Dot(r.Alias()).Op("=").Add(field.Clone()),
+			)
+		}
+
+		g.Return(Id("patch"))
+	})
+}
+
+// PatchEntityFunName returns the name of the patch function for the
+// entity
+func PatchEntityFunName(e *Entity) string {
+	return fmt.Sprintf("Patch%s", e.Name)
+}
+
+// AddPatchFun produces the function that partially updates an entity
+// in the database. The UPDATE statement only sets the columns of the
+// fields in the patch, and the stored row is returned
+func AddPatchFun(e *Entity, f *File) {
+	funName := PatchEntityFunName(e)
+
+	f.Comment(fmt.Sprintf("%s updates the columns of the fields set in the given patch, for the entity of type %s with the given id, and returns it. If no row matches, then this function returns an error", funName, e.Name))
+	f.Func().Id(funName).Params(
+		Id("ctx").Qual("context", "Context"),
+		Id("db").Id("DBTX"),
+		Id("stmts").Op("*").Id("Statements"),
+		Id("id").String(),
+		Id("patch").Op("*").Id(EntityPatchName(e)),
+	).Parens(List(
+		Op("*").Id(e.Name),
+		Error(),
+	)).BlockFunc(func(g *Group) {
+		g.Add(EmptyStructForEntity(e))
+
+		g.Id("sets").Op(":=").Index().String().Values()
+		g.Id("args").Op(":=").Index().Interface().Values()
+
+		for _, a := range PatchAttributes(e) {
+			AddPatchColumn(AttributeColumnName(a), Id("patch").Dot(a.Name), Op("*").Id("patch").Dot(a.Name), g)
+		}
+
+		for _, r := range PatchRelations(e) {
+			AddPatchColumn(RelationColumnName(r), Id("patch").Dot(r.Alias()), Id("patch").Dot(r.Alias()).Dot("ID"), g)
+		}
+
+		// there must be at least one column in the SET clause, even
+		// for empty patches
+		g.If(Len(Id("sets")).Op("==").Lit(0)).Block(
+			Id("sets").Op("=").Append(Id("sets"), Lit("id=id")),
+		)
+
+		g.Id("args").Op("=").Append(Id("args"), Id("id"))
+		PrepareStatement(Qual("fmt", "Sprintf").Call(
+			Lit(fmt.Sprintf("UPDATE %s SET %%s WHERE id=$%%d", TableName(e))),
+			Qual("strings", "Join").Call(Id("sets"), Lit(",")),
+			Len(Id("args")),
+		), g)
+		IfErrorReturnEntityAndError(e, g)
+
+		DeferCloseStatement(g)
+
+		g.List(Id("_"), Err()).Op("=").Id("stmt").Dot("ExecContext").Call(Id("ctx"), Id("args").Op("..."))
+		IfErrorReturnEntityAndError(e, g)
+
+		g.List(Id("find"), Err()).Op(":=").Id("stmts").Dot("Prepare").Call(Id("ctx"), Id("db"), Lit(SelectByColumnFromStatement(e, "id")))
+		IfErrorReturnEntityAndError(e, g)
+
+		DeferCall("find", "Close", g)
+
+		g.Err().Op("=").Id("find").Dot("QueryRowContext").Call(Id("ctx"), Id("id")).Dot("Scan").Call(ListFunc(
+			ScanRowIntoEntityStruct(e),
+		))
+		g.Return(Id(e.VarName()), Err())
+	})
+}
+
+// AddPatchColumn produces the code that adds the given column to the
+// SET clause of a patch, along with its value, when the given field of
+// the patch is set
+func AddPatchColumn(column string, field *Statement, value *Statement, g *Group) {
+	g.If(field.Op("!=").Nil()).Block(
+		Id("args").Op("=").Append(Id("args"), value),
+		Id("sets").Op("=").Append(Id("sets"), Qual("fmt", "Sprintf").Call(
+			Lit(fmt.Sprintf("%s=$%%d", column)),
+			Len(Id("args")),
+		)),
+	)
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	. "github.com/dave/jennifer/jen"
+)
+
+// CreatePubSub generates a Golang file that contains the in-process
+// pub/sub that delivers the changes made by mutations to subscriptions
+func CreatePubSub(p *Package) error {
+	f := NewFile(p.Name)
+
+	f.PackageComment(fmt.Sprintf("%s contains all the library code for the platform", p.Name))
+	f.PackageComment("This file contains the pub/sub that feeds subscriptions")
+	f.PackageComment(" ** THIS CODE IS MACHINE GENERATED. DO NOT EDIT MANUALLY ** ")
+
+	AddEventStruct(f)
+	AddPubSubStruct(f)
+	AddPubSubSubscribeFun(f)
+	AddPubSubPublishFun(f)
+
+	for _, e := range p.Model.Entities {
+		for _, event := range SubscriptionEvents(e) {
+			AddEventTopicConst(e, event, f)
+		}
+	}
+
+	return f.Save(p.Filename)
+}
+
+// SubscriptionEvents returns the kinds of changes to instances of the
+// given entity that clients can subscribe to. Upserts are published as
+// updates
+func SubscriptionEvents(e *Entity) []string {
+	events := []string{}
+
+	if e.SupportsOperation("create") {
+		events = append(events, "created")
+	}
+
+	if e.SupportsOperation("update") || e.SupportsOperation("upsert") {
+		events = append(events, "updated")
+	}
+
+	if e.SupportsOperation("delete") {
+		events = append(events, "deleted")
+	}
+
+	return events
+}
+
+// SubscriptionRelations returns the relations of the given entity that
+// subscriptions can be filtered by, which are the ones to its parents
+func SubscriptionRelations(e *Entity) []*Relation {
+	relations := []*Relation{}
+	for _, r := range e.Relations {
+		if r.HasModifier("belongsTo") || r.HasModifier("hasOne") {
+			relations = append(relations, r)
+		}
+	}
+
+	return relations
+}
+
+// EventTopicName returns the name of the constant that holds the topic
+// of the given kind of change to instances of the given entity
+func EventTopicName(e *Entity, event string) string {
+	return fmt.Sprintf("%s%sTopic", e.Name, strings.Title(event))
+}
+
+// AddEventTopicConst generates the constant that holds the topic of
+// the given kind of change to instances of the given entity
+func AddEventTopicConst(e *Entity, event string, f *File) {
+	name := EventTopicName(e, event)
+
+	f.Comment(fmt.Sprintf("%s is the topic of the events published when an instance of type %s is %s", name, e.Name, event))
+	f.Const().Id(name).Op("=").Lit(fmt.Sprintf("%s.%s", e.Name, event))
+}
+
+// AddEventStruct generates the struct of the events delivered by the
+// pub/sub, and the size of the buffer of each subscriber
+func AddEventStruct(f *File) {
+	f.Comment("PubSubBufferSize is the number of events a subscriber can fall behind by. Events published while the buffer of a subscriber is full are dropped for that subscriber")
+	f.Const().Id("PubSubBufferSize").Op("=").Lit(64)
+
+	f.Comment("ChangeEvent is a change to an instance of an entity, published once the mutation that made it is committed")
+	f.Type().Id("ChangeEvent").Struct(
+		Id("Topic").String(),
+		Id("Data").Interface(),
+	)
+}
+
+// AddPubSubStruct generates the pub/sub struct, and its constructor
+func AddPubSubStruct(f *File) {
+	f.Comment("PubSub delivers the events published by mutations to the subscribers of this process")
+	f.Type().Id("PubSub").Struct(
+		Id("mu").Qual("sync", "RWMutex"),
+		Id("subscribers").Map(String()).Map(Chan().Interface()).Struct(),
+	)
+
+	f.Comment("NewPubSub returns a pub/sub without subscribers")
+	f.Func().Id("NewPubSub").Params().Op("*").Id("PubSub").Block(
+		Return(Op("&").Id("PubSub").Values(Dict{
+			Id("subscribers"): Map(String()).Map(Chan().Interface()).Struct().Values(),
+		})),
+	)
+}
+
+// AddPubSubSubscribeFun generates the method that subscribes to the
+// events of a topic
+func AddPubSubSubscribeFun(f *File) {
+	funName := "Subscribe"
+
+	f.Comment(fmt.Sprintf("%s returns a channel that receives the data of the events published to the given topic, until the given context is done. Nothing is ever received from a nil pub/sub", funName))
+	f.Func().Params(Id("p").Op("*").Id("PubSub")).Id(funName).Params(
+		Id("ctx").Qual("context", "Context"),
+		Id("topic").String(),
+	).Op("<-").Chan().Interface().Block(
+		Id("c").Op(":=").Make(Chan().Interface(), Id("PubSubBufferSize")),
+
+		If(Id("p").Op("==").Nil()).Block(
+			Go().Func().Params().Block(
+				Op("<-").Id("ctx").Dot("Done").Call(),
+				Close(Id("c")),
+			).Call(),
+			Return(Id("c")),
+		),
+
+		Id("p").Dot("mu").Dot("Lock").Call(),
+		List(Id("subscribers"), Id("ok")).Op(":=").Id("p").Dot("subscribers").Index(Id("topic")),
+		If(Op("!").Id("ok")).Block(
+			Id("subscribers").Op("=").Map(Chan().Interface()).Struct().Values(),
+			Id("p").Dot("subscribers").Index(Id("topic")).Op("=").Id("subscribers"),
+		),
+		Id("subscribers").Index(Id("c")).Op("=").Struct().Values(),
+		Id("p").Dot("mu").Dot("Unlock").Call(),
+
+		Go().Func().Params().Block(
+			Op("<-").Id("ctx").Dot("Done").Call(),
+
+			Id("p").Dot("mu").Dot("Lock").Call(),
+			Delete(Id("p").Dot("subscribers").Index(Id("topic")), Id("c")),
+			Id("p").Dot("mu").Dot("Unlock").Call(),
+
+			Close(Id("c")),
+		).Call(),
+
+		Return(Id("c")),
+	)
+}
+
+// AddPubSubPublishFun generates the method that publishes events to
+// their subscribers
+func AddPubSubPublishFun(f *File) {
+	funName := "Publish"
+
+	f.Comment(fmt.Sprintf("%s delivers the given events to the subscribers of their topics, without waiting for them. A nil pub/sub discards all events", funName))
+	f.Func().Params(Id("p").Op("*").Id("PubSub")).Id(funName).Params(
+		Id("events").Op("...").Id("ChangeEvent"),
+	).Block(
+		If(Id("p").Op("==").Nil()).Block(
+			Return(),
+		),
+
+		Id("p").Dot("mu").Dot("RLock").Call(),
+		Defer().Id("p").Dot("mu").Dot("RUnlock").Call(),
+
+		For(List(Id("_"), Id("event")).Op(":=").Range().Id("events")).Block(
+			For(Id("c").Op(":=").Range().Id("p").Dot("subscribers").Index(Id("event").Dot("Topic"))).Block(
+				Select().Block(
+					Case(Id("c").Op("<-").Id("event").Dot("Data")).Block(),
+					Default().Block(),
+				),
+			),
+		),
+	)
+}
+
+// NewEvent returns the code that builds an event of the given kind of
+// change to the given instance of an entity
+func NewEvent(e *Entity, event string, data *Statement) *Statement {
+	return Id("ChangeEvent").Values(Dict{
+		Id("Topic"): Id(EventTopicName(e, event)),
+		Id("Data"):  data,
+	})
+}
+
+// PublishEvent adds the code that publishes the given kind of change
+// to the given instance of an entity. It runs once the mutation is
+// committed
+func PublishEvent(e *Entity, event string, data *Statement, g *Group) {
+	g.Id("r").Dot("Events").Dot("Publish").Call(NewEvent(e, event, data))
+}
+
+// PublishEntitiesEvents adds the code that publishes the given kind of
+// change to every instance in the slice of entities in scope
+func PublishEntitiesEvents(e *Entity, event string, g *Group) {
+	g.For(
+		List(Id("_"), Id(e.VarName())).Op(":=").Range().Id(VarName(e.PluralName())),
+	).Block(
+		Id("r").Dot("Events").Dot("Publish").Call(NewEvent(e, event, Id(e.VarName()))),
+	)
+}
+
+// QueueEvent adds the code that queues the given kind of change to the
+// given instance of an entity, to be published once the transaction of
+// the mutation is committed
+func QueueEvent(e *Entity, event string, data *Statement, g *Group) {
+	g.Op("*").Id("changes").Op("=").Append(Op("*").Id("changes"), NewEvent(e, event, data))
+}
+
+// SubscriptionResolverFunName returns the name of the resolver of the
+// subscription to the given kind of change to instances of the given
+// entity
+func SubscriptionResolverFunName(e *Entity, event string) string {
+	return strings.Title(GraphqlSubscriptionName(e, event))
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+
+	. "github.com/dave/jennifer/jen"
+)
+
+// CreateReplicas generates a Golang file that contains the routing of
+// reads to read replicas of the database
+func CreateReplicas(p *Package) error {
+	f := NewFile(p.Name)
+
+	f.PackageComment(fmt.Sprintf("%s contains all the library code for the platform", p.Name))
+	f.PackageComment("This file contains the routing of reads to read replicas")
+	f.PackageComment(" ** THIS CODE IS MACHINE GENERATED. DO NOT EDIT MANUALLY ** ")
+
+	f.ImportAlias("database/sql", "sql")
+
+	AddReplicaCheckVars(f)
+	AddReplicaStruct(f)
+	AddReplicasStruct(f)
+	AddNewReplicasFun(f)
+	AddReplicasNextFun(f)
+	AddReplicasCheckFun(f)
+	AddReplicasWatchFun(f)
+	AddReadYourWritesFuns(f)
+	AddReplicasHandlerFun(f)
+
+	return f.Save(p.Filename)
+}
+
+// AddReplicaCheckVars declares how often, and for how long, replicas
+// are checked
+func AddReplicaCheckVars(f *File) {
+	f.Comment("ReplicaCheckInterval is the time between two health checks of the replicas")
+	f.Var().Id("ReplicaCheckInterval").Op("=").Lit(10).Op("*").Qual("time", "Second")
+
+	f.Comment("ReplicaCheckTimeout is the time a replica has to answer a health check")
+	f.Var().Id("ReplicaCheckTimeout").Op("=").Lit(2).Op("*").Qual("time", "Second")
+
+	f.Comment("ReadYourWritesHeader is the request header that routes all reads of a request to the primary, eg. right after a mutation sent in a previous request")
+	f.Const().Id("ReadYourWritesHeader").Op("=").Lit("X-Read-Your-Writes")
+}
+
+// AddReplicaStruct generates the struct that holds a read replica,
+// with its own prepared statements
+func AddReplicaStruct(f *File) {
+	f.Comment("Replica is a read replica of the database")
+	f.Type().Id("Replica").Struct(
+		Id("Db").Op("*").Qual("database/sql", "DB"),
+		Id("Statements").Op("*").Id("Statements"),
+		Id("down").Int32(),
+	)
+}
+
+// AddReplicasStruct generates the set of replicas that reads are
+// routed to
+func AddReplicasStruct(f *File) {
+	f.Comment("Replicas routes reads to a set of read replicas, in round-robin order. Replicas that fail a health check are skipped until they pass one again")
+	f.Type().Id("Replicas").Struct(
+		Id("replicas").Index().Op("*").Id("Replica"),
+		Id("next").Uint32(),
+	)
+}
+
+// AddNewReplicasFun generates the function that opens all replicas,
+// and prepares their statements, at startup
+func AddNewReplicasFun(f *File) {
+	funName := "NewReplicas"
+
+	f.Comment(fmt.Sprintf("%s opens the replicas with the given connection strings, and prepares their statements. Empty connection strings are skipped, and nil is returned if there are no replicas at all", funName))
+	f.Func().Id(funName).Params(
+		Id("ctx").Qual("context", "Context"),
+		Id("dsns").Index().String(),
+	).Parens(List(
+		Op("*").Id("Replicas"),
+		Error(),
+	)).BlockFunc(func(g *Group) {
+		g.Id("replicas").Op(":=").Index().Op("*").Id("Replica").Values()
+		g.For(List(Id("_"), Id("dsn")).Op(":=").Range().Id("dsns")).Block(
+			If(Id("dsn").Op("==").Lit("")).Block(
+				Continue(),
+			),
+
+			List(Id("db"), Err()).Op(":=").Id("NewDb").Call(Id("dsn")),
+			If(Err().Op("!=").Nil()).Block(
+				Return(Nil(), Err()),
+			),
+
+			List(Id("stmts"), Err()).Op(":=").Id("PrepareStatements").Call(Id("ctx"), Id("db"), Id("DialectFromDsn").Call(Id("dsn"))),
+			If(Err().Op("!=").Nil()).Block(
+				Return(Nil(), Err()),
+			),
+
+			Id("replicas").Op("=").Append(Id("replicas"), Op("&").Id("Replica").Values(Dict{
+				Id("Db"):         Id("db"),
+				Id("Statements"): Id("stmts"),
+			})),
+		)
+
+		g.If(Len(Id("replicas")).Op("==").Lit(0)).Block(
+			Return(Nil(), Nil()),
+		)
+
+		g.Return(Op("&").Id("Replicas").Values(Dict{Id("replicas"): Id("replicas")}), Nil())
+	})
+}
+
+// AddReplicasNextFun generates the method that picks the replica for
+// the next read
+func AddReplicasNextFun(f *File) {
+	f.Comment("Next returns the next healthy replica, or nil if there is none")
+	f.Func().Parens(Id("r").Op("*").Id("Replicas")).Id("Next").Params().Op("*").Id("Replica").BlockFunc(func(g *Group) {
+		g.If(Id("r").Op("==").Nil()).Block(
+			Return(Nil()),
+		)
+
+		g.For(Id("i").Op(":=").Lit(0), Id("i").Op("<").Len(Id("r").Dot("replicas")), Id("i").Op("++")).Block(
+			Id("n").Op(":=").Qual("sync/atomic", "AddUint32").Call(Op("&").Id("r").Dot("next"), Lit(1)),
+			Id("replica").Op(":=").Id("r").Dot("replicas").Index(Int().Call(Id("n").Op("%").Uint32().Call(Len(Id("r").Dot("replicas"))))),
+			If(Qual("sync/atomic", "LoadInt32").Call(Op("&").Id("replica").Dot("down")).Op("==").Lit(0)).Block(
+				Return(Id("replica")),
+			),
+		)
+
+		g.Return(Nil())
+	})
+}
+
+// AddReplicasCheckFun generates the method that checks the health of
+// every replica
+func AddReplicasCheckFun(f *File) {
+	f.Comment("Check pings every replica, and marks the ones that do not answer in time as down")
+	f.Func().Parens(Id("r").Op("*").Id("Replicas")).Id("Check").Params(
+		Id("ctx").Qual("context", "Context"),
+	).BlockFunc(func(g *Group) {
+		g.If(Id("r").Op("==").Nil()).Block(
+			Return(),
+		)
+
+		g.For(List(Id("i"), Id("replica")).Op(":=").Range().Id("r").Dot("replicas")).Block(
+			List(Id("check"), Id("cancel")).Op(":=").Qual("context", "WithTimeout").Call(Id("ctx"), Id("ReplicaCheckTimeout")),
+			Err().Op(":=").Id("replica").Dot("Db").Dot("PingContext").Call(Id("check")),
+			Id("cancel").Call(),
+
+			Id("down").Op(":=").Int32().Call(Lit(0)),
+			If(Err().Op("!=").Nil()).Block(
+				Id("down").Op("=").Lit(1),
+			),
+
+			// connection strings may hold credentials, so replicas are
+			// logged by position
+			If(Qual("sync/atomic", "SwapInt32").Call(Op("&").Id("replica").Dot("down"), Id("down")).Op("!=").Id("down")).Block(
+				If(Err().Op("!=").Nil()).Block(
+					Qual("log", "Printf").Call(Lit("Replica %d is down: %v"), Id("i"), Err()),
+				).Else().Block(
+					Qual("log", "Printf").Call(Lit("Replica %d is up"), Id("i")),
+				),
+			),
+		)
+	})
+}
+
+// AddReplicasWatchFun generates the method that checks the replicas
+// periodically
+func AddReplicasWatchFun(f *File) {
+	f.Comment("Watch checks the replicas every ReplicaCheckInterval, until the given context is done")
+	f.Func().Parens(Id("r").Op("*").Id("Replicas")).Id("Watch").Params(
+		Id("ctx").Qual("context", "Context"),
+	).BlockFunc(func(g *Group) {
+		g.If(Id("r").Op("==").Nil()).Block(
+			Return(),
+		)
+
+		g.Id("ticker").Op(":=").Qual("time", "NewTicker").Call(Id("ReplicaCheckInterval"))
+		g.Defer().Id("ticker").Dot("Stop").Call()
+
+		g.For().Block(
+			Select().Block(
+				Case(Op("<-").Id("ticker").Dot("C")).Block(
+					Id("r").Dot("Check").Call(Id("ctx")),
+				),
+				Case(Op("<-").Id("ctx").Dot("Done").Call()).Block(
+					Return(),
+				),
+			),
+		)
+	})
+}
+
+// AddReadYourWritesFuns generates the functions that keep track of
+// writes in the context of a request, so that reads that follow a
+// write see it, regardless of replication lag
+func AddReadYourWritesFuns(f *File) {
+	f.Comment("ReadYourWritesContextKey is the key of the write marker in the context of a request")
+	f.Type().Id("ReadYourWritesContextKey").Struct()
+
+	f.Comment("WithReadYourWrites returns a copy of the given context in which reads are routed to the primary once a write happens. If primary is set, all reads are routed to the primary")
+	f.Func().Id("WithReadYourWrites").Params(
+		Id("ctx").Qual("context", "Context"),
+		Id("primary").Bool(),
+	).Qual("context", "Context").Block(
+		Id("written").Op(":=").New(Int32()),
+		If(Id("primary")).Block(
+			Op("*").Id("written").Op("=").Lit(1),
+		),
+		Return(Qual("context", "WithValue").Call(
+			Id("ctx"),
+			Id("ReadYourWritesContextKey").Values(),
+			Id("written"),
+		)),
+	)
+
+	f.Comment("MarkWritten records a write in the given context, so that the reads that follow are routed to the primary")
+	f.Func().Id("MarkWritten").Params(
+		Id("ctx").Qual("context", "Context"),
+	).Block(
+		If(
+			List(Id("written"), Id("ok")).Op(":=").Id("ctx").Dot("Value").Call(Id("ReadYourWritesContextKey").Values()).Assert(Op("*").Int32()),
+			Id("ok"),
+		).Block(
+			Qual("sync/atomic", "StoreInt32").Call(Id("written"), Lit(1)),
+		),
+	)
+
+	f.Comment("ReadsFromPrimary returns whether reads in the given context must be routed to the primary")
+	f.Func().Id("ReadsFromPrimary").Params(
+		Id("ctx").Qual("context", "Context"),
+	).Bool().Block(
+		List(Id("written"), Id("ok")).Op(":=").Id("ctx").Dot("Value").Call(Id("ReadYourWritesContextKey").Values()).Assert(Op("*").Int32()),
+		Return(Id("ok").Op("&&").Qual("sync/atomic", "LoadInt32").Call(Id("written")).Op("==").Lit(1)),
+	)
+}
+
+// AddReplicasHandlerFun generates the http middleware that tracks the
+// writes of every request
+func AddReplicasHandlerFun(f *File) {
+	funName := "ReplicasHandler"
+
+	f.Comment(fmt.Sprintf("%s wraps the given handler, so that the reads of every request that follow a write are routed to the primary. Requests that carry the ReadYourWritesHeader read from the primary only", funName))
+	f.Func().Id(funName).Params(
+		Id("h").Qual("net/http", "Handler"),
+	).Qual("net/http", "Handler").Block(
+		Return(Qual("net/http", "HandlerFunc").Call(
+			Func().Params(
+				Id("w").Qual("net/http", "ResponseWriter"),
+				Id("r").Op("*").Qual("net/http", "Request"),
+			).Block(
+				Id("primary").Op(":=").Id("r").Dot("Header").Dot("Get").Call(Id("ReadYourWritesHeader")).Op("!=").Lit(""),
+				Id("h").Dot("ServeHTTP").Call(
+					Id("w"),
+					Id("r").Dot("WithContext").Call(
+						Id("WithReadYourWrites").Call(
+							Id("r").Dot("Context").Call(),
+							Id("primary"),
+						),
+					),
+				),
+			),
+		)),
+	)
+}
@@ -20,13 +20,56 @@ func CreateRepo(p *Package) error {
 	f.ImportAlias("database/sql", "sql")
 	f.ImportAlias("io/ioutil", "ioutil")
 
+	AddDBTXInterface(f)
 	AddExecStatementsFun(f)
+	AddSqlValuesPlaceholdersFun(f)
 
 	AddRepoFuns(p.Model, f)
 
+	if ModelSupportsSearch(p.Model) {
+		AddSqlSearchQueryFun(f)
+	}
+
+	for _, e := range p.Model.Entities {
+		if SupportsSearch(e) {
+			AddSearchFun(e, f)
+		}
+	}
+
 	return f.Save(p.Filename)
 }
 
+// AddDBTXInterface generates the interface that all repository
+// functions are written against. Both *sql.DB and *sql.Tx satisfy it,
+// so repository functions can either run on their own, or be part of
+// a wider transaction, eg. together with hooks
+func AddDBTXInterface(f *File) {
+	name := "DBTX"
+
+	f.Comment(fmt.Sprintf("%s is the interface shared by *sql.DB and *sql.Tx. Repository functions accept it, so that they can run as part of a transaction opened by the caller", name))
+	f.Type().Id(name).Interface(
+		Id("ExecContext").Params(
+			Qual("context", "Context"),
+			String(),
+			Op("...").Interface(),
+		).Parens(List(Qual("database/sql", "Result"), Error())),
+		Id("PrepareContext").Params(
+			Qual("context", "Context"),
+			String(),
+		).Parens(List(Op("*").Qual("database/sql", "Stmt"), Error())),
+		Id("QueryContext").Params(
+			Qual("context", "Context"),
+			String(),
+			Op("...").Interface(),
+		).Parens(List(Op("*").Qual("database/sql", "Rows"), Error())),
+		Id("QueryRowContext").Params(
+			Qual("context", "Context"),
+			String(),
+			Op("...").Interface(),
+		).Op("*").Qual("database/sql", "Row"),
+	)
+}
+
 // AddExecStatementsFun generates a convenience function that accepts a
 // slice of sql statements, and runs them all, one by one
 func AddExecStatementsFun(f *File) {
@@ -34,10 +77,11 @@ func AddExecStatementsFun(f *File) {
 
 	f.Comment(fmt.Sprintf("%s runs all SQL statements in the given slice. No transaction is opened. This function is designed to run DLL such as DROP/CREATE table.", funName))
 	f.Func().Id(funName).Params(
+		Id("ctx").Qual("context", "Context"),
 		Id("db").Op("*").Qual("database/sql", "DB"),
 		Id("stmts").Op("[]").Id("string")).Error().BlockFunc(func(g *Group) {
 		g.For(List(Id("_"), Id("stmt")).Op(":=").Id("range").Id("stmts")).BlockFunc(func(g2 *Group) {
-			g2.List(Id("_"), Err()).Op(":=").Id("db").Dot("Exec").Call(Id("stmt"))
+			g2.List(Id("_"), Err()).Op(":=").Id("db").Dot("ExecContext").Call(Id("ctx"), Id("stmt"))
 			IfErrorReturn(g2)
 		})
 
@@ -45,6 +89,38 @@ func AddExecStatementsFun(f *File) {
 	})
 }
 
+// AddSqlValuesPlaceholdersFun generates a function that builds the
+// placeholders for a number of rows of values, as used in multi-row
+// INSERT statements or IN clauses, eg. ($1,$2),($3,$4)
+func AddSqlValuesPlaceholdersFun(f *File) {
+	funName := "SqlValuesPlaceholders"
+
+	f.Comment(fmt.Sprintf("%s returns the placeholders for the given number of rows, each one with the given number of columns", funName))
+	f.Func().Id(funName).Params(
+		Id("rows").Int(),
+		Id("cols").Int(),
+	).String().BlockFunc(func(g *Group) {
+		g.Id("chunks").Op(":=").Index().String().Values()
+		g.For(Id("i").Op(":=").Lit(0), Id("i").Op("<").Id("rows"), Id("i").Op("++")).BlockFunc(func(g2 *Group) {
+			g2.Id("placeholders").Op(":=").Index().String().Values()
+			g2.For(Id("j").Op(":=").Lit(1), Id("j").Op("<=").Id("cols"), Id("j").Op("++")).Block(
+				Id("placeholders").Op("=").Append(
+					Id("placeholders"),
+					Qual("fmt", "Sprintf").Call(Lit("$%v"), Id("i").Op("*").Id("cols").Op("+").Id("j")),
+				),
+			)
+			g2.Id("chunks").Op("=").Append(
+				Id("chunks"),
+				Qual("fmt", "Sprintf").Call(
+					Lit("(%s)"),
+					Qual("strings", "Join").Call(Id("placeholders"), Lit(",")),
+				),
+			)
+		})
+		g.Return(Qual("strings", "Join").Call(Id("chunks"), Lit(",")))
+	})
+}
+
 // ReadFile returns the code required to read a file
 func ReadFile(g *Group) {
 	g.List(Id("file"), Err()).Op(":=").Qual("io/ioutil", "ReadFile").Call(Id("path"))
@@ -57,17 +133,28 @@ func AddRepoFuns(m *Model, f *File) {
 		if e.SupportsOperation("create") {
 
 			AddInsertFun(m, e, f)
+			AddInsertManyFun(e, f)
 
 		}
 
 		if e.SupportsOperation("update") {
 
 			AddUpdateFun(e, f)
+			AddUpdateManyFun(e, f)
+
+			if SupportsPatch(e) {
+				AddPatchFun(e, f)
+			}
 		}
 
 		if e.SupportsOperation("delete") {
 
 			AddDeleteFun(e, f)
+			AddDeleteManyFun(e, f)
+		}
+
+		if e.SupportsOperation("upsert") {
+			AddUpsertFun(e, f)
 		}
 
 		if e.SupportsOperation("find") {
@@ -90,66 +177,247 @@ func AddInsertFun(m *Model, e *Entity, f *File) {
 
 	f.Comment(fmt.Sprintf("%s inserts an entity of type %s to the database", funName, e.Name))
 	f.Comment("This function also persists its relations to other linked entities")
-	f.Func().Id(funName).Params(Id("db").Op("*").Qual("database/sql", "DB"), Id(e.VarName()).Op("*").Id(e.Name)).Parens(List(Op("*").Id(e.Name), Error())).BlockFunc(func(g *Group) {
+	f.Func().Id(funName).Params(Id("ctx").Qual("context", "Context"), Id("db").Id("DBTX"), Id("stmts").Op("*").Id("Statements"), Id(e.VarName()).Op("*").Id(e.Name)).Parens(List(Op("*").Id(e.Name), Error())).BlockFunc(func(g *Group) {
 
-		// Open a transaction
-		BeginTransaction(g)
+		// insert statement for the entity
+		PrepareDbStatement(InsertEntityStatement(e), g)
 		IfErrorReturnEntityAndError(e, g)
 
-		DeferRollbackTransaction(g)
+		DeferCloseStatement(g)
 
-		// insert statement for the entity
-		PrepareTransactionStatement(InsertStatement(e), g)
+		// IDs from a sequence are returned by the database
+		if e.GeneratesIDInDatabase() {
+			g.Err().Op("=").Id("stmt").Dot("QueryRowContext").CallFunc(func(g2 *Group) {
+				g2.Id("ctx")
+				g2.ListFunc(func(g3 *Group) {
+					InsertStatementValues(e, g3)
+				})
+			}).Dot("Scan").Call(Op("&").Id(e.VarName()).Dot("ID"))
+		} else {
+			ExecuteStatement(g, func(g2 *Group) {
+				InsertStatementValues(e, g2)
+			})
+		}
 		IfErrorReturnEntityAndError(e, g)
+		ReturnEntityAndNil(e, g)
+	})
+}
 
-		DeferCloseStatement(g)
+// InsertManyEntitiesFunName returns the name of the bulk insert
+// function for the entity
+func InsertManyEntitiesFunName(e *Entity) string {
+	return fmt.Sprintf("CreateMany%s", e.PluralName())
+}
+
+// AddInsertManyFun produces the function that inserts a list of
+// entities to the database, using multi-row INSERT statements. Rows
+// are split in batches, so that each statement stays within the
+// maximum number of parameters supported by the database
+func AddInsertManyFun(e *Entity, f *File) {
+	if e.GeneratesIDInDatabase() {
+		AddInsertManyWithSequenceFun(e, f)
+		return
+	}
+
+	funName := InsertManyEntitiesFunName(e)
+	plural := VarName(e.PluralName())
+	cols := len(InsertColumns(e))
 
-		ExecuteStatement(g, func(g2 *Group) {
-			InsertStatementValues(e, g2)
+	f.Comment(fmt.Sprintf("%s inserts a list of entities of type %s to the database, in batches of multi-row INSERT statements", funName, e.Name))
+	f.Func().Id(funName).Params(
+		Id("ctx").Qual("context", "Context"),
+		Id("db").Id("DBTX"),
+		Id("stmts").Op("*").Id("Statements"),
+		Id(plural).Index().Op("*").Id(e.Name),
+	).Parens(List(
+		Index().Op("*").Id(e.Name),
+		Error(),
+	)).BlockFunc(func(g *Group) {
+
+		g.Id("size").Op(":=").Id("SqlMaxParams").Call(Id("stmts").Dot("Dialect").Call()).Op("/").Lit(cols)
+		ForEachBatch(plural, g, func(g2 *Group) {
+			g2.Id("args").Op(":=").Index().Interface().Values()
+			g2.For(
+				List(Id("_"), Id(e.VarName())).Op(":=").Range().Id(plural).Index(Id("start").Op(":").Id("end")),
+			).Block(
+				Id("args").Op("=").Append(
+					Id("args"),
+					ListFunc(func(g3 *Group) {
+						InsertStatementValues(e, g3)
+					}),
+				),
+			)
+
+			ExecBatchStatement(Lit(fmt.Sprintf("%s ", InsertStatementPrefix(e))).Op("+").Id("SqlValuesPlaceholders").Call(
+				Id("end").Op("-").Id("start"),
+				Lit(cols),
+			), plural, g2)
 		})
-		IfErrorReturnEntityAndError(e, g)
 
-		CommitTransaction(g)
-		IfErrorReturnEntityAndError(e, g)
-		ReturnEntityAndNil(e, g)
+		g.Return(Id(plural), Nil())
 	})
 }
 
+// AddInsertManyWithSequenceFun produces the function that inserts a
+// list of entities whose IDs are assigned by the database. Rows are
+// inserted one at a time, since databases do not guarantee that
+// multi-row INSERT statements return IDs in the same order as rows
+func AddInsertManyWithSequenceFun(e *Entity, f *File) {
+	funName := InsertManyEntitiesFunName(e)
+	plural := VarName(e.PluralName())
+
+	f.Comment(fmt.Sprintf("%s inserts a list of entities of type %s to the database, one at a time, and sets the IDs assigned to them", funName, e.Name))
+	f.Func().Id(funName).Params(
+		Id("ctx").Qual("context", "Context"),
+		Id("db").Id("DBTX"),
+		Id("stmts").Op("*").Id("Statements"),
+		Id(plural).Index().Op("*").Id(e.Name),
+	).Parens(List(
+		Index().Op("*").Id(e.Name),
+		Error(),
+	)).BlockFunc(func(g *Group) {
+		PrepareDbStatement(InsertEntityStatement(e), g)
+		g.If(Err().Op("!=").Nil()).Block(Return(Id(plural), Err()))
+
+		DeferCloseStatement(g)
+
+		g.For(
+			List(Id("_"), Id(e.VarName())).Op(":=").Range().Id(plural),
+		).Block(
+			Err().Op("=").Id("stmt").Dot("QueryRowContext").CallFunc(func(g2 *Group) {
+				g2.Id("ctx")
+				g2.ListFunc(func(g3 *Group) {
+					InsertStatementValues(e, g3)
+				})
+			}).Dot("Scan").Call(Op("&").Id(e.VarName()).Dot("ID")),
+			If(Err().Op("!=").Nil()).Block(Return(Id(plural), Err())),
+		)
+
+		g.Return(Id(plural), Nil())
+	})
+}
+
+// ForEachBatch produces a loop over the given slice variable, in
+// batches of the size held by the "size" variable. The given function
+// generates the body of the loop, where the batch is delimited by the
+// "start" and "end" variables
+func ForEachBatch(slice string, g *Group, body func(*Group)) {
+	g.For(
+		Id("start").Op(":=").Lit(0),
+		Id("start").Op("<").Len(Id(slice)),
+		Id("start").Op("+=").Id("size"),
+	).BlockFunc(func(g2 *Group) {
+		g2.Id("end").Op(":=").Id("start").Op("+").Id("size")
+		g2.If(Id("end").Op(">").Len(Id(slice))).Block(
+			Id("end").Op("=").Len(Id(slice)),
+		)
+
+		body(g2)
+	})
+}
+
+// ExecBatchStatement produces the code that runs the given query for
+// a batch, with the args in scope. The statement is closed before the
+// next batch is run, and errors are returned along with the given
+// slice
+func ExecBatchStatement(query Code, slice string, g *Group) {
+	PrepareStatement(query, g)
+	g.If(Err().Op("!=").Nil()).Block(Return(Id(slice), Err()))
+
+	g.List(Id("_"), Err()).Op("=").Id("stmt").Dot("ExecContext").Call(Id("ctx"), Id("args").Op("..."))
+	g.Id("stmt").Dot("Close").Call()
+	g.If(Err().Op("!=").Nil()).Block(Return(Id(slice), Err()))
+}
+
 // UpdateEntityFunName returns the name of the update function for the
 // entity
 func UpdateEntityFunName(e *Entity) string {
 	return fmt.Sprintf("Update%s", e.Name)
 }
 
-// AddInsertFun produces the function that inserts the given
-// entity to the database.
+// AddUpdateFun produces the function that updates the given
+// entity in the database.
 func AddUpdateFun(e *Entity, f *File) {
 	funName := UpdateEntityFunName(e)
 
-	f.Comment(fmt.Sprintf("%s updates an existing entity of type %s into the database", funName, e.Name))
-	f.Func().Id(funName).Params(Id("db").Op("*").Qual("database/sql", "DB"), Id(e.VarName()).Op("*").Id(e.Name)).Parens(List(Op("*").Id(e.Name), Error())).BlockFunc(func(g *Group) {
+	f.Comment(fmt.Sprintf("%s updates an existing entity of type %s into the database. If no row matches it, then this function returns a not found error", funName, e.Name))
+	f.Func().Id(funName).Params(Id("ctx").Qual("context", "Context"), Id("db").Id("DBTX"), Id("stmts").Op("*").Id("Statements"), Id(e.VarName()).Op("*").Id(e.Name)).Parens(List(Op("*").Id(e.Name), Error())).BlockFunc(func(g *Group) {
 
-		// Open a transaction
-		BeginTransaction(g)
-		IfErrorReturnEntityAndError(e, g)
-
-		DeferRollbackTransaction(g)
-		PrepareTransactionStatement(UpdateStatement(e), g)
+		PrepareDbStatement(UpdateStatement(e), g)
 		IfErrorReturnEntityAndError(e, g)
 
 		DeferCloseStatement(g)
 
-		ExecuteStatement(g, func(g2 *Group) {
-			UpdateStatementValues(e, g2)
+		g.List(Id("result"), Err()).Op(":=").Id("stmt").Dot("ExecContext").CallFunc(func(g2 *Group) {
+			g2.Id("ctx")
+			g2.ListFunc(func(g3 *Group) {
+				UpdateStatementValues(e, g3)
+			})
 		})
 		IfErrorReturnEntityAndError(e, g)
 
-		CommitTransaction(g)
+		g.List(Id("affected"), Err()).Op(":=").Id("result").Dot("RowsAffected").Call()
 		IfErrorReturnEntityAndError(e, g)
+
+		g.If(Id("affected").Op("==").Lit(0)).Block(
+			Return(Id(e.VarName()), NotFoundError(e, Id(e.VarName()).Dot("ID"))),
+		)
 		ReturnEntityAndNil(e, g)
 	})
 }
 
+// UpdateManyEntitiesFunName returns the name of the bulk update
+// function for the entity
+func UpdateManyEntitiesFunName(e *Entity) string {
+	return fmt.Sprintf("UpdateMany%s", e.PluralName())
+}
+
+// AddUpdateManyFun produces the function that updates a list of
+// entities in the database. The UPDATE statement is prepared once, and
+// executed for each entity
+func AddUpdateManyFun(e *Entity, f *File) {
+	funName := UpdateManyEntitiesFunName(e)
+	plural := VarName(e.PluralName())
+
+	f.Comment(fmt.Sprintf("%s updates a list of existing entities of type %s into the database. If no row matches one of them, then this function returns a not found error", funName, e.Name))
+	f.Func().Id(funName).Params(
+		Id("ctx").Qual("context", "Context"),
+		Id("db").Id("DBTX"),
+		Id("stmts").Op("*").Id("Statements"),
+		Id(plural).Index().Op("*").Id(e.Name),
+	).Parens(List(
+		Index().Op("*").Id(e.Name),
+		Error(),
+	)).BlockFunc(func(g *Group) {
+
+		PrepareDbStatement(UpdateStatement(e), g)
+		g.If(Err().Op("!=").Nil()).Block(Return(Id(plural), Err()))
+
+		DeferCloseStatement(g)
+
+		g.For(
+			List(Id("_"), Id(e.VarName())).Op(":=").Range().Id(plural),
+		).BlockFunc(func(g2 *Group) {
+			g2.List(Id("result"), Err()).Op(":=").Id("stmt").Dot("ExecContext").CallFunc(func(g3 *Group) {
+				g3.Id("ctx")
+				g3.ListFunc(func(g4 *Group) {
+					UpdateStatementValues(e, g4)
+				})
+			})
+			g2.If(Err().Op("!=").Nil()).Block(Return(Id(plural), Err()))
+
+			g2.List(Id("affected"), Err()).Op(":=").Id("result").Dot("RowsAffected").Call()
+			g2.If(Err().Op("!=").Nil()).Block(Return(Id(plural), Err()))
+
+			g2.If(Id("affected").Op("==").Lit(0)).Block(
+				Return(Id(plural), NotFoundError(e, Id(e.VarName()).Dot("ID"))),
+			)
+		})
+
+		g.Return(Id(plural), Nil())
+	})
+}
+
 // DeleteEntityFunName returns the name of the delete function for the
 // entity
 func DeleteEntityFunName(e *Entity) string {
@@ -161,9 +429,11 @@ func DeleteEntityFunName(e *Entity) string {
 func AddDeleteFun(e *Entity, f *File) {
 	funName := DeleteEntityFunName(e)
 
-	f.Comment(fmt.Sprintf("%s deletes an existing entity of type %s from the database, by its id", funName, e.Name))
+	f.Comment(fmt.Sprintf("%s deletes an existing entity of type %s from the database, by its id, and returns it as it was stored. If no row matches, then this function returns sql.ErrNoRows", funName, e.Name))
 	f.Func().Id(funName).Params(
-		Id("db").Op("*").Qual("database/sql", "DB"),
+		Id("ctx").Qual("context", "Context"),
+		Id("db").Id("DBTX"),
+		Id("stmts").Op("*").Id("Statements"),
 		Id("id").String(),
 	).Parens(
 		List(Op("*").Id(e.Name),
@@ -172,21 +442,135 @@ func AddDeleteFun(e *Entity, f *File) {
 
 		g.Add(EmptyStructForEntity(e))
 
-		BeginTransaction(g)
+		// the row is loaded first, so that it can be returned once
+		// deleted. Within a transaction, no one else can delete it in
+		// between
+		g.List(Id("find"), Err()).Op(":=").Id("stmts").Dot("Prepare").Call(Id("ctx"), Id("db"), Lit(SelectByColumnFromStatement(e, "id")))
+		IfErrorReturnEntityAndError(e, g)
+
+		DeferCall("find", "Close", g)
+
+		g.Err().Op("=").Id("find").Dot("QueryRowContext").Call(Id("ctx"), Id("id")).Dot("Scan").Call(ListFunc(
+			ScanRowIntoEntityStruct(e),
+		))
 		IfErrorReturnEntityAndError(e, g)
 
-		DeferRollbackTransaction(g)
-		PrepareTransactionStatement(DeleteStatement(e), g)
+		PrepareDbStatement(DeleteStatement(e), g)
 		IfErrorReturnEntityAndError(e, g)
 
 		DeferCloseStatement(g)
 
-		ExecuteStatement(g, func(g2 *Group) {
-			DeleteStatementValues(e, g2)
+		g.List(Id("result"), Err()).Op(":=").Id("stmt").Dot("ExecContext").CallFunc(func(g2 *Group) {
+			g2.Id("ctx")
+			g2.ListFunc(func(g3 *Group) {
+				DeleteStatementValues(e, g3)
+			})
 		})
 		IfErrorReturnEntityAndError(e, g)
 
-		CommitTransaction(g)
+		g.List(Id("affected"), Err()).Op(":=").Id("result").Dot("RowsAffected").Call()
+		IfErrorReturnEntityAndError(e, g)
+
+		g.If(Id("affected").Op("==").Lit(0)).Block(
+			Return(Id(e.VarName()), Qual("database/sql", "ErrNoRows")),
+		)
+
+		ReturnEntityAndNil(e, g)
+	})
+}
+
+// DeleteManyEntitiesFunName returns the name of the bulk delete
+// function for the entity
+func DeleteManyEntitiesFunName(e *Entity) string {
+	return fmt.Sprintf("DeleteMany%s", e.PluralName())
+}
+
+// AddDeleteManyFun produces the function that deletes a list of
+// entities from the database, by their ids. Ids are split in batches,
+// so that each DELETE statement stays within the maximum number of
+// parameters supported by the database
+func AddDeleteManyFun(e *Entity, f *File) {
+	funName := DeleteManyEntitiesFunName(e)
+	plural := VarName(e.PluralName())
+
+	f.Comment(fmt.Sprintf("%s deletes a list of existing entities of type %s from the database, by their ids, and returns them as they were stored. If no row matches one of the ids, then this function returns a not found error", funName, e.Name))
+	f.Func().Id(funName).Params(
+		Id("ctx").Qual("context", "Context"),
+		Id("db").Id("DBTX"),
+		Id("stmts").Op("*").Id("Statements"),
+		Id("ids").Index().String(),
+	).Parens(List(
+		Index().Op("*").Id(e.Name),
+		Error(),
+	)).BlockFunc(func(g *Group) {
+
+		g.Id(plural).Op(":=").Index().Op("*").Id(e.Name).Values()
+		g.Id("size").Op(":=").Id("SqlMaxParams").Call(Id("stmts").Dot("Dialect").Call())
+		ForEachBatch("ids", g, func(g2 *Group) {
+			BatchArgsFromIDs(g2)
+
+			// the rows of the batch are loaded before they are deleted,
+			// so that ids that match no row are found
+			g2.Id("loaded").Op(":=").Len(Id(plural))
+			g2.BlockFunc(func(g3 *Group) {
+				g3.Id("query").Op(":=").Lit(SelectAllStatement(e)+" WHERE id IN ").Op("+").Id("SqlValuesPlaceholders").Call(
+					Lit(1),
+					Id("end").Op("-").Id("start"),
+				)
+
+				QueryEntitiesBatch(e, g3)
+			})
+
+			g2.Id("found").Op(":=").Map(String()).Bool().Values()
+			g2.For(List(Id("_"), Id(e.VarName())).Op(":=").Range().Id(plural).Index(Id("loaded").Op(":"))).Block(
+				Id("found").Index(Id(e.VarName()).Dot("ID")).Op("=").True(),
+			)
+			g2.For(List(Id("_"), Id("id")).Op(":=").Range().Id("ids").Index(Id("start").Op(":").Id("end"))).Block(
+				If(Op("!").Id("found").Index(Id("id"))).Block(
+					Return(Id(plural), NotFoundError(e, Id("id"))),
+				),
+			)
+
+			ExecBatchStatement(Lit(fmt.Sprintf("DELETE FROM %s WHERE id IN ", TableName(e))).Op("+").Id("SqlValuesPlaceholders").Call(
+				Lit(1),
+				Id("end").Op("-").Id("start"),
+			), plural, g2)
+		})
+
+		g.Return(Id(plural), Nil())
+	})
+}
+
+// UpsertEntityFunName returns the name of the upsert function for the
+// entity
+func UpsertEntityFunName(e *Entity) string {
+	return fmt.Sprintf("Upsert%s", e.Name)
+}
+
+// AddUpsertFun produces the function that inserts the given entity to
+// the database, or updates the existing row that has the same value for
+// the upsert key. The stored row is returned
+func AddUpsertFun(e *Entity, f *File) {
+	funName := UpsertEntityFunName(e)
+
+	f.Comment(fmt.Sprintf("%s inserts an entity of type %s to the database, or updates it if another one exists with the same %s", funName, e.Name, e.UpsertAttribute().Name))
+	f.Func().Id(funName).Params(Id("ctx").Qual("context", "Context"), Id("db").Id("DBTX"), Id("stmts").Op("*").Id("Statements"), Id(e.VarName()).Op("*").Id(e.Name)).Parens(List(Op("*").Id(e.Name), Error())).BlockFunc(func(g *Group) {
+
+		PrepareDbStatement(UpsertStatement(e), g)
+		IfErrorReturnEntityAndError(e, g)
+
+		DeferCloseStatement(g)
+
+		g.Err().Op("=").Id("stmt").Dot("QueryRowContext").CallFunc(func(g2 *Group) {
+			g2.Id("ctx")
+			g2.ListFunc(func(g3 *Group) {
+				InsertStatementValues(e, g3)
+			})
+		}).Dot("Scan").Call(ListFunc(
+			ScanRowIntoEntityStruct(e),
+		))
+
+		IfErrorReturnEntityAndError(e, g)
 		ReturnEntityAndNil(e, g)
 	})
 }
@@ -197,16 +581,36 @@ func AddFindFuns(e *Entity, f *File) {
 	for _, a := range e.Attributes {
 		if a.HasModifier("unique") && a.HasModifier("indexed") {
 			AddFindByAttributeFun(e, a, f)
+		} else if a.HasModifier("indexed") {
+			AddFindListByAttributeFun(e, a, f)
+
+			if e.SupportsCursorPagination() {
+				AddFindListByAttributeConnectionFun(e, a, f)
+			}
 		}
 	}
 
+	AddFindByIDsFun(e, f)
+
 	for _, r := range e.Relations {
 		if r.HasModifier("hasOne") || r.HasModifier("belongsTo") {
 			AddFindByRelationFun(e, r, f)
+			AddFindByRelationIDsFun(e, r, f)
+
+			if e.SupportsCursorPagination() {
+				AddFindByRelationConnectionFun(e, r, f)
+			}
 		}
 	}
 
 	AddFindAllFun(e, f)
+
+	if e.SupportsCursorPagination() {
+		AddFindAllConnectionFun(e, f)
+	}
+
+	AddCountFun(e, f)
+	AddAggregateFun(e, f)
 }
 
 // FindEntityByAttributeFunName returns the name of the finder function for the given
@@ -218,6 +622,31 @@ func FindEntityByAttributeFunName(e *Entity, a *Attribute) string {
 // AddFindAllFun produces a finder function that returns instances
 // of the given entity
 func AddFindAllFun(e *Entity, f *File) {
+	funName := FindAllFunName(e)
+	f.Comment(fmt.Sprintf("%s finds all instances of type %s that match the given filter, in the given order. If no row matches, then this function returns an empty slice", funName, e.Name))
+	f.Func().Id(funName).Params(
+		Id("ctx").Qual("context", "Context"),
+		Id("db").Id("DBTX"),
+		Id("stmts").Op("*").Id("Statements"),
+		Id("filter").Op("*").Id(EntityFilterName(e)),
+		Id("orderBy").Index().Op("*").Id("SortOrder"),
+		Id("limit").Int32(),
+		Id("offset").Int32(),
+	).Parens(List(
+		Op("[]").Op("*").Id(e.Name),
+		Error(),
+	)).BlockFunc(func(g *Group) {
+		QueryEntities(e, SelectAllStatement(e), "WHERE", []Code{}, g)
+	})
+}
+
+// QueryEntities produces the code that runs a paginated SELECT
+// statement for the given entity, and scans all rows into a slice. The
+// filter in scope is compiled and appended to the given statement,
+// using the given keyword, and its values are bound after the given
+// args. Results are sorted by the sort orders in scope, or by the
+// preferred sort attribute of the entity if none are given
+func QueryEntities(e *Entity, sql string, keyword string, args []Code, g *Group) {
 
 	// error handling code to be used in different points of this
 	// function body
@@ -228,65 +657,380 @@ func AddFindAllFun(e *Entity, f *File) {
 		),
 	)
 
-	funName := FindAllFunName(e)
-	f.Comment(fmt.Sprintf("%s finds all instances of type %s. If no row matches, then this function returns an empty slice", funName, e.Name))
+	g.Id(VarName(e.PluralName())).Op(":=").Op("[]").Op("*").Id(e.Name).Values(Dict{})
+
+	g.Id("args").Op(":=").Index().Interface().Values(args...)
+	g.Id("query").Op(":=").Lit(sql)
+	g.If(
+		Id("where").Op(":=").Id("filter").Dot("Where").Call(Op("&").Id("args")),
+		Id("where").Op("!=").Lit(""),
+	).Block(
+		Id("query").Op("=").Id("query").Op("+").Lit(fmt.Sprintf(" %s ", keyword)).Op("+").Id("where"),
+	)
+
+	g.List(
+		Id("order"),
+		Err(),
+	).Op(":=").Id("SqlOrderBy").Call(
+		Id("orderBy"),
+		Id(EntitySortColumnsName(e)),
+		Lit(AttributeColumnName(e.PreferredSort())),
+	)
+	g.Add(ifErrReturn)
+
+	// limit and offset are bound last, so that the statement is the
+	// same for every page
+	g.Id("args").Op("=").Append(Id("args"), Id("limit"), Id("offset"))
+	PrepareStatement(Qual("fmt", "Sprintf").Call(
+		Lit(listStatementFormat),
+		Id("query"),
+		Id("order"),
+		Len(Id("args")).Op("-").Lit(1),
+		Len(Id("args")),
+	), g)
+
+	g.Add(ifErrReturn)
+	DeferCall("stmt", "Close", g)
+
+	g.List(
+		Id("rows"),
+		Err(),
+	).Op(":=").Id("stmt").Dot("QueryContext").Call(Id("ctx"), Id("args").Op("..."))
+	g.Add(ifErrReturn)
+
+	DeferCall("rows", "Close", g)
+
+	g.For(
+		Id("rows").Dot("Next").Call(),
+	).BlockFunc(func(g2 *Group) {
+
+		g2.Add(EmptyStructForEntity(e))
+		g2.Err().Op(":=").Id("rows").Dot("Scan").Call(ListFunc(
+			ScanRowIntoEntityStruct(e),
+		))
+
+		g2.Add(ifErrReturn)
+		g2.Id(VarName(e.PluralName())).Op("=").Append(Id(VarName(e.PluralName())), Id(e.VarName()))
+	})
+
+	g.Return(List(
+		Id(VarName(e.PluralName())),
+		Nil(),
+	))
+}
+
+// AddFindAllConnectionFun produces a finder function that returns a
+// page of instances of the given entity, selected with a cursor
+func AddFindAllConnectionFun(e *Entity, f *File) {
+	funName := FindAllConnectionFunName(e)
+	f.Comment(fmt.Sprintf("%s finds a page of instances of type %s that match the given filter, in the given order. Pages are selected using keyset pagination, so results are stable while rows are inserted or deleted", funName, e.Name))
 	f.Func().Id(funName).Params(
-		Id("db").Op("*").Qual("database/sql", "DB"),
-		Id("limit").Int32(),
-		Id("offset").Int32(),
+		Id("ctx").Qual("context", "Context"),
+		Id("db").Id("DBTX"),
+		Id("stmts").Op("*").Id("Statements"),
+		Id("filter").Op("*").Id(EntityFilterName(e)),
+		Id("orderBy").Index().Op("*").Id("SortOrder"),
+		Id("page").Op("*").Id("Page"),
 	).Parens(List(
-		Op("[]").Op("*").Id(e.Name),
+		Op("*").Id(EntityConnectionName(e)),
 		Error(),
 	)).BlockFunc(func(g *Group) {
+		QueryEntitiesConnection(e, SelectAllStatement(e), "WHERE", []Code{}, g)
+	})
+}
 
-		g.Id(VarName(e.PluralName())).Op(":=").Op("[]").Op("*").Id(e.Name).Values(Dict{})
+// FindAllConnectionFunName returns the name of the function that finds
+// a page of instances of the given entity
+func FindAllConnectionFunName(e *Entity) string {
+	return fmt.Sprintf("%sConnection", FindAllFunName(e))
+}
 
-		g.List(
-			Id("stmt"),
+// QueryEntitiesConnection produces the code that runs a SELECT
+// statement for a page of instances of the given entity, and builds a
+// connection from the results. Rows are sorted by the sort orders in
+// scope, completed with the ID, and the page cursors are compiled into
+// conditions on the sort columns. One extra row is fetched to tell
+// whether there are more results
+func QueryEntitiesConnection(e *Entity, sql string, keyword string, args []Code, g *Group) {
+	connection := EntityConnectionName(e)
+	columns := EntitySortColumnsName(e)
+	ifErrReturn := If(Err().Op("!=").Nil()).Block(
+		Return(
+			Id("connection"),
 			Err(),
-		).Op(":=").Id("db").Dot("Prepare").Call(
-			Qual("fmt", "Sprintf").Call(
-				Lit(fmt.Sprintf(
-					"%s ORDER BY %s ASC LIMIT %%v OFFSET %%v",
-					SelectAllStatement(e),
-					AttributeColumnName(e.PreferredSort()),
-				)),
-				Id("limit"),
-				Id("offset"),
+		),
+	)
+
+	g.Id("connection").Op(":=").Op("&").Id(connection).Values(Dict{
+		Id("Edges"):    Index().Op("*").Id(EntityEdgeName(e)).Values(),
+		Id("PageInfo"): Op("&").Id("PageInfo").Values(),
+	})
+
+	g.List(Id("limit"), Id("backward"), Err()).Op(":=").Id("PageSize").Call(Id("page"))
+	g.Add(ifErrReturn)
+
+	g.Id("orders").Op(":=").Id("KeysetOrders").Call(
+		Id("orderBy"),
+		Lit(AttributeSortField(e.PreferredSort())),
+		Lit(AttributeSortField(&Attribute{Name: "ID"})),
+	)
+
+	g.Id("sorted").Op(":=").Id("orders")
+	g.If(Id("backward")).Block(
+		Id("sorted").Op("=").Id("ReverseSortOrders").Call(Id("orders")),
+	)
+
+	g.List(Id("order"), Err()).Op(":=").Id("SqlOrderBy").Call(
+		Id("sorted"),
+		Id(columns),
+		Lit(AttributeColumnName(e.PreferredSort())),
+	)
+	g.Add(ifErrReturn)
+
+	g.Id("args").Op(":=").Index().Interface().Values(args...)
+	g.Id("conds").Op(":=").Index().String().Values()
+	g.If(
+		Id("where").Op(":=").Id("filter").Dot("Where").Call(Op("&").Id("args")),
+		Id("where").Op("!=").Lit(""),
+	).Block(
+		AppendCondition(Id("where")),
+	)
+
+	for _, cursor := range []string{"After", "Before"} {
+		g.If(Id("page").Dot(cursor).Op("!=").Nil()).Block(
+			List(Id("values"), Err()).Op(":=").Id(EntityCursorValuesFunName(e)).Call(
+				Op("*").Id("page").Dot(cursor),
+				Id("orders"),
 			),
+			ifErrReturn,
+			AppendCondition(Id("SqlKeysetCondition").Call(
+				Id("orders"),
+				Id(columns),
+				Id("values"),
+				Lit(cursor == "After"),
+				Op("&").Id("args"),
+			)),
 		)
+	}
 
-		g.Add(ifErrReturn)
+	g.Id("query").Op(":=").Lit(sql)
+	g.If(Len(Id("conds")).Op(">").Lit(0)).Block(
+		Id("query").Op("=").Id("query").Op("+").Lit(fmt.Sprintf(" %s ", keyword)).Op("+").Qual("strings", "Join").Call(Id("conds"), Lit(" AND ")),
+	)
+
+	g.Id("args").Op("=").Append(Id("args"), Id("limit").Op("+").Lit(1))
+	PrepareStatement(Qual("fmt", "Sprintf").Call(
+		Lit(pageStatementFormat),
+		Id("query"),
+		Id("order"),
+		Len(Id("args")),
+	), g)
+	g.Add(ifErrReturn)
+	DeferCall("stmt", "Close", g)
+
+	g.List(
+		Id("rows"),
+		Err(),
+	).Op(":=").Id("stmt").Dot("QueryContext").Call(Id("ctx"), Id("args").Op("..."))
+	g.Add(ifErrReturn)
+	DeferCall("rows", "Close", g)
+
+	g.Id(VarName(e.PluralName())).Op(":=").Op("[]").Op("*").Id(e.Name).Values()
+	g.For(
+		Id("rows").Dot("Next").Call(),
+	).BlockFunc(func(g2 *Group) {
+		g2.Add(EmptyStructForEntity(e))
+		g2.Err().Op(":=").Id("rows").Dot("Scan").Call(ListFunc(
+			ScanRowIntoEntityStruct(e),
+		))
+		g2.Add(ifErrReturn)
+		g2.Id(VarName(e.PluralName())).Op("=").Append(Id(VarName(e.PluralName())), Id(e.VarName()))
+	})
+
+	g.Id("more").Op(":=").Len(Id(VarName(e.PluralName()))).Op(">").Int().Call(Id("limit"))
+	g.If(Id("more")).Block(
+		Id(VarName(e.PluralName())).Op("=").Id(VarName(e.PluralName())).Index(Empty(), Id("limit")),
+	)
+
+	// rows selected backwards are fetched in reverse order
+	g.If(Id("backward")).Block(
+		For(
+			List(Id("i"), Id("j")).Op(":=").List(Lit(0), Len(Id(VarName(e.PluralName()))).Op("-").Lit(1)),
+			Id("i").Op("<").Id("j"),
+			List(Id("i"), Id("j")).Op("=").List(Id("i").Op("+").Lit(1), Id("j").Op("-").Lit(1)),
+		).Block(
+			List(
+				Id(VarName(e.PluralName())).Index(Id("i")),
+				Id(VarName(e.PluralName())).Index(Id("j")),
+			).Op("=").List(
+				Id(VarName(e.PluralName())).Index(Id("j")),
+				Id(VarName(e.PluralName())).Index(Id("i")),
+			),
+		),
+	)
+
+	g.Id("cursors").Op(":=").Index().String().Values()
+	g.For(List(Id("_"), Id(e.VarName())).Op(":=").Range().Id(VarName(e.PluralName()))).Block(
+		Id("cursor").Op(":=").Id("EncodeCursor").Call(
+			Id("orders"),
+			Id(EntitySortValuesFunName(e)).Call(Id(e.VarName()), Id("orders")),
+		),
+		Id("cursors").Op("=").Append(Id("cursors"), Id("cursor")),
+		Id("connection").Dot("Edges").Op("=").Append(Id("connection").Dot("Edges"), Op("&").Id(EntityEdgeName(e)).Values(Dict{
+			Id("Cursor"): Id("cursor"),
+			Id("Node"):   Id(e.VarName()),
+		})),
+	)
+
+	g.Id("connection").Dot("PageInfo").Op("=").Id("NewPageInfo").Call(Id("page"), Id("more"), Id("cursors"))
+	g.Return(Id("connection"), Nil())
+}
+
+// AddCountFun produces a function that counts the instances of the
+// given entity that match a filter
+func AddCountFun(e *Entity, f *File) {
+	funName := CountFunName(e)
+	f.Comment(fmt.Sprintf("%s counts the instances of type %s that match the given filter", funName, e.Name))
+	f.Func().Id(funName).Params(
+		Id("ctx").Qual("context", "Context"),
+		Id("db").Id("DBTX"),
+		Id("stmts").Op("*").Id("Statements"),
+		Id("filter").Op("*").Id(EntityFilterName(e)),
+	).Parens(List(
+		Int(),
+		Error(),
+	)).BlockFunc(func(g *Group) {
+		g.Id("count").Op(":=").Lit(0)
+
+		g.Id("args").Op(":=").Index().Interface().Values()
+		g.Id("query").Op(":=").Lit(CountStatement(e))
+		g.If(
+			Id("where").Op(":=").Id("filter").Dot("Where").Call(Op("&").Id("args")),
+			Id("where").Op("!=").Lit(""),
+		).Block(
+			Id("query").Op("=").Id("query").Op("+").Lit(" WHERE ").Op("+").Id("where"),
+		)
+
+		PrepareStatement(Id("query"), g)
+		g.If(Err().Op("!=").Nil()).Block(
+			Return(Id("count"), Err()),
+		)
 		DeferCall("stmt", "Close", g)
 
-		g.List(
-			Id("rows"),
-			Err(),
-		).Op(":=").Id("stmt").Dot("Query").Call()
-		g.Add(ifErrReturn)
+		g.Err().Op("=").Id("stmt").Dot("QueryRowContext").Call(Id("ctx"), Id("args").Op("...")).Dot("Scan").Call(Op("&").Id("count"))
+		g.Return(Id("count"), Err())
+	})
+}
 
-		DeferCall("rows", "Close", g)
+// CountFunName returns the name of the function that counts instances
+// of the given entity
+func CountFunName(e *Entity) string {
+	return fmt.Sprintf("Count%s", e.PluralName())
+}
 
-		g.For(
-			Id("rows").Dot("Next").Call(),
-		).BlockFunc(func(g2 *Group) {
+// AddAggregateFun produces a function that computes the count, and
+// numeric aggregates, of the instances of the given entity that match
+// a filter, optionally grouped by one or more fields
+func AddAggregateFun(e *Entity, f *File) {
+	funName := AggregateFunName(e)
+	aggregate := EntityAggregateName(e)
 
-			g2.Add(EmptyStructForEntity(e))
-			g2.Err().Op(":=").Id("rows").Dot("Scan").Call(ListFunc(
-				ScanRowIntoEntityStruct(e),
-			))
+	f.Comment(fmt.Sprintf("%s computes aggregates of the instances of type %s that match the given filter. A single aggregate is returned unless fields to group by are given, in which case there is an aggregate per group, sorted by the group fields", funName, e.Name))
+	f.Func().Id(funName).Params(
+		Id("ctx").Qual("context", "Context"),
+		Id("db").Id("DBTX"),
+		Id("stmts").Op("*").Id("Statements"),
+		Id("filter").Op("*").Id(EntityFilterName(e)),
+		Id("groupBy").Index().String(),
+	).Parens(List(
+		Index().Op("*").Id(aggregate),
+		Error(),
+	)).BlockFunc(func(g *Group) {
+		ifErrReturn := If(Err().Op("!=").Nil()).Block(
+			Return(Id("aggregates"), Err()),
+		)
 
+		g.Id("aggregates").Op(":=").Index().Op("*").Id(aggregate).Values()
+
+		g.Id("columns").Op(":=").Index().String().Values()
+		g.For(List(Id("_"), Id("field")).Op(":=").Range().Id("groupBy")).Block(
+			List(Id("column"), Id("ok")).Op(":=").Id(EntityGroupColumnsName(e)).Index(Id("field")),
+			If(Op("!").Id("ok")).Block(
+				Return(Id("aggregates"), ValidationError("groupBy", Qual("fmt", "Sprintf").Call(Lit("Unknown group field %s"), Id("field")))),
+			),
+			Id("columns").Op("=").Append(Id("columns"), Id("column")),
+		)
+
+		g.Id("selected").Op(":=").Append(Index().String().Values(), Id("columns").Op("..."))
+		g.Id("selected").Op("=").Append(Id("selected"), Lit(strings.Join(AggregateExpressions(e), ", ")))
+
+		g.Id("args").Op(":=").Index().Interface().Values()
+		g.Id("query").Op(":=").Lit("SELECT ").Op("+").Qual("strings", "Join").Call(Id("selected"), Lit(", ")).Op("+").Lit(fmt.Sprintf(" FROM %s", TableName(e)))
+		g.If(
+			Id("where").Op(":=").Id("filter").Dot("Where").Call(Op("&").Id("args")),
+			Id("where").Op("!=").Lit(""),
+		).Block(
+			Id("query").Op("=").Id("query").Op("+").Lit(" WHERE ").Op("+").Id("where"),
+		)
+
+		g.If(Len(Id("columns")).Op(">").Lit(0)).Block(
+			Id("group").Op(":=").Qual("strings", "Join").Call(Id("columns"), Lit(", ")),
+			Id("query").Op("=").Id("query").Op("+").Lit(" GROUP BY ").Op("+").Id("group").Op("+").Lit(" ORDER BY ").Op("+").Id("group"),
+		)
+
+		PrepareStatement(Id("query"), g)
+		g.Add(ifErrReturn)
+		DeferCall("stmt", "Close", g)
+
+		g.List(Id("rows"), Err()).Op(":=").Id("stmt").Dot("QueryContext").Call(Id("ctx"), Id("args").Op("..."))
+		g.Add(ifErrReturn)
+		DeferCall("rows", "Close", g)
+
+		g.For(Id("rows").Dot("Next").Call()).BlockFunc(func(g2 *Group) {
+			g2.Id("a").Op(":=").Op("&").Id(aggregate).Values(DictFunc(func(d Dict) {
+				d[Id("Group")] = Op("&").Id(EntityGroupName(e)).Values()
+				for _, fun := range AggregateFunctions() {
+					d[Id(fun[0])] = Op("&").Id(EntityAggregateValuesName(e)).Values()
+				}
+			}))
+
+			// group values are scanned first, in the order they were
+			// selected
+			g2.Id("dest").Op(":=").Index().Interface().Values()
+			g2.For(List(Id("_"), Id("field")).Op(":=").Range().Id("groupBy")).Block(
+				Switch(Id("field")).BlockFunc(func(g3 *Group) {
+					for _, gf := range EntityGroupFields(e) {
+						g3.Case(Lit(gf.Name)).Block(
+							Id("dest").Op("=").Append(Id("dest"), Op("&").Id("a").Dot("Group").Dot(gf.Field)),
+						)
+					}
+				}),
+			)
+
+			g2.Id("dest").Op("=").Append(Id("dest"), ListFunc(func(g3 *Group) {
+				g3.Op("&").Id("a").Dot("Count")
+				for _, fun := range AggregateFunctions() {
+					for _, a := range AggregateAttributes(e) {
+						g3.Op("&").Id("a").Dot(fun[0]).Dot(a.Name)
+					}
+				}
+			}))
+
+			g2.Err().Op(":=").Id("rows").Dot("Scan").Call(Id("dest").Op("..."))
 			g2.Add(ifErrReturn)
-			g2.Id(VarName(e.PluralName())).Op("=").Append(Id(VarName(e.PluralName())), Id(e.VarName()))
+			g2.Id("aggregates").Op("=").Append(Id("aggregates"), Id("a"))
 		})
 
-		g.Return(List(
-			Id(VarName(e.PluralName())),
-			Nil(),
-		))
+		g.Return(Id("aggregates"), Nil())
 	})
 }
 
+// AggregateFunName returns the name of the function that computes
+// aggregates of instances of the given entity
+func AggregateFunName(e *Entity) string {
+	return fmt.Sprintf("Aggregate%s", e.PluralName())
+}
+
 // FindAllFunName returns the name of the finder function for the given
 // entity
 func FindAllFunName(e *Entity) string {
@@ -298,14 +1042,14 @@ func FindAllFunName(e *Entity) string {
 func AddFindByAttributeFun(e *Entity, a *Attribute, f *File) {
 	funName := FindEntityByAttributeFunName(e, a)
 	f.Comment(fmt.Sprintf("%s finds an instance of type %s by %s. If no row matches, then this function returns an error", funName, e.Name, a.Name))
-	f.Func().Id(funName).Params(Id("db").Op("*").Qual("database/sql", "DB"), TypedFromAttribute(Id(a.VarName()), a)).Parens(List(Op("*").Id(e.Name), Error())).BlockFunc(func(g *Group) {
+	f.Func().Id(funName).Params(Id("ctx").Qual("context", "Context"), Id("db").Id("DBTX"), Id("stmts").Op("*").Id("Statements"), TypedFromAttribute(Id(a.VarName()), a)).Parens(List(Op("*").Id(e.Name), Error())).BlockFunc(func(g *Group) {
 
 		g.Add(EmptyStructForEntity(e))
 		PrepareDbStatement(SelectByColumnFromAttributeStatement(e, a), g)
 		IfErrorReturnWithEntity(e, g)
 		DeferCloseStatement(g)
 
-		g.Err().Op("=").Id("stmt").Dot("QueryRow").Call(Id(a.VarName())).Dot("Scan").Call(ListFunc(
+		g.Err().Op("=").Id("stmt").Dot("QueryRowContext").Call(Id("ctx"), Id(a.VarName())).Dot("Scan").Call(ListFunc(
 			ScanRowIntoEntityStruct(e),
 		))
 		g.Return(List(
@@ -315,6 +1059,67 @@ func AddFindByAttributeFun(e *Entity, a *Attribute, f *File) {
 	})
 }
 
+// FindEntitiesByAttributeFunName returns the name of the finder
+// function that returns a list of instances of the given entity by the
+// given attribute
+func FindEntitiesByAttributeFunName(e *Entity, a *Attribute) string {
+	return fmt.Sprintf("Find%sBy%s", e.PluralName(), a.Name)
+}
+
+// AddFindListByAttributeFun produces a finder function for the given
+// entity and an indexed attribute that is not unique. This function
+// will return a list of instances of the given entity
+func AddFindListByAttributeFun(e *Entity, a *Attribute, f *File) {
+	funName := FindEntitiesByAttributeFunName(e, a)
+
+	f.Comment(fmt.Sprintf("%s finds a list of instances of type %s by %s, that match the given filter. If no rows match, then this function returns an empty slice. Results are sorted and paginated.", funName, e.Name, a.Name))
+	f.Func().Id(funName).Params(
+		Id("ctx").Qual("context", "Context"),
+		Id("db").Id("DBTX"),
+		Id("stmts").Op("*").Id("Statements"),
+		TypedFromAttribute(Id(a.VarName()), a),
+		Id("filter").Op("*").Id(EntityFilterName(e)),
+		Id("orderBy").Index().Op("*").Id("SortOrder"),
+		Id("limit").Int32(),
+		Id("offset").Int32(),
+	).Parens(List(
+		Op("[]").Op("*").Id(e.Name),
+		Error(),
+	)).BlockFunc(func(g *Group) {
+		QueryEntities(e, SelectByColumnFromAttributeStatement(e, a), "AND", []Code{Id(a.VarName())}, g)
+	})
+}
+
+// AddFindListByAttributeConnectionFun produces a finder function for
+// the given entity and an indexed attribute that is not unique, that
+// returns a page of instances selected with a cursor
+func AddFindListByAttributeConnectionFun(e *Entity, a *Attribute, f *File) {
+	funName := FindEntitiesByAttributeConnectionFunName(e, a)
+
+	f.Comment(fmt.Sprintf("%s finds a page of instances of type %s by %s, that match the given filter, in the given order. Pages are selected using keyset pagination", funName, e.Name, a.Name))
+	f.Func().Id(funName).Params(
+		Id("ctx").Qual("context", "Context"),
+		Id("db").Id("DBTX"),
+		Id("stmts").Op("*").Id("Statements"),
+		TypedFromAttribute(Id(a.VarName()), a),
+		Id("filter").Op("*").Id(EntityFilterName(e)),
+		Id("orderBy").Index().Op("*").Id("SortOrder"),
+		Id("page").Op("*").Id("Page"),
+	).Parens(List(
+		Op("*").Id(EntityConnectionName(e)),
+		Error(),
+	)).BlockFunc(func(g *Group) {
+		QueryEntitiesConnection(e, SelectByColumnFromAttributeStatement(e, a), "AND", []Code{Id(a.VarName())}, g)
+	})
+}
+
+// FindEntitiesByAttributeConnectionFunName returns the name of the
+// function that finds a page of instances of the given entity by
+// attribute
+func FindEntitiesByAttributeConnectionFunName(e *Entity, a *Attribute) string {
+	return fmt.Sprintf("%sConnection", FindEntitiesByAttributeFunName(e, a))
+}
+
 // FindEntityByRelationFunName returns the name of the finder function for the given
 // entity and relation
 func FindEntityByRelationFunName(e *Entity, r *Relation) string {
@@ -327,73 +1132,231 @@ func FindEntityByRelationFunName(e *Entity, r *Relation) string {
 func AddFindByRelationFun(e *Entity, r *Relation, f *File) {
 	funName := FindEntityByRelationFunName(e, r)
 
-	// error handling code to be used in different points of this
-	// function body
-	ifErrReturn := If(Err().Op("!=").Nil()).Block(
-		Return(
-			Id(VarName(e.PluralName())),
-			Err(),
-		),
-	)
-
-	f.Comment(fmt.Sprintf("%s finds a list of instances of type %s by %s. If no rows match, then this function returns an empty slice. Results are sorted and paginated.", funName, e.Name, r.Alias()))
+	f.Comment(fmt.Sprintf("%s finds a list of instances of type %s by %s, that match the given filter. If no rows match, then this function returns an empty slice. Results are sorted and paginated.", funName, e.Name, r.Alias()))
 	f.Func().Id(funName).Params(
-		Id("db").Op("*").Qual("database/sql", "DB"),
+		Id("ctx").Qual("context", "Context"),
+		Id("db").Id("DBTX"),
+		Id("stmts").Op("*").Id("Statements"),
 		Id(r.VarName()).String(),
+		Id("filter").Op("*").Id(EntityFilterName(e)),
+		Id("orderBy").Index().Op("*").Id("SortOrder"),
 		Id("limit").Int32(),
 		Id("offset").Int32(),
 	).Parens(List(
 		Op("[]").Op("*").Id(e.Name),
 		Error(),
 	)).BlockFunc(func(g *Group) {
-		g.Id(VarName(e.PluralName())).Op(":=").Op("[]").Op("*").Id(e.Name).Values(Dict{})
+		QueryEntities(e, SelectByColumnFromRelationStatement(e, r), "AND", []Code{Id(r.VarName())}, g)
+	})
+}
+
+// AddFindByRelationConnectionFun produces a finder function for the
+// given entity and relation, that returns a page of instances selected
+// with a cursor
+func AddFindByRelationConnectionFun(e *Entity, r *Relation, f *File) {
+	funName := FindEntityByRelationConnectionFunName(e, r)
+
+	f.Comment(fmt.Sprintf("%s finds a page of instances of type %s by %s, that match the given filter, in the given order. Pages are selected using keyset pagination", funName, e.Name, r.Alias()))
+	f.Func().Id(funName).Params(
+		Id("ctx").Qual("context", "Context"),
+		Id("db").Id("DBTX"),
+		Id("stmts").Op("*").Id("Statements"),
+		Id(r.VarName()).String(),
+		Id("filter").Op("*").Id(EntityFilterName(e)),
+		Id("orderBy").Index().Op("*").Id("SortOrder"),
+		Id("page").Op("*").Id("Page"),
+	).Parens(List(
+		Op("*").Id(EntityConnectionName(e)),
+		Error(),
+	)).BlockFunc(func(g *Group) {
+		QueryEntitiesConnection(e, SelectByColumnFromRelationStatement(e, r), "AND", []Code{Id(r.VarName())}, g)
+	})
+}
 
-		g.List(
-			Id("stmt"),
-			Err(),
-		).Op(":=").Id("db").Dot("Prepare").Call(
-			Qual("fmt", "Sprintf").Call(
-				Lit(fmt.Sprintf(
-					"%s ORDER BY %s ASC LIMIT %%v OFFSET %%v",
-					SelectByColumnFromRelationStatement(e, r),
-					AttributeColumnName(e.PreferredSort()),
-				)),
-				Id("limit"),
-				Id("offset"),
-			),
-		)
+// FindEntitiesByIDsFunName returns the name of the function that finds
+// instances of the given entity by a list of ids
+func FindEntitiesByIDsFunName(e *Entity) string {
+	return fmt.Sprintf("Find%sByIDs", e.PluralName())
+}
 
-		g.Add(ifErrReturn)
-		DeferCall("stmt", "Close", g)
+// AddFindByIDsFun produces a function that finds the instances of the
+// given entity with any of the given ids, using an IN clause. Ids are
+// split in batches, so that queries never exceed the maximum number of
+// parameters
+func AddFindByIDsFun(e *Entity, f *File) {
+	funName := FindEntitiesByIDsFunName(e)
+	plural := VarName(e.PluralName())
+
+	f.Comment(fmt.Sprintf("%s finds the instances of type %s with the given ids. Ids that match no row are skipped, and results are not sorted", funName, e.Name))
+	f.Func().Id(funName).Params(
+		Id("ctx").Qual("context", "Context"),
+		Id("db").Id("DBTX"),
+		Id("stmts").Op("*").Id("Statements"),
+		Id("ids").Index().String(),
+	).Parens(List(
+		Index().Op("*").Id(e.Name),
+		Error(),
+	)).BlockFunc(func(g *Group) {
+		g.Id(plural).Op(":=").Index().Op("*").Id(e.Name).Values()
+		g.Id("size").Op(":=").Id("SqlMaxParams").Call(Id("stmts").Dot("Dialect").Call())
+		ForEachBatch("ids", g, func(g2 *Group) {
+			BatchArgsFromIDs(g2)
+			g2.Id("query").Op(":=").Lit(SelectAllStatement(e)+" WHERE id IN ").Op("+").Id("SqlValuesPlaceholders").Call(
+				Lit(1),
+				Id("end").Op("-").Id("start"),
+			)
+
+			QueryEntitiesBatch(e, g2)
+		})
+
+		g.Return(Id(plural), Nil())
+	})
+}
+
+// FindEntitiesByRelationIDsFunName returns the name of the function
+// that finds instances of the given entity by a list of ids of the
+// given relation
+func FindEntitiesByRelationIDsFunName(e *Entity, r *Relation) string {
+	return fmt.Sprintf("%sIDs", FindEntityByRelationFunName(e, r))
+}
+
+// AddFindByRelationIDsFun produces a function that finds the instances
+// of the given entity related to any of the given ids, using an IN
+// clause. Each related id gets its own page of results, which is
+// selected by numbering rows with a window function partitioned by the
+// relation column
+func AddFindByRelationIDsFun(e *Entity, r *Relation, f *File) {
+	funName := FindEntitiesByRelationIDsFunName(e, r)
+	plural := VarName(e.PluralName())
+	column := RelationColumnName(r)
+
+	f.Comment(fmt.Sprintf("%s finds the instances of type %s by any of the given %s ids, that match the given filter. Results are sorted and paginated for every %s separately, and returned one %s after the other", funName, e.Name, r.Alias(), r.Alias(), r.Alias()))
+	f.Func().Id(funName).Params(
+		Id("ctx").Qual("context", "Context"),
+		Id("db").Id("DBTX"),
+		Id("stmts").Op("*").Id("Statements"),
+		Id("ids").Index().String(),
+		Id("filter").Op("*").Id(EntityFilterName(e)),
+		Id("orderBy").Index().Op("*").Id("SortOrder"),
+		Id("limit").Int32(),
+		Id("offset").Int32(),
+	).Parens(List(
+		Index().Op("*").Id(e.Name),
+		Error(),
+	)).BlockFunc(func(g *Group) {
+		g.Id(plural).Op(":=").Index().Op("*").Id(e.Name).Values()
 
 		g.List(
-			Id("rows"),
+			Id("order"),
 			Err(),
-		).Op(":=").Id("stmt").Dot("Query").Call(
-			Id(r.VarName()),
+		).Op(":=").Id("SqlOrderBy").Call(
+			Id("orderBy"),
+			Id(EntitySortColumnsName(e)),
+			Lit(AttributeColumnName(e.PreferredSort())),
 		)
-		g.Add(ifErrReturn)
+		g.If(Err().Op("!=").Nil()).Block(Return(Id(plural), Err()))
 
-		DeferCall("rows", "Close", g)
+		// leave room for the values of the filter
+		g.Id("size").Op(":=").Id("SqlMaxParams").Call(Id("stmts").Dot("Dialect").Call()).Op("/").Lit(2)
+		ForEachBatch("ids", g, func(g2 *Group) {
+			BatchArgsFromIDs(g2)
+			g2.Id("ranked").Op(":=").Qual("fmt", "Sprintf").Call(
+				Lit(fmt.Sprintf(
+					"SELECT %s, ROW_NUMBER() OVER (PARTITION BY %s ORDER BY %%s) AS ordinal FROM %s WHERE %s IN ",
+					strings.Join(EntityColumns(e), ","),
+					column,
+					TableName(e),
+					column,
+				)),
+				Id("order"),
+			).Op("+").Id("SqlValuesPlaceholders").Call(
+				Lit(1),
+				Id("end").Op("-").Id("start"),
+			)
+			g2.If(
+				Id("where").Op(":=").Id("filter").Dot("Where").Call(Op("&").Id("args")),
+				Id("where").Op("!=").Lit(""),
+			).Block(
+				Id("ranked").Op("=").Id("ranked").Op("+").Lit(" AND ").Op("+").Id("where"),
+			)
+
+			g2.Id("args").Op("=").Append(
+				Id("args"),
+				Id("offset"),
+				Int64().Call(Id("offset")).Op("+").Int64().Call(Id("limit")),
+			)
+			g2.Id("query").Op(":=").Qual("fmt", "Sprintf").Call(
+				Lit(fmt.Sprintf(
+					"SELECT %s FROM (%%s) AS ranked WHERE ordinal > $%%v AND ordinal <= $%%v ORDER BY %s, ordinal",
+					strings.Join(EntityColumns(e), ","),
+					column,
+				)),
+				Id("ranked"),
+				Len(Id("args")).Op("-").Lit(1),
+				Len(Id("args")),
+			)
 
-		g.For(
-			Id("rows").Dot("Next").Call(),
-		).BlockFunc(func(g2 *Group) {
+			QueryEntitiesBatch(e, g2)
+		})
 
-			g2.Add(EmptyStructForEntity(e))
-			g2.Err().Op(":=").Id("rows").Dot("Scan").Call(ListFunc(
-				ScanRowIntoEntityStruct(e),
-			))
+		g.Return(Id(plural), Nil())
+	})
+}
 
-			g2.Add(ifErrReturn)
-			g2.Id(VarName(e.PluralName())).Op("=").Append(Id(VarName(e.PluralName())), Id(e.VarName()))
-		})
+// BatchArgsFromIDs produces the code that collects the ids of the
+// current batch into the args of a query
+func BatchArgsFromIDs(g *Group) {
+	g.Id("args").Op(":=").Index().Interface().Values()
+	g.For(
+		List(Id("_"), Id("id")).Op(":=").Range().Id("ids").Index(Id("start").Op(":").Id("end")),
+	).Block(
+		Id("args").Op("=").Append(Id("args"), Id("id")),
+	)
+}
 
-		g.Return(List(
-			Id(VarName(e.PluralName())),
-			Nil(),
+// QueryEntitiesBatch produces the code that runs the query in scope
+// for a batch, and appends all rows to the slice of instances of the
+// given entity. Rows and the statement are closed before the next
+// batch is queried
+func QueryEntitiesBatch(e *Entity, g *Group) {
+	plural := VarName(e.PluralName())
+
+	PrepareStatement(Id("query"), g)
+	g.If(Err().Op("!=").Nil()).Block(Return(Id(plural), Err()))
+
+	g.List(
+		Id("rows"),
+		Err(),
+	).Op(":=").Id("stmt").Dot("QueryContext").Call(Id("ctx"), Id("args").Op("..."))
+	g.If(Err().Op("!=").Nil()).Block(
+		Id("stmt").Dot("Close").Call(),
+		Return(Id(plural), Err()),
+	)
+
+	g.For(
+		Id("rows").Dot("Next").Call(),
+	).BlockFunc(func(g2 *Group) {
+		g2.Add(EmptyStructForEntity(e))
+		g2.Err().Op(":=").Id("rows").Dot("Scan").Call(ListFunc(
+			ScanRowIntoEntityStruct(e),
 		))
+		g2.If(Err().Op("!=").Nil()).Block(
+			Id("rows").Dot("Close").Call(),
+			Id("stmt").Dot("Close").Call(),
+			Return(Id(plural), Err()),
+		)
+		g2.Id(plural).Op("=").Append(Id(plural), Id(e.VarName()))
 	})
+
+	g.Err().Op("=").Id("rows").Dot("Close").Call()
+	g.Id("stmt").Dot("Close").Call()
+	g.If(Err().Op("!=").Nil()).Block(Return(Id(plural), Err()))
+}
+
+// FindEntityByRelationConnectionFunName returns the name of the function
+// that finds a page of instances of the given entity by relation
+func FindEntityByRelationConnectionFunName(e *Entity, r *Relation) string {
+	return fmt.Sprintf("%sConnection", FindEntityByRelationFunName(e, r))
 }
 
 // Quoted
@@ -401,12 +1364,77 @@ func SingleQuoted(str string) string {
 	return fmt.Sprintf("'%v'", str)
 }
 
-// InsertStatement generates a sql INSERT statement for the given entity
+// InsertEntityStatement generates the sql INSERT statement that
+// creates an instance of the given entity. When IDs are assigned by the
+// database, the statement returns the ID
+func InsertEntityStatement(e *Entity) string {
+	if e.GeneratesIDInDatabase() {
+		return fmt.Sprintf("%s RETURNING id", InsertStatement(e))
+	}
+
+	return InsertStatement(e)
+}
+
+// InsertStatement generates a sql INSERT statement for the given entity.
+// Rows without any column to insert, such as the ones of entities that
+// only hold an ID from a sequence, get the default values
 func InsertStatement(e *Entity) string {
+	if len(InsertColumns(e)) == 0 {
+		return fmt.Sprintf("INSERT INTO %s DEFAULT VALUES", TableName(e))
+	}
+
+	placeholders := []string{}
+	for i := range InsertColumns(e) {
+		placeholders = append(placeholders, placeholder(i+1))
+	}
+
+	return fmt.Sprintf("%s (%s)", InsertStatementPrefix(e), strings.Join(placeholders, ","))
+}
+
+// UpsertStatement generates a sql INSERT statement for the given
+// entity, that updates all columns of the existing row, but the id and
+// the upsert key, on conflict. Both postgres and sqlite support this
+// syntax. The stored row is returned.
+func UpsertStatement(e *Entity) string {
+	key := AttributeColumnName(e.UpsertAttribute())
+
+	columns := []string{}
+	for _, c := range EntityColumns(e) {
+		if c != key && c != "id" {
+			columns = append(columns, fmt.Sprintf("%s=excluded.%s", c, c))
+		}
+	}
+
+	// there must be at least one column in the SET clause, so that
+	// the conflicting row is returned
+	if len(columns) == 0 {
+		columns = append(columns, fmt.Sprintf("%s=excluded.%s", key, key))
+	}
+
+	chunks := []string{}
+	chunks = append(chunks, InsertStatement(e))
+	chunks = append(chunks, fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET", key))
+	chunks = append(chunks, strings.Join(columns, ","))
+	chunks = append(chunks, "RETURNING")
+	chunks = append(chunks, strings.Join(EntityColumns(e), ","))
+	return strings.Join(chunks, " ")
+}
+
+// InsertStatementPrefix generates the beginning of a sql INSERT
+// statement for the given entity, up to the VALUES keyword
+func InsertStatementPrefix(e *Entity) string {
 	chunks := []string{}
 	chunks = append(chunks, "INSERT INTO")
 	chunks = append(chunks, TableName(e))
+	chunks = append(chunks, fmt.Sprintf("(%s)", strings.Join(InsertColumns(e), ",")))
+	chunks = append(chunks, "VALUES")
+	return strings.Join(chunks, " ")
+}
 
+// EntityColumns returns the names of all the columns of the table for
+// the given entity: one for each attribute, and one for each relation
+// that holds the id of another entity
+func EntityColumns(e *Entity) []string {
 	columns := []string{}
 	for _, a := range e.Attributes {
 		columns = append(columns, AttributeColumnName(a))
@@ -418,25 +1446,21 @@ func InsertStatement(e *Entity) string {
 		}
 	}
 
-	chunks = append(chunks, fmt.Sprintf("(%s)", strings.Join(columns, ",")))
-	chunks = append(chunks, "VALUES")
-
-	placeholders := []string{}
-	i := 1
-	for _, _ = range e.Attributes {
-		placeholders = append(placeholders, placeholder(i))
-		i++
-	}
+	return columns
+}
 
-	for _, r := range e.Relations {
-		if r.HasModifier("belongsTo") || r.HasModifier("hasOne") {
-			placeholders = append(placeholders, placeholder(i))
-			i++
+// InsertColumns returns the names of the columns given a value when
+// inserting instances of the given entity. These are all the columns,
+// but the id when it is assigned by the database
+func InsertColumns(e *Entity) []string {
+	columns := []string{}
+	for _, c := range EntityColumns(e) {
+		if c != "id" || !e.GeneratesIDInDatabase() {
+			columns = append(columns, c)
 		}
 	}
 
-	chunks = append(chunks, fmt.Sprintf("(%s)", strings.Join(placeholders, ",")))
-	return strings.Join(chunks, " ")
+	return columns
 }
 
 // placeholder returns a postgres style placeholder
@@ -448,7 +1472,9 @@ func placeholder(i int) string {
 // values to be sent to the INSERT sql statement for the entity
 func InsertStatementValues(e *Entity, g *Group) {
 	for _, a := range e.Attributes {
-		g.Id(e.VarName()).Dot(a.Name)
+		if a.Name != "ID" || !e.GeneratesIDInDatabase() {
+			g.Id(e.VarName()).Dot(a.Name)
+		}
 	}
 
 	for _, r := range e.Relations {
@@ -498,6 +1524,12 @@ func UpdateStatement(e *Entity) string {
 		}
 	}
 
+	// there must be at least one column in the SET clause, even for
+	// entities that have nothing but an id
+	if len(columns) == 0 {
+		columns = append(columns, "id=id")
+	}
+
 	chunks = append(chunks, strings.Join(columns, ","))
 	chunks = append(chunks, fmt.Sprintf("WHERE id=%s", placeholder(i)))
 	return strings.Join(chunks, " ")
@@ -559,18 +1591,7 @@ func SelectAllStatement(e *Entity) string {
 	chunks := []string{}
 	chunks = append(chunks, "SELECT")
 
-	columns := []string{}
-	for _, a := range e.Attributes {
-		columns = append(columns, AttributeColumnName(a))
-	}
-
-	for _, r := range e.Relations {
-		if r.HasModifier("belongsTo") || r.HasModifier("hasOne") {
-			columns = append(columns, RelationColumnName(r))
-		}
-	}
-
-	chunks = append(chunks, strings.Join(columns, ","))
+	chunks = append(chunks, strings.Join(EntityColumns(e), ","))
 	chunks = append(chunks, "FROM")
 	chunks = append(chunks, TableName(e))
 	return strings.Join(chunks, " ")
@@ -583,18 +1604,7 @@ func SelectByColumnFromStatement(e *Entity, whereColumn string) string {
 	chunks := []string{}
 	chunks = append(chunks, "SELECT")
 
-	columns := []string{}
-	for _, a := range e.Attributes {
-		columns = append(columns, AttributeColumnName(a))
-	}
-
-	for _, r := range e.Relations {
-		if r.HasModifier("belongsTo") || r.HasModifier("hasOne") {
-			columns = append(columns, RelationColumnName(r))
-		}
-	}
-
-	chunks = append(chunks, strings.Join(columns, ","))
+	chunks = append(chunks, strings.Join(EntityColumns(e), ","))
 	chunks = append(chunks, "FROM")
 	chunks = append(chunks, TableName(e))
 	chunks = append(chunks, "WHERE")
@@ -688,33 +1698,31 @@ func ReturnRow(e *Entity, g *Group) {
 }
 
 // BeginTransaction is a helper function that generates the code needed
-// to start a new transaction.
-func BeginTransaction(g *Group) {
-	g.List(Id("tx"), Err()).Op(":=").Id("db").Dot("Begin").Call()
-}
-
-// PrepareTransactionStatement produces the code required to create a new
-// statement from a transaction
-func PrepareTransactionStatement(sql string, g *Group) {
-	PrepareStatement("tx", sql, g)
+// to start a new transaction on the given database handle, bound to the
+// context in scope
+func BeginTransaction(db *Statement, g *Group) {
+	g.List(Id("tx"), Err()).Op(":=").Add(db).Dot("BeginTx").Call(Id("ctx"), Nil())
 }
 
-// PrepareDbStatement produces the code required to create a new
-// statement from a database
+// PrepareDbStatement produces the code required to get a statement
+// for the given sql from the registry, to be run on the database
+// handle in scope
 func PrepareDbStatement(sql string, g *Group) {
-	PrepareStatement("db", sql, g)
+	PrepareStatement(Lit(sql), g)
 }
 
-// PrepareStatement produces the code required to create a new statement
-func PrepareStatement(receiver string, sql string, g *Group) {
-	g.List(Id("stmt"), Err()).Op(":=").Id(receiver).Dot("Prepare").Call(Lit(sql))
+// PrepareStatement produces the code required to get a statement for
+// the given query from the registry
+func PrepareStatement(query Code, g *Group) {
+	g.List(Id("stmt"), Err()).Op(":=").Id("stmts").Dot("Prepare").Call(Id("ctx"), Id("db"), query)
 }
 
 // ExecuteStatement produces the code required to execute a statement.
 // Since not every database fully supports the Result interface, we
 // simply ignore it
 func ExecuteStatement(g *Group, argsFun func(g *Group)) {
-	g.List(Id("_"), Err()).Op("=").Id("stmt").Dot("Exec").CallFunc(func(g2 *Group) {
+	g.List(Id("_"), Err()).Op("=").Id("stmt").Dot("ExecContext").CallFunc(func(g2 *Group) {
+		g2.Id("ctx")
 		g2.ListFunc(argsFun)
 	})
 }
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"fmt"
+
+	. "github.com/dave/jennifer/jen"
+)
+
+// CreateRepository generates a Golang file that contains the
+// repository interfaces of every entity, and their implementation
+// backed by the database
+func CreateRepository(p *Package) error {
+	f := NewFile(p.Name)
+
+	f.PackageComment(fmt.Sprintf("%s contains all the library code for the platform", p.Name))
+	f.PackageComment("This file contains the repository interfaces, and their SQL implementation")
+	f.PackageComment(" ** THIS CODE IS MACHINE GENERATED. DO NOT EDIT MANUALLY ** ")
+
+	for _, e := range p.Model.Entities {
+		if len(EntityRepositoryMethods(e)) > 0 {
+			AddEntityRepositoryInterface(e, f)
+		}
+	}
+
+	AddRepositoryInterface(p.Model, f)
+	AddTransactionInterface(f)
+
+	AddSqlRepositoryStruct(f)
+	AddSqlTransactionStruct(f)
+
+	for _, e := range p.Model.Entities {
+		for _, m := range EntityRepositoryMethods(e) {
+			AddSqlRepositoryMethod(m, f)
+		}
+	}
+
+	return f.Save(p.Filename)
+}
+
+// RepositoryMethod describes a method of the repository of an entity.
+// Every method mirrors a repository function, minus its database
+// handle, so that callers do not depend on where instances are stored
+type RepositoryMethod struct {
+	Name      string
+	Kind      string
+	Entity    *Entity
+	Attribute *Attribute
+	Relation  *Relation
+	Params    []*RepositoryParam
+	Results   []Code
+}
+
+// RepositoryParam is a parameter of a repository method
+type RepositoryParam struct {
+	Name string
+	Type *Statement
+}
+
+// EntityRepositoryMethods returns the methods of the repository of the
+// given entity, one for each repository function generated for it.
+// Fresh statements are built on every call, so that the result can be
+// rendered freely
+func EntityRepositoryMethods(e *Entity) []*RepositoryMethod {
+	methods := []*RepositoryMethod{}
+	add := func(name string, kind string, params []*RepositoryParam, results ...Code) *RepositoryMethod {
+		m := &RepositoryMethod{
+			Name:    name,
+			Kind:    kind,
+			Entity:  e,
+			Params:  params,
+			Results: results,
+		}
+		methods = append(methods, m)
+		return m
+	}
+
+	one := func() *Statement { return Op("*").Id(e.Name) }
+	many := func() *Statement { return Index().Op("*").Id(e.Name) }
+	connection := func() *Statement { return Op("*").Id(EntityConnectionName(e)) }
+	entity := func() []*RepositoryParam {
+		return []*RepositoryParam{&RepositoryParam{Name: e.VarName(), Type: one()}}
+	}
+	entities := func() []*RepositoryParam {
+		return []*RepositoryParam{&RepositoryParam{Name: VarName(e.PluralName()), Type: many()}}
+	}
+	ids := func() *RepositoryParam {
+		return &RepositoryParam{Name: "ids", Type: Index().String()}
+	}
+	list := func(params ...*RepositoryParam) []*RepositoryParam {
+		return append(params,
+			&RepositoryParam{Name: "filter", Type: Op("*").Id(EntityFilterName(e))},
+			&RepositoryParam{Name: "orderBy", Type: Index().Op("*").Id("SortOrder")},
+			&RepositoryParam{Name: "limit", Type: Int32()},
+			&RepositoryParam{Name: "offset", Type: Int32()},
+		)
+	}
+	page := func(params ...*RepositoryParam) []*RepositoryParam {
+		return append(params,
+			&RepositoryParam{Name: "filter", Type: Op("*").Id(EntityFilterName(e))},
+			&RepositoryParam{Name: "orderBy", Type: Index().Op("*").Id("SortOrder")},
+			&RepositoryParam{Name: "page", Type: Op("*").Id("Page")},
+		)
+	}
+
+	if e.SupportsOperation("create") {
+		add(InsertEntityFunName(e), "create", entity(), one(), Error())
+		add(InsertManyEntitiesFunName(e), "createMany", entities(), many(), Error())
+	}
+
+	if e.SupportsOperation("update") {
+		add(UpdateEntityFunName(e), "update", entity(), one(), Error())
+		add(UpdateManyEntitiesFunName(e), "updateMany", entities(), many(), Error())
+
+		if SupportsPatch(e) {
+			add(PatchEntityFunName(e), "patch", []*RepositoryParam{
+				&RepositoryParam{Name: "id", Type: String()},
+				&RepositoryParam{Name: "patch", Type: Op("*").Id(EntityPatchName(e))},
+			}, one(), Error())
+		}
+	}
+
+	if e.SupportsOperation("delete") {
+		add(DeleteEntityFunName(e), "delete", []*RepositoryParam{&RepositoryParam{Name: "id", Type: String()}}, one(), Error())
+		add(DeleteManyEntitiesFunName(e), "deleteMany", []*RepositoryParam{ids()}, many(), Error())
+	}
+
+	if e.SupportsOperation("upsert") {
+		add(UpsertEntityFunName(e), "upsert", entity(), one(), Error())
+	}
+
+	if !e.SupportsOperation("find") {
+		return methods
+	}
+
+	for _, a := range e.Attributes {
+		key := &RepositoryParam{Name: a.VarName(), Type: TypeFromAttribute(a)}
+		if a.HasModifier("unique") && a.HasModifier("indexed") {
+			add(FindEntityByAttributeFunName(e, a), "findByAttribute", []*RepositoryParam{key}, one(), Error()).Attribute = a
+		} else if a.HasModifier("indexed") {
+			add(FindEntitiesByAttributeFunName(e, a), "findListByAttribute", list(key), many(), Error()).Attribute = a
+
+			if e.SupportsCursorPagination() {
+				key := &RepositoryParam{Name: a.VarName(), Type: TypeFromAttribute(a)}
+				add(FindEntitiesByAttributeConnectionFunName(e, a), "findConnectionByAttribute", page(key), connection(), Error()).Attribute = a
+			}
+		}
+	}
+
+	add(FindEntitiesByIDsFunName(e), "findByIDs", []*RepositoryParam{ids()}, many(), Error())
+
+	for _, r := range e.Relations {
+		if r.HasModifier("hasOne") || r.HasModifier("belongsTo") {
+			key := func() *RepositoryParam {
+				return &RepositoryParam{Name: r.VarName(), Type: TypeFromRelation(r)}
+			}
+			add(FindEntityByRelationFunName(e, r), "findListByRelation", list(key()), many(), Error()).Relation = r
+			add(FindEntitiesByRelationIDsFunName(e, r), "findListByRelationIDs", list(ids()), many(), Error()).Relation = r
+
+			if e.SupportsCursorPagination() {
+				add(FindEntityByRelationConnectionFunName(e, r), "findConnectionByRelation", page(key()), connection(), Error()).Relation = r
+			}
+		}
+	}
+
+	add(FindAllFunName(e), "findAll", list(), many(), Error())
+
+	if e.SupportsCursorPagination() {
+		add(FindAllConnectionFunName(e), "findAllConnection", page(), connection(), Error())
+	}
+
+	add(CountFunName(e), "count", []*RepositoryParam{
+		&RepositoryParam{Name: "filter", Type: Op("*").Id(EntityFilterName(e))},
+	}, Int(), Error())
+
+	add(AggregateFunName(e), "aggregate", []*RepositoryParam{
+		&RepositoryParam{Name: "filter", Type: Op("*").Id(EntityFilterName(e))},
+		&RepositoryParam{Name: "groupBy", Type: Index().String()},
+	}, Index().Op("*").Id(EntityAggregateName(e)), Error())
+
+	if SupportsSearch(e) {
+		add(SearchFunName(e), "search", []*RepositoryParam{
+			&RepositoryParam{Name: "query", Type: String()},
+			&RepositoryParam{Name: "limit", Type: Int32()},
+			&RepositoryParam{Name: "offset", Type: Int32()},
+		}, many(), Error())
+	}
+
+	return methods
+}
+
+// Writes returns whether the method changes instances, rather than
+// finding them
+func (m *RepositoryMethod) Writes() bool {
+	switch m.Kind {
+	case "create", "createMany", "update", "updateMany", "patch", "delete", "deleteMany", "upsert":
+		return true
+	}
+
+	return false
+}
+
+// RepositoryMethodParams returns the parameters of the given repository
+// method, which all start with the context
+func RepositoryMethodParams(m *RepositoryMethod) []Code {
+	params := []Code{Id("ctx").Qual("context", "Context")}
+	for _, p := range m.Params {
+		params = append(params, Id(p.Name).Add(p.Type))
+	}
+
+	return params
+}
+
+// EntityRepositoryName returns the name of the repository interface of
+// the given entity
+func EntityRepositoryName(e *Entity) string {
+	return fmt.Sprintf("%sRepository", e.Name)
+}
+
+// AddEntityRepositoryInterface generates the interface that holds all
+// the repository methods of the given entity
+func AddEntityRepositoryInterface(e *Entity, f *File) {
+	name := EntityRepositoryName(e)
+
+	f.Comment(fmt.Sprintf("%s persists and finds instances of type %s", name, e.Name))
+	f.Type().Id(name).InterfaceFunc(func(g *Group) {
+		for _, m := range EntityRepositoryMethods(e) {
+			g.Id(m.Name).Params(RepositoryMethodParams(m)...).Parens(List(m.Results...))
+		}
+	})
+}
+
+// AddRepositoryInterface generates the interface that aggregates the
+// repositories of all entities in the model. This is what resolvers,
+// hooks and generators are written against
+func AddRepositoryInterface(m *Model, f *File) {
+	f.Comment("Repository persists and finds instances of all entities. It is implemented by SqlRepository, backed by the database, and MemoryRepository, which holds instances in memory")
+	f.Type().Id("Repository").InterfaceFunc(func(g *Group) {
+		for _, e := range m.Entities {
+			if len(EntityRepositoryMethods(e)) > 0 {
+				g.Id(EntityRepositoryName(e))
+			}
+		}
+
+		g.Comment("Begin opens a transaction. Changes made through the transaction are only visible to others once it is committed")
+		g.Id("Begin").Params(Id("ctx").Qual("context", "Context")).Parens(List(Id("Transaction"), Error()))
+	})
+}
+
+// AddTransactionInterface generates the interface of a repository
+// bound to a transaction
+func AddTransactionInterface(f *File) {
+	f.Comment("Transaction is a Repository whose changes are applied all at once when committed, or discarded when rolled back. Transactions cannot be nested")
+	f.Type().Id("Transaction").Interface(
+		Id("Repository"),
+		Id("Commit").Params().Error(),
+		Id("Rollback").Params().Error(),
+	)
+}
+
+// AddSqlRepositoryStruct generates the Repository implementation that
+// runs the repository functions against a database handle
+func AddSqlRepositoryStruct(f *File) {
+	f.Comment("SqlRepository is the Repository backed by the database. Its methods call the repository functions with the given handle, and reuse the given prepared statements, if any. Reads are routed to the given replicas, if any, unless they follow a write in the same request")
+	f.Type().Id("SqlRepository").Struct(
+		Id("Db").Id("DBTX"),
+		Id("Statements").Op("*").Id("Statements"),
+		Id("Replicas").Op("*").Id("Replicas"),
+	)
+
+	f.Comment("reader returns the handle, and its statements, that reads in the given context run on")
+	f.Func().Parens(Id("r").Op("*").Id("SqlRepository")).Id("reader").Params(
+		Id("ctx").Qual("context", "Context"),
+	).Parens(List(
+		Id("DBTX"),
+		Op("*").Id("Statements"),
+	)).Block(
+		If(Op("!").Id("ReadsFromPrimary").Call(Id("ctx"))).Block(
+			If(Id("replica").Op(":=").Id("r").Dot("Replicas").Dot("Next").Call(), Id("replica").Op("!=").Nil()).Block(
+				Return(Id("replica").Dot("Db"), Id("replica").Dot("Statements")),
+			),
+		),
+		Return(Id("r").Dot("Db"), Id("r").Dot("Statements")),
+	)
+
+	f.Comment("Begin opens a database transaction on the primary. Only repositories backed by a *sql.DB can open one")
+	f.Func().Parens(Id("r").Op("*").Id("SqlRepository")).Id("Begin").Params(
+		Id("ctx").Qual("context", "Context"),
+	).Parens(List(
+		Id("Transaction"),
+		Error(),
+	)).BlockFunc(func(g *Group) {
+		g.Id("MarkWritten").Call(Id("ctx"))
+
+		g.List(Id("db"), Id("ok")).Op(":=").Id("r").Dot("Db").Assert(Op("*").Qual("database/sql", "DB"))
+		g.If(Op("!").Id("ok")).Block(
+			Return(Nil(), Qual("fmt", "Errorf").Call(Lit("Nested transactions are not supported"))),
+		)
+
+		BeginTransaction(Id("db"), g)
+		g.If(Err().Op("!=").Nil()).Block(
+			Return(Nil(), Err()),
+		)
+
+		g.Return(Op("&").Id("SqlTransaction").Values(Dict{
+			Id("SqlRepository"): Id("SqlRepository").Values(Dict{
+				Id("Db"):         Id("tx"),
+				Id("Statements"): Id("r").Dot("Statements"),
+			}),
+			Id("Tx"): Id("tx"),
+		}), Nil())
+	})
+}
+
+// AddSqlTransactionStruct generates the Transaction implementation
+// bound to a database transaction
+func AddSqlTransactionStruct(f *File) {
+	f.Comment("SqlTransaction is the Transaction backed by a database transaction")
+	f.Type().Id("SqlTransaction").Struct(
+		Id("SqlRepository"),
+		Id("Tx").Op("*").Qual("database/sql", "Tx"),
+	)
+
+	f.Comment("Commit commits the database transaction")
+	f.Func().Parens(Id("t").Op("*").Id("SqlTransaction")).Id("Commit").Params().Error().Block(
+		Return(Id("t").Dot("Tx").Dot("Commit").Call()),
+	)
+
+	f.Comment("Rollback rolls the database transaction back. It fails with sql.ErrTxDone once the transaction is committed")
+	f.Func().Parens(Id("t").Op("*").Id("SqlTransaction")).Id("Rollback").Params().Error().Block(
+		Return(Id("t").Dot("Tx").Dot("Rollback").Call()),
+	)
+}
+
+// AddSqlRepositoryMethod generates the method of SqlRepository that
+// calls the repository function mirrored by the given method. Writes
+// run on the primary, and reads on a replica, if any. Errors are
+// classified, so that they do not depend on the database
+func AddSqlRepositoryMethod(m *RepositoryMethod, f *File) {
+	f.Comment(fmt.Sprintf("%s calls the %s repository function", m.Name, m.Name))
+	f.Func().Parens(Id("r").Op("*").Id("SqlRepository")).Id(m.Name).Params(
+		RepositoryMethodParams(m)...,
+	).Parens(List(m.Results...)).BlockFunc(func(g *Group) {
+		db := Id("r").Dot("Db")
+		stmts := Id("r").Dot("Statements")
+		if m.Writes() {
+			g.Id("MarkWritten").Call(Id("ctx"))
+		} else {
+			g.List(Id("db"), Id("stmts")).Op(":=").Id("r").Dot("reader").Call(Id("ctx"))
+			db = Id("db")
+			stmts = Id("stmts")
+		}
+
+		g.List(Id("result"), Err()).Op(":=").Id(m.Name).CallFunc(func(g2 *Group) {
+			g2.Id("ctx")
+			g2.Add(db)
+			g2.Add(stmts)
+			for _, p := range m.Params {
+				g2.Id(p.Name)
+			}
+		})
+		g.Return(Id("result"), Id("ClassifyError").Call(Err(), Lit(m.Entity.Name), Id(EntityColumnFieldsName(m.Entity))))
+	})
+}
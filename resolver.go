@@ -12,39 +12,113 @@ func CreateResolver(p *Package) error {
 	f := NewFile(p.Name)
 	AddResolverStruct(f)
 
+	for _, sf := range ScalarFilters() {
+		AddScalarFilterInputStruct(sf, f)
+	}
+
+	for _, sf := range EnumFilters(p.Model.Types) {
+		AddScalarFilterInputStruct(sf, f)
+	}
+
+	if p.Model.Relay {
+		AddGlobalIDFilterFun(f)
+	}
+
+	if p.Model.SupportsCursorPagination() {
+		AddPageInfoResolver(f)
+	}
+
 	for _, e := range p.Model.Entities {
 
 		AddTypeResolver(e, p.Model, f)
 
 		if e.SupportsOperation("create") {
 
+			AddEntityFromCreateInputFun(e, f)
+			AddCreateFromInputFun(e, f)
 			AddCreateMutationResolverFun(e, f)
 
+			if HasCreateInput(e) {
+				AddInputStruct(GraphqlCreateInputFromEntity(e), f)
+			}
+			AddCreateManyMutationResolverFun(e, f)
+
 		}
 
 		if e.SupportsOperation("update") {
 			AddUpdateMutationResolverFun(e, f)
+
+			AddInputStruct(GraphqlUpdateInputFromEntity(e), f)
+			AddUpdateManyMutationResolverFun(e, f)
+
+			if SupportsPatch(e) {
+				AddPatchMutationResolverFun(e, f)
+			}
 		}
 
 		if e.SupportsOperation("delete") {
 			AddDeleteMutationResolverFun(e, f)
+			AddDeleteManyMutationResolverFun(e, f)
+		}
+
+		if e.SupportsOperation("upsert") {
+			AddUpsertMutationResolverFun(e, f)
+		}
+
+		for _, event := range SubscriptionEvents(e) {
+			AddSubscriptionResolverFun(e, event, f)
 		}
 
 		if e.SupportsOperation("find") {
 
+			AddEntityFilterInputStruct(e, f)
+			AddEntityOrderInputStruct(e, f)
+
 			for _, a := range e.Attributes {
 				if a.HasModifier("indexed") && a.HasModifier("unique") {
 					AddFinderByAttributeQueryResolverFun(e, a, f)
+				} else if a.HasModifier("indexed") {
+					if e.SupportsOffsetPagination() {
+						AddListFinderByAttributeQueryResolverFun(e, a, f)
+					}
+
+					if e.SupportsCursorPagination() {
+						AddConnectionByAttributeQueryResolverFun(e, a, f)
+					}
 				}
 			}
 
+			if e.SupportsCursorPagination() {
+				AddConnectionResolvers(e, f)
+			}
+
 			for _, r := range e.Relations {
 				if r.HasModifier("belongsTo") || r.HasModifier("hasOne") {
-					AddFinderByRelationQueryResolverFun(e, r, f)
+					if e.SupportsOffsetPagination() {
+						AddFinderByRelationQueryResolverFun(e, r, f)
+					}
+
+					if e.SupportsCursorPagination() {
+						AddConnectionByRelationQueryResolverFun(e, r, f)
+					}
 				}
 			}
 
-			AddFinderForAllQueryResolverFun(e, f)
+			if e.SupportsOffsetPagination() {
+				AddFinderForAllQueryResolverFun(e, f)
+			}
+
+			if e.SupportsCursorPagination() {
+				AddConnectionForAllQueryResolverFun(e, f)
+			}
+
+			if SupportsSearch(e) {
+				AddSearchQueryResolverFun(e, f)
+			}
+
+			AddAggregateResolvers(e, f)
+			AddCountQueryResolverFun(e, f)
+			AddAggregateQueryResolverFun(e, f)
 		}
 	}
 
@@ -54,14 +128,15 @@ func CreateResolver(p *Package) error {
 func AddResolverStruct(f *File) {
 
 	f.Type().Id("Resolver").Struct(
-		Id("Db").Op("*").Qual("database/sql", "DB"),
+		Id("Repo").Id("Repository"),
+		Id("Events").Op("*").Id("PubSub"),
 	)
 }
 
 func AddTypeResolver(e *Entity, m *Model, f *File) {
 
 	f.Type().Id(GraphqlResolverForEntity(e)).Struct(
-		Id("Db").Op("*").Qual("database/sql", "DB"),
+		Id("Repo").Id("Repository"),
 		Id("Data").Op("*").Id(e.Name),
 	)
 
@@ -92,6 +167,11 @@ func AddAttributeResolver(e *Entity, a *Attribute, f *File) {
 		Id("ctx").Qual("context", "Context"),
 	).Add(returnType).BlockFunc(func(g *Group) {
 		value := Id("r").Dot("Data").Dot(a.Name)
+		if a.Name == "ID" {
+			g.Return(CastToGraphqlID(value, e))
+			return
+		}
+
 		g.Return(CastToGraphqlType(value, GraphqlFieldFromAttribute(a)))
 	})
 }
@@ -113,6 +193,11 @@ func AddManyRelationResolver(e *Entity, r *Relation, m *Model, f *File) {
 
 	f.Func().Parens(Id("r").Op("*").Id(resolver)).Id(strings.Title(r.Alias())).Params(
 		Id("ctx").Qual("context", "Context"),
+		Id("args").StructFunc(func(g *Group) {
+			for _, a := range GraphqlFieldFromRelation(r).Args {
+				g.Id(strings.Title(a.Name)).Add(GraphqlResolverDataTypeFromGraphqlField(a))
+			}
+		}),
 	).Parens(List(
 		returnType,
 		Error(),
@@ -120,14 +205,28 @@ func AddManyRelationResolver(e *Entity, r *Relation, m *Model, f *File) {
 
 		TimeNow(g)
 
+		// without a limit, all related entities are returned
+		g.Id("limit").Op(":=").Int32().Call(Qual("math", "MaxInt32"))
+		g.If(Id("args").Dot("Limit").Op("!=").Nil()).Block(
+			Id("limit").Op("=").Op("*").Id("args").Dot("Limit"),
+		)
+
+		g.Id("offset").Op(":=").Int32().Call(Lit(0))
+		g.If(Id("args").Dot("Offset").Op("!=").Nil()).Block(
+			Id("offset").Op("=").Op("*").Id("args").Dot("Offset"),
+		)
+
 		g.List(
 			Id(r.Variable),
 			Err(),
-		).Op(":=").Id(fmt.Sprintf("Find%sBy%s", child.PluralName(), inverse.Alias())).Call(
-			Id("r").Dot("Db"),
+		).Op(":=").Id(LoadByRelationFunName(child, inverse)).Call(
+			Id("ctx"),
+			Id("r").Dot("Repo"),
 			Id("r").Dot("Data").Dot("ID"),
-			Lit(100),
-			Lit(0),
+			Id("args").Dot("Filter").Dot("Filter").Call(),
+			Id(EntitySortOrdersFunName(child)).Call(Id("args").Dot("OrderBy")),
+			Id("limit"),
+			Id("offset"),
 		)
 
 		MaybeReturnWrappedErrorAndIncrementCounter(
@@ -148,7 +247,7 @@ func AddManyRelationResolver(e *Entity, r *Relation, m *Model, f *File) {
 			g2.Id("resolvers").Op("=").Append(
 				Id("resolvers"),
 				Op("&").Id(GraphqlResolverForEntity(child)).Values(Dict{
-					Id("Db"):   Id("r").Dot("Db"),
+					Id("Repo"): Id("r").Dot("Repo"),
 					Id("Data"): Id(child.VarName()),
 				}),
 			)
@@ -187,8 +286,9 @@ func AddSimpleRelationResolver(e *Entity, r *Relation, m *Model, f *File) {
 		g.List(
 			Id(r.VarName()),
 			Err(),
-		).Op(":=").Id(fmt.Sprintf("Find%sByID", r.Entity)).Call(
-			Id("r").Dot("Db"),
+		).Op(":=").Id(LoadByIDFunName(&Entity{Name: r.Entity})).Call(
+			Id("ctx"),
+			Id("r").Dot("Repo"),
 			Id("r").Dot("Data").Dot(r.Alias()).Dot("ID"),
 		)
 
@@ -202,7 +302,7 @@ func AddSimpleRelationResolver(e *Entity, r *Relation, m *Model, f *File) {
 
 		g.Return(
 			Op("&").Add(Id(res)).Values(Dict{
-				Id("Db"):   Id("r").Dot("Db"),
+				Id("Repo"): Id("r").Dot("Repo"),
 				Id("Data"): Id(r.VarName()),
 			}),
 			Nil(),
@@ -220,20 +320,29 @@ func AddCreateMutationResolverFun(e *Entity, f *File) {
 
 		TimeNow(g)
 
-		g.Id(e.VarName()).Op(":=").Op("&").Id(e.Name).Values(DictFunc(EntityStructFromArgsDictFunc(e)))
-
-		MaybeAddHook(e, "create", "before", g)
+		BeginMutationTransaction(e, "create", g)
 
-		MaybeAddGenerators(e, "create", g)
+		g.Id("changes").Op(":=").Index().Id("ChangeEvent").Values()
+		g.List(Id(e.VarName()), Err()).Op(":=").Id("r").Dot(CreateFromInputFunName(e)).CallFunc(func(g2 *Group) {
+			g2.Id("ctx")
+			g2.Id("tx")
+			if HasCreateInput(e) {
+				g2.Op("&").Id("args").Dot("Input")
+			}
+			g2.Op("&").Id("changes")
+		})
+		g.If(Err().Op("!=").Nil()).Block(
+			Return(Nil(), Err()),
+		)
 
-		AddEntityRepoCall(e, "create", g)
+		CommitMutationTransaction(e, "create", g)
 
-		MaybeAddHook(e, "create", "after", g)
+		g.Id("r").Dot("Events").Dot("Publish").Call(Id("changes").Op("..."))
 
 		ObserveDuration(CreateMutationHistogramName(e), g)
 		g.Return(
 			Op("&").Add(Id(res)).Values(Dict{
-				Id("Db"):   Id("r").Dot("Db"),
+				Id("Repo"): Id("r").Dot("Repo"),
 				Id("Data"): Id(e.VarName()),
 			}),
 			Nil(),
@@ -241,6 +350,139 @@ func AddCreateMutationResolverFun(e *Entity, f *File) {
 	}, f)
 }
 
+// EntityFromCreateInputFunName returns the name of the resolver method
+// that builds an instance of the given entity from a create input
+func EntityFromCreateInputFunName(e *Entity) string {
+	return fmt.Sprintf("build%sFromInput", e.Name)
+}
+
+// AddEntityFromCreateInputFun defines the resolver method that builds
+// an instance of the given entity from a create input. Related
+// instances nested in the input are created first, as part of the
+// transaction, and the others are given by id. Its errors are counted
+// by the callers, for their own mutation
+func AddEntityFromCreateInputFun(e *Entity, f *File) {
+	funName := EntityFromCreateInputFunName(e)
+	v := e.VarName()
+
+	f.Comment(fmt.Sprintf("%s returns a %s with the values of the given input. Related instances nested in the input are created first, as part of the given transaction, and their events are queued to the given ones", funName, e.Name))
+	f.Func().Parens(Id("r").Op("*").Id("Resolver")).Id(funName).Params(
+		CreateFromInputParams(e)...,
+	).Parens(List(
+		Op("*").Id(e.Name),
+		Error(),
+	)).BlockFunc(func(g *Group) {
+		g.Id(v).Op(":=").Op("&").Id(e.Name).Values(DictFunc(EntityStructFromValuesDictFunc(e, "input", "create")))
+
+		MaybeValidateGlobalID(e, "create", true, g)
+
+		for _, r := range e.Relations {
+			if r.SupportsNestedCreate() {
+				AddNestedCreate(e, r, g)
+			}
+		}
+
+		g.Return(Id(v), Nil())
+	})
+}
+
+// AddNestedCreate adds the code that sets the given relation of an
+// entity being created, either to the related instance nested in the
+// input, which is created first, or to the one with the given id.
+// Exactly one of them must be given
+func AddNestedCreate(e *Entity, r *Relation, g *Group) {
+	field := RelationGraphqlFieldName(r)
+	nested := NestedCreateFieldName(r)
+	byID := Id("input").Dot(strings.Title(field))
+	byInput := Id("input").Dot(strings.Title(nested))
+
+	g.If(Parens(byID.Clone().Op("==").Nil()).Op("==").Parens(byInput.Clone().Op("==").Nil())).Block(
+		Return(Nil(), ValidationError(field, Lit(fmt.Sprintf("Either %s or %s must be given", field, nested)))),
+	)
+
+	g.If(byInput.Clone().Op("!=").Nil()).Block(
+		List(Id("related"), Err()).Op(":=").Id("r").Dot(CreateFromInputFunName(r.target)).Call(
+			Id("ctx"),
+			Id("tx"),
+			byInput.Clone(),
+			Id("changes"),
+		),
+		If(Err().Op("!=").Nil()).Block(
+			Return(Nil(), Err()),
+		),
+		Id(e.VarName()).Dot(r.Alias()).Op("=").Id("related"),
+	).Else().Block(
+		Id(e.VarName()).Dot(r.Alias()).Op("=").Op("&").Id(r.Entity).Values(Dict{
+			Id("ID"): CastRelationFromGraphql(Op("*").Add(byID.Clone()), r),
+		}),
+	)
+}
+
+// CreateFromInputParams returns the params of the resolver methods that
+// build and create an instance of the given entity from a create input.
+// Entities without a create input take none
+func CreateFromInputParams(e *Entity) []Code {
+	params := []Code{
+		Id("ctx").Qual("context", "Context"),
+		Id("tx").Id("Repository"),
+	}
+
+	if HasCreateInput(e) {
+		params = append(params, Id("input").Op("*").Id(GraphqlInputStructName(GraphqlCreateInputName(e))))
+	}
+
+	return append(params, Id("changes").Op("*").Index().Id("ChangeEvent"))
+}
+
+// CreateFromInputFunName returns the name of the resolver method that
+// creates an instance of the given entity from a create input
+func CreateFromInputFunName(e *Entity) string {
+	return fmt.Sprintf("create%sFromInput", e.Name)
+}
+
+// AddCreateFromInputFun defines the resolver method that creates an
+// instance of the given entity from a create input, as part of a
+// transaction. It runs the hooks and generators of the create
+// mutation, so that nested creates behave like top level ones. The
+// events of all the instances created are queued, to be published once
+// the transaction is committed
+func AddCreateFromInputFun(e *Entity, f *File) {
+	funName := CreateFromInputFunName(e)
+
+	f.Comment(fmt.Sprintf("%s creates a %s from the given input, along with the related instances nested in it, as part of the given transaction. Their events are queued to the given ones", funName, e.Name))
+	f.Func().Parens(Id("r").Op("*").Id("Resolver")).Id(funName).Params(
+		CreateFromInputParams(e)...,
+	).Parens(List(
+		Op("*").Id(e.Name),
+		Error(),
+	)).BlockFunc(func(g *Group) {
+		g.List(Id(e.VarName()), Err()).Op(":=").Id("r").Dot(EntityFromCreateInputFunName(e)).CallFunc(func(g2 *Group) {
+			g2.Id("ctx")
+			g2.Id("tx")
+			if HasCreateInput(e) {
+				g2.Id("input")
+			}
+			g2.Id("changes")
+		})
+		g.If(Err().Op("!=").Nil()).Block(
+			Id(MutationErrorCounterName(e, "create")).Dot("Inc").Call(),
+			Return(Nil(), Err()),
+		)
+
+		MaybeAddHook(e, "create", "before", g)
+
+		MaybeAddGenerators(e, "create", g)
+
+		AddEntityRepoCall(e, "create", g)
+
+		MaybeAddHook(e, "create", "after", g)
+
+		QueueEvent(e, "created", Id(e.VarName()), g)
+
+		g.Return(Id(e.VarName()), Nil())
+	})
+}
+
 // AddUpdateResolverFun defines an update resolver function for the given
 // entity
 func AddUpdateMutationResolverFun(e *Entity, f *File) {
@@ -249,7 +491,12 @@ func AddUpdateMutationResolverFun(e *Entity, f *File) {
 	ResolverFun(fun, func(g *Group) {
 		TimeNow(g)
 
-		g.Id(e.VarName()).Op(":=").Op("&").Id(e.Name).Values(DictFunc(EntityStructFromArgsDictFunc(e)))
+		g.Id("input").Op(":=").Id("args").Dot("Input")
+		g.Id(e.VarName()).Op(":=").Op("&").Id(e.Name).Values(DictFunc(EntityStructFromValuesDictFunc(e, "input", "update")))
+
+		MaybeRejectUnknownGlobalID(e, "update", Id("input").Dot("Id"), g)
+
+		BeginMutationTransaction(e, "update", g)
 
 		MaybeAddHook(e, "update", "before", g)
 
@@ -259,11 +506,151 @@ func AddUpdateMutationResolverFun(e *Entity, f *File) {
 
 		MaybeAddHook(e, "update", "after", g)
 
+		CommitMutationTransaction(e, "update", g)
+
+		// the repository fails when no row has the id, so only changes
+		// to stored instances are published
+		PublishEvent(e, "updated", Id(e.VarName()), g)
+
 		ObserveDuration(UpdateMutationHistogramName(e), g)
 
 		g.Return(
 			Op("&").Add(Id(res)).Values(Dict{
-				Id("Db"):   Id("r").Dot("Db"),
+				Id("Repo"): Id("r").Dot("Repo"),
+				Id("Data"): Id(e.VarName()),
+			}),
+			Nil(),
+		)
+	}, f)
+}
+
+// AddPatchMutationResolverFun defines a resolver function that
+// partially updates an instance of the given entity. The stored
+// instance is found first, so that hooks receive it along with the
+// patched one. Patches run the update generators
+func AddPatchMutationResolverFun(e *Entity, f *File) {
+	fun := GraphqlPatchMutationFromEntity(e)
+	res := GraphqlResolverResult(fun)
+	ResolverFun(fun, func(g *Group) {
+		TimeNow(g)
+
+		g.Id("patch").Op(":=").Op("&").Id(EntityPatchName(e)).Values()
+		PatchFromArgs(e, g)
+
+		BeginMutationTransaction(e, "patch", g)
+
+		findFun := FindEntityByAttributeFunName(e, &Attribute{Name: "ID"})
+		g.List(Id("stored"), Err()).Op(":=").Id("tx").Dot(findFun).Call(
+			Id("ctx"),
+			CastFromGraphqlID(Id("args").Dot("Id"), e),
+		)
+
+		MaybeReturnWrappedErrorAndIncrementCounter(
+			fmt.Sprintf("Error calling function %s", findFun),
+			MutationErrorCounterName(e, "patch"),
+			g,
+		)
+
+		g.Id("patched").Op(":=").Id("patch").Dot("Apply").Call(Id("stored"))
+
+		MaybeAddHook(e, "patch", "before", g)
+
+		MaybeAddPatchGenerators(e, g)
+
+		// hooks and generators change the patched instance, so the
+		// patch that is stored is built from it
+		g.Id("patch").Op("=").Id(NewEntityPatchFunName(e)).Call(Id("stored"), Id("patched"))
+
+		AddPatchEnumValidations(e, g)
+
+		repoFun := PatchEntityFunName(e)
+		g.List(Id("patched"), Err()).Op("=").Id("tx").Dot(repoFun).Call(
+			Id("ctx"),
+			Id("stored").Dot("ID"),
+			Id("patch"),
+		)
+
+		MaybeReturnWrappedErrorAndIncrementCounter(
+			fmt.Sprintf("Error calling function %s", repoFun),
+			MutationErrorCounterName(e, "patch"),
+			g,
+		)
+
+		MaybeAddHook(e, "patch", "after", g)
+
+		CommitMutationTransaction(e, "patch", g)
+
+		PublishEvent(e, "updated", Id("patched"), g)
+
+		ObserveDuration(PatchMutationHistogramName(e), g)
+
+		g.Return(
+			Op("&").Add(Id(res)).Values(Dict{
+				Id("Repo"): Id("r").Dot("Repo"),
+				Id("Data"): Id("patched"),
+			}),
+			Nil(),
+		)
+	}, f)
+}
+
+// PatchFromArgs produces the code that sets the fields of the patch in
+// scope from the resolver args that are given, casting values from
+// Graphql into plain Golang types
+func PatchFromArgs(e *Entity, g *Group) {
+	for _, a := range PatchAttributes(e) {
+		arg := Id("args").Dot(strings.Title(AttributeGraphqlFieldName(a)))
+		g.If(arg.Clone().Op("!=").Nil()).Block(
+			Id("value").Op(":=").Add(CastAttributeFromGraphql(Op("*").Add(arg.Clone()), e, a)),
+			Id("patch").Dot(a.Name).Op("=").Op("&").Id("value"),
+		)
+	}
+
+	for _, r := range PatchRelations(e) {
+		if r.HasModifier("generated") {
+			continue
+		}
+
+		arg := Id("args").Dot(strings.Title(r.Alias()))
+		g.If(arg.Clone().Op("!=").Nil()).Block(
+			Id("patch").Dot(r.Alias()).Op("=").Op("&").Id(r.Entity).Values(Dict{
+				Id("ID"): CastRelationFromGraphql(Op("*").Add(arg.Clone()), r),
+			}),
+		)
+	}
+}
+
+// AddUpsertMutationResolverFun defines an upsert resolver function for
+// the given entity
+func AddUpsertMutationResolverFun(e *Entity, f *File) {
+	fun := GraphqlUpsertMutationFromEntity(e)
+	res := GraphqlResolverResult(fun)
+	ResolverFun(fun, func(g *Group) {
+		TimeNow(g)
+
+		g.Id(e.VarName()).Op(":=").Op("&").Id(e.Name).Values(DictFunc(EntityStructFromArgsDictFunc(e, "upsert")))
+
+		MaybeValidateGlobalID(e, "upsert", false, g)
+
+		BeginMutationTransaction(e, "upsert", g)
+
+		MaybeAddHook(e, "upsert", "before", g)
+
+		MaybeAddGenerators(e, "upsert", g)
+
+		AddEntityRepoCall(e, "upsert", g)
+
+		MaybeAddHook(e, "upsert", "after", g)
+
+		CommitMutationTransaction(e, "upsert", g)
+
+		PublishEvent(e, "updated", Id(e.VarName()), g)
+
+		ObserveDuration(UpsertMutationHistogramName(e), g)
+
+		g.Return(
+			Op("&").Add(Id(res)).Values(Dict{
+				Id("Repo"): Id("r").Dot("Repo"),
 				Id("Data"): Id(e.VarName()),
 			}),
 			Nil(),
@@ -275,22 +662,42 @@ func AddUpdateMutationResolverFun(e *Entity, f *File) {
 // dictionary and builds all the fields read from args, and adapts them
 // into a struct of the given entity, casting values from Graphql into
 // plain Golang types
-func EntityStructFromArgsDictFunc(e *Entity) func(Dict) {
+func EntityStructFromArgsDictFunc(e *Entity, mutation string) func(Dict) {
+	return EntityStructFromValuesDictFunc(e, "args", mutation)
+}
+
+// EntityStructFromValuesDictFunc builds a function that takes a
+// dictionary and builds all the fields read from the given variable,
+// which is either the resolver args, or a generated input struct. IDs
+// that clients do not supply to the given mutation are generated
+func EntityStructFromValuesDictFunc(e *Entity, values string, mutation string) func(Dict) {
 	return func(d Dict) {
 		// build a input for the entity, taking values
 		// from the resolver args
 		for _, a := range e.Attributes {
-			f := GraphqlFieldFromAttribute(a)
-			value := Id("args").Dot(strings.Title(AttributeGraphqlFieldName(a)))
-			d[Id(a.Name)] = CastFromGraphqlType(value, f)
+			if a.Name == "ID" && !ClientSuppliesID(e, mutation) {
+				// IDs assigned by the database are left empty
+				if !e.GeneratesIDInDatabase() {
+					d[Id(a.Name)] = Id(NewIDFunName(e)).Call()
+				}
+				continue
+			}
+
+			value := Id(values).Dot(strings.Title(AttributeGraphqlFieldName(a)))
+			d[Id(a.Name)] = CastAttributeFromGraphql(value, e, a)
 		}
 
 		for _, r := range e.Relations {
+			// nested creates are resolved separately
+			if mutation == "create" && r.SupportsNestedCreate() {
+				continue
+			}
+
 			if !r.HasModifier("generated") && (r.HasModifier("hasOne") || r.HasModifier("belongsTo")) {
 				d[Id(r.Alias())] = Op("&").Id(r.Entity).Values(Dict{
-					Id("ID"): CastFromGraphqlType(
-						Id("args").Dot(strings.Title(r.Alias())),
-						GraphqlInputFieldFromRelation(r),
+					Id("ID"): CastRelationFromGraphql(
+						Id(values).Dot(strings.Title(r.Alias())),
+						r,
 					),
 				})
 			}
@@ -298,87 +705,1047 @@ func EntityStructFromArgsDictFunc(e *Entity) func(Dict) {
 	}
 }
 
-// AddDeleteMutationResolverFun defines a delete resolver function for the given
-// entity
-func AddDeleteMutationResolverFun(e *Entity, f *File) {
-	fun := GraphqlDeleteMutationFromEntity(e)
+// AddInputStruct builds the Golang struct that the Graphql server
+// populates from the given input type
+func AddInputStruct(in *GraphqlInput, f *File) {
+	f.Type().Id(GraphqlInputStructName(in.Name)).StructFunc(func(g *Group) {
+		for _, a := range in.Fields {
+			g.Id(strings.Title(a.Name)).Add(GraphqlResolverDataTypeFromGraphqlField(a))
+		}
+	})
+}
+
+// AddScalarFilterInputStruct builds the Golang struct populated from
+// the Graphql input of the given scalar filter, and the method that
+// converts it into the filter used by the repository
+func AddScalarFilterInputStruct(sf *ScalarFilter, f *File) {
+	in := GraphqlScalarFilterInput(sf)
+	AddInputStruct(in, f)
+
+	f.Comment(fmt.Sprintf("Filter converts the input into a %s, or nil if no input was given", sf.Filter))
+	f.Func().Parens(Id("i").Op("*").Id(GraphqlInputStructName(in.Name))).Id("Filter").Params().Op("*").Id(sf.Filter).BlockFunc(func(g *Group) {
+		g.If(Id("i").Op("==").Nil()).Block(
+			Return(Nil()),
+		)
+
+		g.Id("f").Op(":=").Op("&").Id(sf.Filter).Values()
+
+		// enums are compared as the strings they are stored as
+		value := &GraphqlField{DataType: sf.DataType}
+		cast := func(s *Statement) *Statement {
+			if sf.Enum {
+				return String().Call(s)
+			}
+
+			return CastFromGraphqlType(s, value)
+		}
+
+		for _, op := range ScalarFilterOperators(sf) {
+			g.If(Id("i").Dot(op[0]).Op("!=").Nil()).Block(
+				Id("v").Op(":=").Add(cast(Op("*").Id("i").Dot(op[0]))),
+				Id("f").Dot(op[0]).Op("=").Op("&").Id("v"),
+			)
+		}
+
+		g.If(Id("i").Dot("In").Op("!=").Nil()).Block(
+			Id("f").Dot("In").Op("=").Index().Add(ScalarFilterDataType(sf)).Values(),
+			For(List(Id("_"), Id("v")).Op(":=").Range().Op("*").Id("i").Dot("In")).Block(
+				Id("f").Dot("In").Op("=").Append(Id("f").Dot("In"), cast(Id("v"))),
+			),
+		)
+
+		if sf.Contains {
+			g.Id("f").Dot("Contains").Op("=").Id("i").Dot("Contains")
+		}
+
+		g.Id("f").Dot("IsNull").Op("=").Id("i").Dot("IsNull")
+		g.Return(Id("f"))
+	})
+}
+
+// AddGlobalIDFilterFun builds the method that converts the input of
+// an ID filter into the filter used by the repository, decoding the
+// global IDs it compares with
+func AddGlobalIDFilterFun(f *File) {
+	sf := ScalarFilterForDataType("ID")
+	in := GraphqlScalarFilterInput(sf)
+
+	f.Comment(fmt.Sprintf("GlobalFilter converts the input into a %s on the ids held by global IDs of the given type, or nil if no input was given", sf.Filter))
+	f.Func().Parens(Id("i").Op("*").Id(GraphqlInputStructName(in.Name))).Id("GlobalFilter").Params(
+		Id("typ").String(),
+	).Op("*").Id(sf.Filter).BlockFunc(func(g *Group) {
+		g.Id("f").Op(":=").Id("i").Dot("Filter").Call()
+		g.If(Id("f").Op("==").Nil()).Block(
+			Return(Nil()),
+		)
+
+		for _, op := range ScalarFilterOperators(sf) {
+			g.If(Id("f").Dot(op[0]).Op("!=").Nil()).Block(
+				Id("v").Op(":=").Id("LocalID").Call(
+					Id("typ"),
+					Qual("github.com/graph-gophers/graphql-go", "ID").Call(Op("*").Id("f").Dot(op[0])),
+				),
+				Id("f").Dot(op[0]).Op("=").Op("&").Id("v"),
+			)
+		}
+
+		g.For(List(Id("n"), Id("v")).Op(":=").Range().Id("f").Dot("In")).Block(
+			Id("f").Dot("In").Index(Id("n")).Op("=").Id("LocalID").Call(
+				Id("typ"),
+				Qual("github.com/graph-gophers/graphql-go", "ID").Call(Id("v")),
+			),
+		)
+
+		g.Return(Id("f"))
+	})
+}
+
+// AddEntityFilterInputStruct builds the Golang struct populated from
+// the Graphql filter input of the given entity, and the method that
+// converts it into the filter used by the repository
+func AddEntityFilterInputStruct(e *Entity, f *File) {
+	in := GraphqlFilterInputFromEntity(e)
+	AddInputStruct(in, f)
+
+	f.Comment(fmt.Sprintf("Filter converts the input into a %s, or nil if no input was given", EntityFilterName(e)))
+	f.Func().Parens(Id("i").Op("*").Id(GraphqlInputStructName(in.Name))).Id("Filter").Params().Op("*").Id(EntityFilterName(e)).BlockFunc(func(g *Group) {
+		g.If(Id("i").Op("==").Nil()).Block(
+			Return(Nil()),
+		)
+
+		g.Id("f").Op(":=").Op("&").Id(EntityFilterName(e)).Values(DictFunc(func(d Dict) {
+			for _, a := range e.Attributes {
+				input := Id("i").Dot(strings.Title(AttributeGraphqlFieldName(a)))
+				if a.Name == "ID" && e.UsesGlobalIDs() {
+					d[Id(a.Name)] = input.Dot("GlobalFilter").Call(Lit(e.Name))
+				} else {
+					d[Id(a.Name)] = input.Dot("Filter").Call()
+				}
+			}
+
+			for _, r := range e.Relations {
+				if r.HasModifier("belongsTo") || r.HasModifier("hasOne") {
+					input := Id("i").Dot(strings.Title(RelationGraphqlFieldName(r)))
+					if r.target.UsesGlobalIDs() {
+						d[Id(r.Alias())] = input.Dot("GlobalFilter").Call(Lit(r.Entity))
+					} else {
+						d[Id(r.Alias())] = input.Dot("Filter").Call()
+					}
+				}
+			}
+		}))
+
+		for _, name := range []string{"And", "Or"} {
+			g.If(Id("i").Dot(name).Op("!=").Nil()).Block(
+				For(List(Id("_"), Id("nested")).Op(":=").Range().Op("*").Id("i").Dot(name)).Block(
+					Id("f").Dot(name).Op("=").Append(Id("f").Dot(name), Id("nested").Dot("Filter").Call()),
+				),
+			)
+		}
+
+		g.Return(Id("f"))
+	})
+}
+
+// AddEntityOrderInputStruct builds the Golang struct populated from
+// the Graphql order input of the given entity, and the function that
+// converts a list of them into the sort orders used by the repository
+func AddEntityOrderInputStruct(e *Entity, f *File) {
+	in := GraphqlOrderInputFromEntity(e)
+	AddInputStruct(in, f)
+
+	funName := EntitySortOrdersFunName(e)
+	f.Comment(fmt.Sprintf("%s converts the given inputs into sort orders, or nil if no inputs were given", funName))
+	f.Func().Id(funName).Params(
+		Id("inputs").Op("*").Index().Op("*").Id(GraphqlInputStructName(in.Name)),
+	).Index().Op("*").Id("SortOrder").BlockFunc(func(g *Group) {
+		g.If(Id("inputs").Op("==").Nil()).Block(
+			Return(Nil()),
+		)
+
+		g.Id("orders").Op(":=").Index().Op("*").Id("SortOrder").Values()
+		g.For(List(Id("_"), Id("i")).Op(":=").Range().Op("*").Id("inputs")).BlockFunc(func(g2 *Group) {
+			g2.Id("o").Op(":=").Op("&").Id("SortOrder").Values(Dict{
+				Id("Field"): Id("i").Dot("Field"),
+			})
+			g2.If(Id("i").Dot("Direction").Op("!=").Nil()).Block(
+				Id("o").Dot("Direction").Op("=").Op("*").Id("i").Dot("Direction"),
+			)
+			g2.Id("orders").Op("=").Append(Id("orders"), Id("o"))
+		})
+
+		g.Return(Id("orders"))
+	})
+}
+
+// EntitySortOrdersFunName returns the name of the function that
+// converts order inputs of the given entity into sort orders
+func EntitySortOrdersFunName(e *Entity) string {
+	return fmt.Sprintf("%sSortOrders", e.Name)
+}
+
+// AddCreateManyMutationResolverFun defines a resolver function that
+// creates a list of instances of the given entity. Hooks and generators
+// run for each one of them, and all changes are part of the same
+// transaction
+func AddCreateManyMutationResolverFun(e *Entity, f *File) {
+	fun := GraphqlCreateManyMutationFromEntity(e)
+	plural := VarName(e.PluralName())
+
+	ResolverFun(fun, func(g *Group) {
+		TimeNow(g)
+
+		BeginMutationTransaction(e, "createMany", g)
+
+		g.Id("changes").Op(":=").Index().Id("ChangeEvent").Values()
+		g.Id(plural).Op(":=").Index().Op("*").Id(e.Name).Values()
+
+		// without a create input, the given number of instances is
+		// built
+		loop := []Code{
+			Id("i").Op(":=").Id("int32").Call(Lit(0)),
+			Id("i").Op("<").Id("args").Dot("Count"),
+			Id("i").Op("++"),
+		}
+		if HasCreateInput(e) {
+			loop = []Code{List(Id("_"), Id("input")).Op(":=").Range().Id("args").Dot("Input")}
+		}
+
+		g.For(loop...).Block(
+			List(Id(e.VarName()), Err()).Op(":=").Id("r").Dot(EntityFromCreateInputFunName(e)).CallFunc(func(g2 *Group) {
+				g2.Id("ctx")
+				g2.Id("tx")
+				if HasCreateInput(e) {
+					g2.Id("input")
+				}
+				g2.Op("&").Id("changes")
+			}),
+			If(Err().Op("!=").Nil()).Block(
+				Id(MutationErrorCounterName(e, "createMany")).Dot("Inc").Call(),
+				Return(Nil(), Err()),
+			),
+			Id(plural).Op("=").Append(Id(plural), Id(e.VarName())),
+		)
+
+		MaybeForEachEntity(e, HasHook(e, "create", "before") || e.HasGenerators(), g, func(g2 *Group) {
+			MaybeAddHook(e, "createMany", "before", g2)
+			MaybeAddGenerators(e, "createMany", g2)
+		})
+
+		AddEntitiesRepoCall(e, "createMany", InsertManyEntitiesFunName(e), plural, "=", g)
+
+		MaybeForEachEntity(e, HasHook(e, "create", "after"), g, func(g2 *Group) {
+			MaybeAddHook(e, "createMany", "after", g2)
+		})
+
+		CommitMutationTransaction(e, "createMany", g)
+
+		// the related instances nested in the inputs were created first
+		g.Id("r").Dot("Events").Dot("Publish").Call(Id("changes").Op("..."))
+		PublishEntitiesEvents(e, "created", g)
+
+		ObserveDuration(CreateManyMutationHistogramName(e), g)
+		ReturnEntityResolvers(e, plural, g)
+	}, f)
+}
+
+// AddUpdateManyMutationResolverFun defines a resolver function that
+// updates a list of instances of the given entity. Hooks and generators
+// run for each one of them, and all changes are part of the same
+// transaction
+func AddUpdateManyMutationResolverFun(e *Entity, f *File) {
+	fun := GraphqlUpdateManyMutationFromEntity(e)
+	plural := VarName(e.PluralName())
+
+	ResolverFun(fun, func(g *Group) {
+		TimeNow(g)
+
+		EntitiesFromInputs(e, "update", g)
+
+		BeginMutationTransaction(e, "updateMany", g)
+
+		MaybeForEachEntity(e, HasHook(e, "update", "before") || e.HasGenerators(), g, func(g2 *Group) {
+			MaybeAddHook(e, "updateMany", "before", g2)
+			MaybeAddGenerators(e, "updateMany", g2)
+		})
+
+		AddEntitiesRepoCall(e, "updateMany", UpdateManyEntitiesFunName(e), plural, "=", g)
+
+		MaybeForEachEntity(e, HasHook(e, "update", "after"), g, func(g2 *Group) {
+			MaybeAddHook(e, "updateMany", "after", g2)
+		})
+
+		CommitMutationTransaction(e, "updateMany", g)
+
+		PublishEntitiesEvents(e, "updated", g)
+
+		ObserveDuration(UpdateManyMutationHistogramName(e), g)
+		ReturnEntityResolvers(e, plural, g)
+	}, f)
+}
+
+// AddDeleteManyMutationResolverFun defines a resolver function that
+// deletes a list of instances of the given entity, by their ids. Hooks
+// run for each id, and all changes are part of the same transaction
+func AddDeleteManyMutationResolverFun(e *Entity, f *File) {
+	fun := GraphqlDeleteManyMutationFromEntity(e)
+	plural := VarName(e.PluralName())
+
+	ResolverFun(fun, func(g *Group) {
+		TimeNow(g)
+
+		g.Id("ids").Op(":=").Index().String().Values()
+		g.For(
+			List(Id("_"), Id("id")).Op(":=").Range().Id("args").Dot("Ids"),
+		).Block(
+			Id("ids").Op("=").Append(
+				Id("ids"),
+				CastFromGraphqlID(Id("id"), e),
+			),
+		)
+
+		BeginMutationTransaction(e, "deleteMany", g)
+
+		MaybeForEachId(HasHook(e, "delete", "before"), g, func(g2 *Group) {
+			MaybeAddHook(e, "deleteMany", "before", g2)
+		})
+
+		AddEntitiesRepoCall(e, "deleteMany", DeleteManyEntitiesFunName(e), "ids", ":=", g)
+
+		if HasHook(e, "delete", "after") {
+			g.For(
+				List(Id("_"), Id(e.VarName())).Op(":=").Range().Id(plural),
+			).BlockFunc(func(g2 *Group) {
+				MaybeAddHook(e, "deleteMany", "after", g2)
+			})
+		}
+
+		CommitMutationTransaction(e, "deleteMany", g)
+
+		PublishEntitiesEvents(e, "deleted", g)
+
+		ObserveDuration(DeleteManyMutationHistogramName(e), g)
+		ReturnEntityResolvers(e, plural, g)
+	}, f)
+}
+
+// EntitiesFromInputs adds the code that converts the list of inputs
+// received by a bulk mutation into a slice of structs of the given
+// entity
+func EntitiesFromInputs(e *Entity, mutation string, g *Group) {
+	plural := VarName(e.PluralName())
+
+	g.Id(plural).Op(":=").Index().Op("*").Id(e.Name).Values()
+	g.For(
+		List(Id("_"), Id("input")).Op(":=").Range().Id("args").Dot("Input"),
+	).Block(
+		Id(plural).Op("=").Append(
+			Id(plural),
+			Op("&").Id(e.Name).Values(DictFunc(EntityStructFromValuesDictFunc(e, "input", mutation))),
+		),
+	)
+}
+
+// MaybeForEachEntity adds a loop over the slice of entities in scope,
+// if the given condition holds. This avoids generating empty loops
+// when an entity has no hooks or generators
+func MaybeForEachEntity(e *Entity, cond bool, g *Group, body func(*Group)) {
+	if cond {
+		g.For(
+			List(Id("_"), Id(e.VarName())).Op(":=").Range().Id(VarName(e.PluralName())),
+		).BlockFunc(body)
+	}
+}
+
+// MaybeForEachId adds a loop over the slice of ids in scope, if the
+// given condition holds
+func MaybeForEachId(cond bool, g *Group, body func(*Group)) {
+	if cond {
+		g.For(
+			List(Id("_"), Id("id")).Op(":=").Range().Id("ids"),
+		).BlockFunc(body)
+	}
+}
+
+// AddEntitiesRepoCall adds the code that calls the given bulk repo
+// function, as part of the mutation transaction. Instances that are
+// created or updated have their enums validated first
+func AddEntitiesRepoCall(e *Entity, mutation string, repoFun string, arg string, op string, g *Group) {
+	MaybeForEachEntity(e, MutationOperation(mutation) != "delete" && e.HasEnums(), g, func(g2 *Group) {
+		AddEnumValidations(e, mutation, g2)
+	})
+
+	g.List(
+		Id(VarName(e.PluralName())),
+		Err(),
+	).Op(op).Id("tx").Dot(repoFun).Call(
+		Id("ctx"),
+		Id(arg),
+	)
+
+	MaybeReturnWrappedErrorAndIncrementCounter(
+		fmt.Sprintf("Error calling function %s", repoFun),
+		MutationErrorCounterName(e, mutation),
+		g,
+	)
+}
+
+// ReturnEntityResolvers adds the code that wraps each entity of the
+// given slice into a resolver, and returns them all
+func ReturnEntityResolvers(e *Entity, slice string, g *Group) {
+	g.Id("resolvers").Op(":=").Index().Op("*").Id(GraphqlResolverForEntity(e)).Values()
+
+	g.For(
+		List(
+			Id("_"),
+			Id(e.VarName()),
+		).Op(":=").Range().Id(slice),
+	).BlockFunc(func(g2 *Group) {
+
+		g2.Id("resolvers").Op("=").Append(
+			Id("resolvers"),
+			Op("&").Id(GraphqlResolverForEntity(e)).Values(Dict{
+				Id("Repo"): Id("r").Dot("Repo"),
+				Id("Data"): Id(e.VarName()),
+			}),
+		)
+	})
+
+	g.Return(
+		Op("&").Id("resolvers"),
+		Nil(),
+	)
+}
+
+// AddDeleteMutationResolverFun defines a delete resolver function for the given
+// entity
+func AddDeleteMutationResolverFun(e *Entity, f *File) {
+	fun := GraphqlDeleteMutationFromEntity(e)
+	res := GraphqlResolverResult(fun)
+	ResolverFun(fun, func(g *Group) {
+
+		TimeNow(g)
+
+		g.Id("id").Op(":=").Add(
+			CastFromGraphqlID(Id("args").Dot("Id"), e),
+		)
+
+		BeginMutationTransaction(e, "delete", g)
+
+		MaybeAddHook(e, "delete", "before", g)
+		AddEntityRepoCall(e, "delete", g)
+		MaybeAddHook(e, "delete", "after", g)
+
+		CommitMutationTransaction(e, "delete", g)
+
+		PublishEvent(e, "deleted", Id(e.VarName()), g)
+
+		ObserveDuration(DeleteMutationHistogramName(e), g)
+
+		g.Return(
+			Op("&").Add(Id(res)).Values(Dict{
+				Id("Repo"): Id("r").Dot("Repo"),
+				Id("Data"): Id(e.VarName()),
+			}),
+			Nil(),
+		)
+	}, f)
+
+}
+
+// AddSubscriptionResolverFun defines a resolver function that streams
+// the given kind of change to instances of the given entity, as they
+// are published by mutations. Changes are skipped unless they match
+// the id, and the ids of the parents, given in the args
+func AddSubscriptionResolverFun(e *Entity, event string, f *File) {
+	fun := GraphqlSubscriptionFromEntity(e, event)
+	res := GraphqlResolverResult(fun)
+	funName := SubscriptionResolverFunName(e, event)
+	v := e.VarName()
+
+	f.Comment(fmt.Sprintf("%s streams the instances of type %s %s from now on, until the given context is done", funName, e.Name, event))
+	f.Func().Parens(Id("r").Op("*").Id("Resolver")).Id(funName).Params(
+		Id("ctx").Qual("context", "Context"),
+		GraphqlResolverArgs(fun),
+	).Parens(List(
+		Op("<-").Chan().Op("*").Id(res),
+		Error(),
+	)).BlockFunc(func(g *Group) {
+		g.Id("changes").Op(":=").Id("r").Dot("Events").Dot("Subscribe").Call(
+			Id("ctx"),
+			Id(EventTopicName(e, event)),
+		)
+
+		g.Id("c").Op(":=").Make(Chan().Op("*").Id(res))
+		g.Go().Func().Params().BlockFunc(func(g2 *Group) {
+			g2.Defer().Close(Id("c"))
+
+			g2.For(Id("data").Op(":=").Range().Id("changes")).BlockFunc(func(g3 *Group) {
+				g3.Id(v).Op(":=").Id("data").Assert(Op("*").Id(e.Name))
+
+				if e.HasID() {
+					arg := Id("args").Dot("Id")
+					g3.If(
+						arg.Clone().Op("!=").Nil().Op("&&").Id(v).Dot("ID").Op("!=").Add(
+							CastFromGraphqlID(Op("*").Add(arg.Clone()), e),
+						),
+					).Block(
+						Continue(),
+					)
+				}
+
+				for _, r := range SubscriptionRelations(e) {
+					arg := Id("args").Dot(strings.Title(r.Alias()))
+					related := Id(v).Dot(r.Alias())
+					g3.If(
+						arg.Clone().Op("!=").Nil().Op("&&").Parens(
+							related.Clone().Op("==").Nil().Op("||").Add(related.Clone()).Dot("ID").Op("!=").Add(
+								CastRelationFromGraphql(Op("*").Add(arg.Clone()), r),
+							),
+						),
+					).Block(
+						Continue(),
+					)
+				}
+
+				g3.Select().Block(
+					Case(Id("c").Op("<-").Op("&").Id(res).Values(Dict{
+						Id("Repo"): Id("r").Dot("Repo"),
+						Id("Data"): Id(v),
+					})).Block(),
+					Case(Op("<-").Id("ctx").Dot("Done").Call()).Block(
+						Return(),
+					),
+				)
+			})
+		}).Call()
+
+		g.Return(Id("c"), Nil())
+	})
+}
+
+// AddEntityRepoCall adds the code that calls the given repo function.
+// This function infers the right assignments and repo function to call
+// according to conventions. The repo function runs as part of the
+// mutation transaction
+func AddEntityRepoCall(e *Entity, mutation string, g *Group) {
+
+	// when creating or updating, the entity variable was already
+	// declared from the resolver args. When deleting, we only
+	// have an id so far
+	op := "="
+	varName := e.VarName()
+	if mutation == "delete" {
+		op = ":="
+		varName = "id"
+	}
+
+	if mutation != "delete" {
+		AddEnumValidations(e, mutation, g)
+	}
+
+	repoFun := EntityRepoFun(e, mutation)
+
+	g.List(
+		Id(e.VarName()),
+		Err(),
+	).Op(op).Id("tx").Dot(repoFun).Call(
+		Id("ctx"),
+		Id(varName),
+	)
+
+	MaybeReturnWrappedErrorAndIncrementCounter(
+		fmt.Sprintf("Error calling function %s", repoFun),
+		MutationErrorCounterName(e, mutation),
+		g,
+	)
+
+}
+
+// AddEnumValidations adds the code that rejects an instance of the
+// given entity, before it is stored, if an enum attribute holds a value
+// that is not in the model. Values from the api are always valid, but
+// hooks may convert any string
+func AddEnumValidations(e *Entity, mutation string, g *Group) {
+	for _, a := range e.Attributes {
+		if a.Enum() != nil {
+			AddEnumValidation(e, a, mutation, Op("!").Id(e.VarName()).Dot(a.Name).Dot("Valid").Call(), g)
+		}
+	}
+}
+
+// AddPatchEnumValidations adds the code that rejects the patch in
+// scope, before it is stored, if it sets an enum attribute to a value
+// that is not in the model
+func AddPatchEnumValidations(e *Entity, g *Group) {
+	for _, a := range PatchAttributes(e) {
+		if a.Enum() != nil {
+			field := Id("patch").Dot(a.Name)
+			AddEnumValidation(e, a, "patch", field.Clone().Op("!=").Nil().Op("&&").Op("!").Add(field.Clone()).Dot("Valid").Call(), g)
+		}
+	}
+}
+
+// AddEnumValidation adds the code that rejects the value of the given
+// enum attribute when the given condition holds
+func AddEnumValidation(e *Entity, a *Attribute, mutation string, invalid *Statement, g *Group) {
+	g.If(invalid).Block(
+		Id(MutationErrorCounterName(e, mutation)).Dot("Inc").Call(),
+		Return(Nil(), ValidationError(AttributeGraphqlFieldName(a), Lit(fmt.Sprintf("Invalid value of %s", a.Enum().Name)))),
+	)
+}
+
+// BeginMutationTransaction adds the code that opens the transaction
+// in which hooks, generators and the repo function of a mutation run.
+// The transaction is rolled back, unless committed by
+// CommitMutationTransaction
+func BeginMutationTransaction(e *Entity, mutation string, g *Group) {
+	g.List(Id("tx"), Err()).Op(":=").Id("r").Dot("Repo").Dot("Begin").Call(Id("ctx"))
+
+	MaybeReturnWrappedErrorAndIncrementCounter(
+		"Error opening transaction",
+		MutationErrorCounterName(e, mutation),
+		g,
+	)
+
+	DeferRollbackTransaction(g)
+}
+
+// CommitMutationTransaction adds the code that commits the
+// transaction opened by BeginMutationTransaction
+func CommitMutationTransaction(e *Entity, mutation string, g *Group) {
+	CommitTransaction(g)
+
+	MaybeReturnWrappedErrorAndIncrementCounter(
+		"Error committing transaction",
+		MutationErrorCounterName(e, mutation),
+		g,
+	)
+
+	// instances loaded before the mutation may be stale now
+	g.Id("ClearLoaders").Call(Id("ctx"))
+}
+
+// EntityRepoFun returns the repo entity to call from the given entity
+// and mutation
+func EntityRepoFun(e *Entity, mutation string) string {
+	return fmt.Sprintf(
+		"%s%s",
+		strcase.ToCamel(mutation),
+		e.Name,
+	)
+}
+
+// AddFinderForAllQueryResolverFun defines a resolver function for the given
+// entity.
+func AddFinderForAllQueryResolverFun(e *Entity, f *File) {
+	fun := GraphqlFinderQueryForAll(e)
+	res := GraphqlResolverResult(fun)
+
+	ResolverFun(fun, func(g *Group) {
+
+		TimeNow(g)
+
+		g.List(
+			Id(VarName(e.PluralName())),
+			Err(),
+		).Op(":=").Id("r").Dot("Repo").Dot(fmt.Sprintf("FindAll%s", e.PluralName())).Call(
+			Id("ctx"),
+			Id("args").Dot("Filter").Dot("Filter").Call(),
+			Id(EntitySortOrdersFunName(e)).Call(Id("args").Dot("OrderBy")),
+			Id("args").Dot("Limit"),
+			Id("args").Dot("Offset"),
+		)
+
+		MaybeReturnWrappedErrorAndIncrementCounter(
+			fmt.Sprintf("Error finding all %s ", e.PluralName()),
+			FindAllQueryErrorCounterName(e),
+			g,
+		)
+
+		g.Id("resolvers").Op(":=").Id(res).Values(Dict{})
+
+		g.For(
+			List(
+				Id("_"),
+				Id(e.VarName()),
+			).Op(":=").Range().Id(VarName(e.PluralName())),
+		).BlockFunc(func(g2 *Group) {
+
+			g2.Id("resolvers").Op("=").Append(
+				Id("resolvers"),
+				Op("&").Id(GraphqlResolverForEntity(e)).Values(Dict{
+					Id("Repo"): Id("r").Dot("Repo"),
+					Id("Data"): Id(e.VarName()),
+				}),
+			)
+		})
+
+		ObserveDuration(FindAllQueryHistogramName(e), g)
+
+		g.Return(
+			Op("&").Id("resolvers"),
+			Nil(),
+		)
+
+	}, f)
+}
+
+// AddFinderByAttributeQueryResolverFun defines a resolver function for the given
+// indexed and
+// unique attribute of the given entity
+func AddFinderByAttributeQueryResolverFun(e *Entity, a *Attribute, f *File) {
+	fun := GraphqlFinderQueryFromAttribute(e, a)
+	res := GraphqlResolverResult(fun)
+
+	ResolverFun(fun, func(g *Group) {
+
+		TimeNow(g)
+
+		value := Id("args").Dot(strings.Title(AttributeGraphqlFieldName(a)))
+		g.List(
+			Id(e.VarName()),
+			Err(),
+		).Op(":=").Id("r").Dot("Repo").Dot(fmt.Sprintf("Find%sBy%s", e.Name, a.Name)).Call(
+			Id("ctx"),
+			CastAttributeFromGraphql(value, e, a),
+		)
+
+		MaybeReturnWrappedErrorAndIncrementCounter(
+			fmt.Sprintf("Error finding %s by %s", e.Name, a.Name),
+			FindByAttributeQueryErrorCounterName(e, a),
+			g,
+		)
+
+		ObserveDuration(FindByAttributeQueryHistogramName(e, a), g)
+
+		g.Return(
+			Op("&").Add(Id(res)).Values(Dict{
+				Id("Repo"): Id("r").Dot("Repo"),
+				Id("Data"): Id(e.VarName()),
+			}),
+			Nil(),
+		)
+	}, f)
+}
+
+// AddListFinderByAttributeQueryResolverFun defines a resolver function
+// that finds a list of instances of the given entity by an indexed
+// attribute that is not unique
+func AddListFinderByAttributeQueryResolverFun(e *Entity, a *Attribute, f *File) {
+	fun := GraphqlListFinderQueryFromAttribute(e, a)
+	res := GraphqlResolverResult(fun)
+
+	ResolverFun(fun, func(g *Group) {
+
+		TimeNow(g)
+
+		value := Id("args").Dot(strings.Title(AttributeGraphqlFieldName(a)))
+		g.List(
+			Id(VarName(e.PluralName())),
+			Err(),
+		).Op(":=").Id("r").Dot("Repo").Dot(FindEntitiesByAttributeFunName(e, a)).Call(
+			Id("ctx"),
+			CastAttributeFromGraphql(value, e, a),
+			Id("args").Dot("Filter").Dot("Filter").Call(),
+			Id(EntitySortOrdersFunName(e)).Call(Id("args").Dot("OrderBy")),
+			Id("args").Dot("Limit"),
+			Id("args").Dot("Offset"),
+		)
+
+		MaybeReturnWrappedErrorAndIncrementCounter(
+			fmt.Sprintf("Error finding %s by %s", e.PluralName(), a.Name),
+			FindListByAttributeQueryErrorCounterName(e, a),
+			g,
+		)
+
+		g.Id("resolvers").Op(":=").Id(res).Values(Dict{})
+
+		g.For(
+			List(
+				Id("_"),
+				Id(e.VarName()),
+			).Op(":=").Range().Id(VarName(e.PluralName())),
+		).BlockFunc(func(g2 *Group) {
+
+			g2.Id("resolvers").Op("=").Append(
+				Id("resolvers"),
+				Op("&").Id(GraphqlResolverForEntity(e)).Values(Dict{
+					Id("Repo"): Id("r").Dot("Repo"),
+					Id("Data"): Id(e.VarName()),
+				}),
+			)
+		})
+
+		ObserveDuration(FindListByAttributeQueryHistogramName(e, a), g)
+
+		g.Return(
+			Op("&").Id("resolvers"),
+			Nil(),
+		)
+	}, f)
+}
+
+// AddFinderByRelationQueryResolverFun defines a resolver function for the given
+// relation.
+func AddFinderByRelationQueryResolverFun(e *Entity, r *Relation, f *File) {
+	fun := GraphqlFinderQueryFromRelation(e, r)
+	res := GraphqlResolverResult(fun)
+
+	ResolverFun(fun, func(g *Group) {
+
+		TimeNow(g)
+
+		g.List(
+			Id(VarName(e.PluralName())),
+			Err(),
+		).Op(":=").Id("r").Dot("Repo").Dot(fmt.Sprintf("Find%sBy%s", e.PluralName(), r.Alias())).Call(
+			Id("ctx"),
+			CastRelationFromGraphql(
+				Id("args").Dot(r.Alias()),
+				r,
+			),
+			Id("args").Dot("Filter").Dot("Filter").Call(),
+			Id(EntitySortOrdersFunName(e)).Call(Id("args").Dot("OrderBy")),
+			Id("args").Dot("Limit"),
+			Id("args").Dot("Offset"),
+		)
+
+		MaybeReturnWrappedErrorAndIncrementCounter(
+			fmt.Sprintf("Error finding %s by %s", e.Name, r.Alias()),
+			FindByRelationQueryErrorCounterName(e, r),
+			g,
+		)
+
+		g.Id("resolvers").Op(":=").Id(res).Values(Dict{})
+
+		g.For(
+			List(
+				Id("_"),
+				Id(e.VarName()),
+			).Op(":=").Range().Id(VarName(e.PluralName())),
+		).BlockFunc(func(g2 *Group) {
+
+			g2.Id("resolvers").Op("=").Append(
+				Id("resolvers"),
+				Op("&").Id(GraphqlResolverForEntity(e)).Values(Dict{
+					Id("Repo"): Id("r").Dot("Repo"),
+					Id("Data"): Id(e.VarName()),
+				}),
+			)
+		})
+
+		ObserveDuration(FindByRelationQueryHistogramName(e, r), g)
+
+		g.Return(
+			Op("&").Id("resolvers"),
+			Nil(),
+		)
+	}, f)
+}
+
+// AddPageInfoResolver builds the resolver for the type that describes
+// a page of results
+func AddPageInfoResolver(f *File) {
+	t := GraphqlPageInfoType()
+	resolver := GraphqlResolverForType(t.Name)
+
+	f.Type().Id(resolver).Struct(
+		Id("Repo").Id("Repository"),
+		Id("Data").Op("*").Id(t.Name),
+	)
+
+	for _, field := range t.Fields {
+		f.Func().Parens(Id("r").Op("*").Id(resolver)).Id(strings.Title(field.Name)).Params(
+			Id("ctx").Qual("context", "Context"),
+		).Add(GraphqlResolverDataTypeFromGraphqlField(field)).Block(
+			Return(Id("r").Dot("Data").Dot(strings.Title(field.Name))),
+		)
+	}
+}
+
+// AddConnectionResolvers builds the resolvers for the types that hold a
+// page of instances of the given entity
+func AddConnectionResolvers(e *Entity, f *File) {
+	edge := EntityEdgeName(e)
+	connection := EntityConnectionName(e)
+
+	f.Type().Id(GraphqlResolverForType(edge)).Struct(
+		Id("Repo").Id("Repository"),
+		Id("Data").Op("*").Id(edge),
+	)
+
+	f.Func().Parens(Id("r").Op("*").Id(GraphqlResolverForType(edge))).Id("Cursor").Params(
+		Id("ctx").Qual("context", "Context"),
+	).String().Block(
+		Return(Id("r").Dot("Data").Dot("Cursor")),
+	)
+
+	f.Func().Parens(Id("r").Op("*").Id(GraphqlResolverForType(edge))).Id("Node").Params(
+		Id("ctx").Qual("context", "Context"),
+	).Op("*").Id(GraphqlResolverForEntity(e)).Block(
+		Return(Op("&").Id(GraphqlResolverForEntity(e)).Values(Dict{
+			Id("Repo"): Id("r").Dot("Repo"),
+			Id("Data"): Id("r").Dot("Data").Dot("Node"),
+		})),
+	)
+
+	f.Type().Id(GraphqlResolverForType(connection)).Struct(
+		Id("Repo").Id("Repository"),
+		Id("Data").Op("*").Id(connection),
+	)
+
+	f.Func().Parens(Id("r").Op("*").Id(GraphqlResolverForType(connection))).Id("Edges").Params(
+		Id("ctx").Qual("context", "Context"),
+	).Index().Op("*").Id(GraphqlResolverForType(edge)).BlockFunc(func(g *Group) {
+		g.Id("resolvers").Op(":=").Index().Op("*").Id(GraphqlResolverForType(edge)).Values()
+		g.For(List(Id("_"), Id("edge")).Op(":=").Range().Id("r").Dot("Data").Dot("Edges")).Block(
+			Id("resolvers").Op("=").Append(
+				Id("resolvers"),
+				Op("&").Id(GraphqlResolverForType(edge)).Values(Dict{
+					Id("Repo"): Id("r").Dot("Repo"),
+					Id("Data"): Id("edge"),
+				}),
+			),
+		)
+		g.Return(Id("resolvers"))
+	})
+
+	f.Func().Parens(Id("r").Op("*").Id(GraphqlResolverForType(connection))).Id("PageInfo").Params(
+		Id("ctx").Qual("context", "Context"),
+	).Op("*").Id(GraphqlResolverForType("PageInfo")).Block(
+		Return(Op("&").Id(GraphqlResolverForType("PageInfo")).Values(Dict{
+			Id("Repo"): Id("r").Dot("Repo"),
+			Id("Data"): Id("r").Dot("Data").Dot("PageInfo"),
+		})),
+	)
+}
+
+// AddConnectionForAllQueryResolverFun defines a resolver function that
+// finds a page of instances of the given entity
+func AddConnectionForAllQueryResolverFun(e *Entity, f *File) {
+	fun := GraphqlConnectionQueryForAll(e)
+	res := GraphqlResolverResult(fun)
+
+	ResolverFun(fun, func(g *Group) {
+
+		TimeNow(g)
+
+		g.List(
+			Id("connection"),
+			Err(),
+		).Op(":=").Id("r").Dot("Repo").Dot(FindAllConnectionFunName(e)).Call(
+			Id("ctx"),
+			Id("args").Dot("Filter").Dot("Filter").Call(),
+			Id(EntitySortOrdersFunName(e)).Call(Id("args").Dot("OrderBy")),
+			PageFromArgs(),
+		)
+
+		MaybeReturnWrappedErrorAndIncrementCounter(
+			fmt.Sprintf("Error finding page of %s", e.PluralName()),
+			FindAllQueryErrorCounterName(e),
+			g,
+		)
+
+		ObserveDuration(FindAllQueryHistogramName(e), g)
+
+		g.Return(
+			Op("&").Id(res).Values(Dict{
+				Id("Repo"): Id("r").Dot("Repo"),
+				Id("Data"): Id("connection"),
+			}),
+			Nil(),
+		)
+	}, f)
+}
+
+// AddConnectionByRelationQueryResolverFun defines a resolver function
+// that finds a page of instances of the given entity by relation
+func AddConnectionByRelationQueryResolverFun(e *Entity, r *Relation, f *File) {
+	fun := GraphqlConnectionQueryFromRelation(e, r)
 	res := GraphqlResolverResult(fun)
+
 	ResolverFun(fun, func(g *Group) {
 
 		TimeNow(g)
 
-		g.Id("id").Op(":=").Add(
-			CastFromGraphqlType(Id("args").Dot("Id"), &GraphqlField{
-				DataType: "ID",
-			}),
+		g.List(
+			Id("connection"),
+			Err(),
+		).Op(":=").Id("r").Dot("Repo").Dot(FindEntityByRelationConnectionFunName(e, r)).Call(
+			Id("ctx"),
+			CastRelationFromGraphql(
+				Id("args").Dot(r.Alias()),
+				r,
+			),
+			Id("args").Dot("Filter").Dot("Filter").Call(),
+			Id(EntitySortOrdersFunName(e)).Call(Id("args").Dot("OrderBy")),
+			PageFromArgs(),
 		)
 
-		MaybeAddHook(e, "delete", "before", g)
-		AddEntityRepoCall(e, "delete", g)
-		MaybeAddHook(e, "delete", "after", g)
+		MaybeReturnWrappedErrorAndIncrementCounter(
+			fmt.Sprintf("Error finding page of %s by %s", e.PluralName(), r.Alias()),
+			FindByRelationQueryErrorCounterName(e, r),
+			g,
+		)
 
-		ObserveDuration(DeleteMutationHistogramName(e), g)
+		ObserveDuration(FindByRelationQueryHistogramName(e, r), g)
 
 		g.Return(
-			Op("&").Add(Id(res)).Values(Dict{
-				Id("Db"):   Id("r").Dot("Db"),
-				Id("Data"): Id(e.VarName()),
+			Op("&").Id(res).Values(Dict{
+				Id("Repo"): Id("r").Dot("Repo"),
+				Id("Data"): Id("connection"),
 			}),
 			Nil(),
 		)
 	}, f)
-
 }
 
-// AddEntityRepoCall adds the code that calls the given repo function.
-// This function infers the right assignments and repo function to call
-// according to conventions
-func AddEntityRepoCall(e *Entity, mutation string, g *Group) {
-
-	// use the right assignment, depending on whether or not
-	// a hook or a generator was previously called
-	op := ":="
-	if HasHook(e, mutation, "before") || (e.HasGenerators() && (mutation == "create" || mutation == "update")) {
-		op = "="
-	}
+// AddConnectionByAttributeQueryResolverFun defines a resolver function
+// that finds a page of instances of the given entity by an indexed
+// attribute that is not unique
+func AddConnectionByAttributeQueryResolverFun(e *Entity, a *Attribute, f *File) {
+	fun := GraphqlConnectionQueryFromAttribute(e, a)
+	res := GraphqlResolverResult(fun)
 
-	varName := e.VarName()
-	if mutation == "delete" {
-		varName = "id"
-	}
+	ResolverFun(fun, func(g *Group) {
 
-	repoFun := EntityRepoFun(e, mutation)
+		TimeNow(g)
 
-	g.List(
-		Id(e.VarName()),
-		Err(),
-	).Op(op).Id(repoFun).Call(
-		Id("r").Dot("Db"),
-		Id(varName),
-	)
+		value := Id("args").Dot(strings.Title(AttributeGraphqlFieldName(a)))
+		g.List(
+			Id("connection"),
+			Err(),
+		).Op(":=").Id("r").Dot("Repo").Dot(FindEntitiesByAttributeConnectionFunName(e, a)).Call(
+			Id("ctx"),
+			CastAttributeFromGraphql(value, e, a),
+			Id("args").Dot("Filter").Dot("Filter").Call(),
+			Id(EntitySortOrdersFunName(e)).Call(Id("args").Dot("OrderBy")),
+			PageFromArgs(),
+		)
 
-	MaybeReturnWrappedErrorAndIncrementCounter(
-		fmt.Sprintf("Error calling function %s", repoFun),
-		CreateMutationErrorCounterName(e),
-		g,
-	)
+		MaybeReturnWrappedErrorAndIncrementCounter(
+			fmt.Sprintf("Error finding page of %s by %s", e.PluralName(), a.Name),
+			FindListByAttributeQueryErrorCounterName(e, a),
+			g,
+		)
 
-}
+		ObserveDuration(FindListByAttributeQueryHistogramName(e, a), g)
 
-// EntityRepoFun returns the repo entity to call from the given entity
-// and mutation
-func EntityRepoFun(e *Entity, mutation string) string {
-	return fmt.Sprintf(
-		"%s%s",
-		strcase.ToCamel(mutation),
-		e.Name,
-	)
+		g.Return(
+			Op("&").Id(res).Values(Dict{
+				Id("Repo"): Id("r").Dot("Repo"),
+				Id("Data"): Id("connection"),
+			}),
+			Nil(),
+		)
+	}, f)
 }
 
-// AddFinderForAllQueryResolverFun defines a resolver function for the given
-// entity.
-func AddFinderForAllQueryResolverFun(e *Entity, f *File) {
-	fun := GraphqlFinderQueryForAll(e)
+// AddSearchQueryResolverFun defines a resolver function that finds
+// instances of the given entity by full text search
+func AddSearchQueryResolverFun(e *Entity, f *File) {
+	fun := GraphqlSearchQueryFromEntity(e)
 	res := GraphqlResolverResult(fun)
 
 	ResolverFun(fun, func(g *Group) {
@@ -388,15 +1755,16 @@ func AddFinderForAllQueryResolverFun(e *Entity, f *File) {
 		g.List(
 			Id(VarName(e.PluralName())),
 			Err(),
-		).Op(":=").Id(fmt.Sprintf("FindAll%s", e.PluralName())).Call(
-			Id("r").Dot("Db"),
+		).Op(":=").Id("r").Dot("Repo").Dot(SearchFunName(e)).Call(
+			Id("ctx"),
+			Id("args").Dot("Query"),
 			Id("args").Dot("Limit"),
 			Id("args").Dot("Offset"),
 		)
 
 		MaybeReturnWrappedErrorAndIncrementCounter(
-			fmt.Sprintf("Error finding all %s ", e.PluralName()),
-			FindAllQueryErrorCounterName(e),
+			fmt.Sprintf("Error searching %s", e.PluralName()),
+			SearchQueryErrorCounterName(e),
 			g,
 		)
 
@@ -412,108 +1780,188 @@ func AddFinderForAllQueryResolverFun(e *Entity, f *File) {
 			g2.Id("resolvers").Op("=").Append(
 				Id("resolvers"),
 				Op("&").Id(GraphqlResolverForEntity(e)).Values(Dict{
-					Id("Db"):   Id("r").Dot("Db"),
+					Id("Repo"): Id("r").Dot("Repo"),
 					Id("Data"): Id(e.VarName()),
 				}),
 			)
 		})
 
-		ObserveDuration(FindAllQueryHistogramName(e), g)
+		ObserveDuration(SearchQueryHistogramName(e), g)
 
 		g.Return(
 			Op("&").Id("resolvers"),
 			Nil(),
 		)
-
 	}, f)
 }
 
-// AddFinderByAttributeQueryResolverFun defines a resolver function for the given
-// indexed and
-// unique attribute of the given entity
-func AddFinderByAttributeQueryResolverFun(e *Entity, a *Attribute, f *File) {
-	fun := GraphqlFinderQueryFromAttribute(e, a)
-	res := GraphqlResolverResult(fun)
+// AddAggregateResolvers builds the resolvers for the types that hold
+// the aggregates of a group of instances of the given entity
+func AddAggregateResolvers(e *Entity, f *File) {
+	aggregate := GraphqlResolverForType(EntityAggregateName(e))
+	group := GraphqlResolverForType(EntityGroupName(e))
+	values := GraphqlResolverForType(EntityAggregateValuesName(e))
 
-	ResolverFun(fun, func(g *Group) {
+	f.Type().Id(group).Struct(
+		Id("Repo").Id("Repository"),
+		Id("Data").Op("*").Id(EntityGroupName(e)),
+	)
+
+	for _, gf := range EntityGroupFields(e) {
+		field := GraphqlGroupField(gf)
+		value := CastToGraphqlType(Op("*").Id("r").Dot("Data").Dot(gf.Field), field)
+		if gf.Entity != nil {
+			value = CastToGraphqlID(Op("*").Id("r").Dot("Data").Dot(gf.Field), gf.Entity)
+		}
+
+		f.Func().Parens(Id("r").Op("*").Id(group)).Id(gf.Field).Params(
+			Id("ctx").Qual("context", "Context"),
+		).Add(GraphqlResolverDataTypeFromGraphqlField(field)).Block(
+			If(Id("r").Dot("Data").Dot(gf.Field).Op("==").Nil()).Block(
+				Return(Nil()),
+			),
+			Id("v").Op(":=").Add(value),
+			Return(Op("&").Id("v")),
+		)
+	}
+
+	if len(AggregateAttributes(e)) > 0 {
+		f.Type().Id(values).Struct(
+			Id("Repo").Id("Repository"),
+			Id("Data").Op("*").Id(EntityAggregateValuesName(e)),
+		)
+
+		for _, a := range AggregateAttributes(e) {
+			f.Func().Parens(Id("r").Op("*").Id(values)).Id(a.Name).Params(
+				Id("ctx").Qual("context", "Context"),
+			).Op("*").Float64().Block(
+				Return(Id("r").Dot("Data").Dot(a.Name)),
+			)
+		}
+	}
+
+	f.Type().Id(aggregate).Struct(
+		Id("Repo").Id("Repository"),
+		Id("Data").Op("*").Id(EntityAggregateName(e)),
+	)
+
+	f.Func().Parens(Id("r").Op("*").Id(aggregate)).Id("Group").Params(
+		Id("ctx").Qual("context", "Context"),
+	).Op("*").Id(group).Block(
+		Return(Op("&").Id(group).Values(Dict{
+			Id("Repo"): Id("r").Dot("Repo"),
+			Id("Data"): Id("r").Dot("Data").Dot("Group"),
+		})),
+	)
+
+	f.Func().Parens(Id("r").Op("*").Id(aggregate)).Id("Count").Params(
+		Id("ctx").Qual("context", "Context"),
+	).Int32().Block(
+		Return(Int32().Call(Id("r").Dot("Data").Dot("Count"))),
+	)
+
+	if len(AggregateAttributes(e)) > 0 {
+		for _, fun := range AggregateFunctions() {
+			f.Func().Parens(Id("r").Op("*").Id(aggregate)).Id(fun[0]).Params(
+				Id("ctx").Qual("context", "Context"),
+			).Op("*").Id(values).Block(
+				Return(Op("&").Id(values).Values(Dict{
+					Id("Repo"): Id("r").Dot("Repo"),
+					Id("Data"): Id("r").Dot("Data").Dot(fun[0]),
+				})),
+			)
+		}
+	}
+}
+
+// AddCountQueryResolverFun defines a resolver function that counts the
+// instances of the given entity that match a filter
+func AddCountQueryResolverFun(e *Entity, f *File) {
+	fun := GraphqlCountQueryFromEntity(e)
+
+	f.Func().Parens(Id("r").Op("*").Id("Resolver")).Id(strings.Title(fun.Name)).Params(
+		Id("ctx").Qual("context", "Context"),
+		GraphqlResolverArgs(fun),
+	).Parens(List(
+		Int32(),
+		Error(),
+	)).BlockFunc(func(g *Group) {
 
 		TimeNow(g)
 
-		value := Id("args").Dot(strings.Title(AttributeGraphqlFieldName(a)))
 		g.List(
-			Id(e.VarName()),
+			Id("count"),
 			Err(),
-		).Op(":=").Id(fmt.Sprintf("Find%sBy%s", e.Name, a.Name)).Call(
-			Id("r").Dot("Db"),
-			CastFromGraphqlType(value, GraphqlFieldFromAttribute(a)),
+		).Op(":=").Id("r").Dot("Repo").Dot(CountFunName(e)).Call(
+			Id("ctx"),
+			Id("args").Dot("Filter").Dot("Filter").Call(),
 		)
 
-		MaybeReturnWrappedErrorAndIncrementCounter(
-			fmt.Sprintf("Error finding %s by %s", e.Name, a.Name),
-			FindByAttributeQueryErrorCounterName(e, a),
-			g,
+		g.If(
+			Err().Op("!=").Nil(),
+		).Block(
+			Id(CountQueryErrorCounterName(e)).Dot("Inc").Call(),
+			Return(
+				Lit(0),
+				Id("GraphqlError").Call(
+					Err(),
+					Id("CodeInternal"),
+					Lit(fmt.Sprintf("Error counting %s", e.PluralName())),
+				),
+			),
 		)
 
-		ObserveDuration(FindByAttributeQueryHistogramName(e, a), g)
+		ObserveDuration(CountQueryHistogramName(e), g)
 
 		g.Return(
-			Op("&").Add(Id(res)).Values(Dict{
-				Id("Db"):   Id("r").Dot("Db"),
-				Id("Data"): Id(e.VarName()),
-			}),
+			Int32().Call(Id("count")),
 			Nil(),
 		)
-	}, f)
+	})
 }
 
-// AddFinderByRelationQueryResolverFun defines a resolver function for the given
-// relation.
-func AddFinderByRelationQueryResolverFun(e *Entity, r *Relation, f *File) {
-	fun := GraphqlFinderQueryFromRelation(e, r)
-	res := GraphqlResolverResult(fun)
+// AddAggregateQueryResolverFun defines a resolver function that
+// computes aggregates of the instances of the given entity
+func AddAggregateQueryResolverFun(e *Entity, f *File) {
+	fun := GraphqlAggregateQueryFromEntity(e)
+	res := GraphqlResolverForType(EntityAggregateName(e))
 
 	ResolverFun(fun, func(g *Group) {
 
 		TimeNow(g)
 
+		g.Id("groupBy").Op(":=").Index().String().Values()
+		g.If(Id("args").Dot("GroupBy").Op("!=").Nil()).Block(
+			Id("groupBy").Op("=").Op("*").Id("args").Dot("GroupBy"),
+		)
+
 		g.List(
-			Id(VarName(e.PluralName())),
+			Id("aggregates"),
 			Err(),
-		).Op(":=").Id(fmt.Sprintf("Find%sBy%s", e.PluralName(), r.Alias())).Call(
-			Id("r").Dot("Db"),
-			CastFromGraphqlType(
-				Id("args").Dot(r.Alias()),
-				GraphqlInputFieldFromRelation(r),
-			),
-			Id("args").Dot("Limit"),
-			Id("args").Dot("Offset"),
+		).Op(":=").Id("r").Dot("Repo").Dot(AggregateFunName(e)).Call(
+			Id("ctx"),
+			Id("args").Dot("Filter").Dot("Filter").Call(),
+			Id("groupBy"),
 		)
 
 		MaybeReturnWrappedErrorAndIncrementCounter(
-			fmt.Sprintf("Error finding %s by %s", e.Name, r.Alias()),
-			FindByRelationQueryErrorCounterName(e, r),
+			fmt.Sprintf("Error aggregating %s", e.PluralName()),
+			AggregateQueryErrorCounterName(e),
 			g,
 		)
 
-		g.Id("resolvers").Op(":=").Id(res).Values(Dict{})
-
-		g.For(
-			List(
-				Id("_"),
-				Id(e.VarName()),
-			).Op(":=").Range().Id(VarName(e.PluralName())),
-		).BlockFunc(func(g2 *Group) {
-
-			g2.Id("resolvers").Op("=").Append(
+		g.Id("resolvers").Op(":=").Index().Op("*").Id(res).Values()
+		g.For(List(Id("_"), Id("a")).Op(":=").Range().Id("aggregates")).Block(
+			Id("resolvers").Op("=").Append(
 				Id("resolvers"),
-				Op("&").Id(GraphqlResolverForEntity(e)).Values(Dict{
-					Id("Db"):   Id("r").Dot("Db"),
-					Id("Data"): Id(e.VarName()),
+				Op("&").Id(res).Values(Dict{
+					Id("Repo"): Id("r").Dot("Repo"),
+					Id("Data"): Id("a"),
 				}),
-			)
-		})
+			),
+		)
 
-		ObserveDuration(FindByRelationQueryHistogramName(e, r), g)
+		ObserveDuration(AggregateQueryHistogramName(e), g)
 
 		g.Return(
 			Op("&").Id("resolvers"),
@@ -522,12 +1970,26 @@ func AddFinderByRelationQueryResolverFun(e *Entity, r *Relation, f *File) {
 	}, f)
 }
 
+// PageFromArgs returns the code that builds the page selected by the
+// resolver arguments in scope
+func PageFromArgs() *Statement {
+	return Op("&").Id("Page").Values(DictFunc(func(d Dict) {
+		for _, field := range GraphqlPageFields() {
+			d[Id(field.Name)] = Id("args").Dot(field.Name)
+		}
+	}))
+}
+
 func ResolverFun(fun *GraphqlFun, blockFun func(*Group), f *File) {
 	res := GraphqlResolverResult(fun)
-	f.Func().Parens(Id("r").Op("*").Id("Resolver")).Id(strings.Title(fun.Name)).Params(
-		Id("ctx").Qual("context", "Context"),
-		GraphqlResolverArgs(fun),
-	).Parens(List(
+	f.Func().Parens(Id("r").Op("*").Id("Resolver")).Id(strings.Title(fun.Name)).ParamsFunc(func(g *Group) {
+		g.Id("ctx").Qual("context", "Context")
+
+		// resolvers of fields without arguments take no args struct
+		if len(fun.Args) > 0 {
+			g.Add(GraphqlResolverArgs(fun))
+		}
+	}).Parens(List(
 		Op("*").Id(res),
 		Error(),
 	)).BlockFunc(blockFun)
@@ -579,6 +2041,10 @@ func GraphqlResolverArgs(fun *GraphqlFun) *Statement {
 // GraphqlResolverDataTypeFromAttribute returns the Golang data type for
 // the given entity attribute
 func GraphqlResolverDataTypeFromAttribute(a *Attribute) *Statement {
+	if a.Enum() != nil {
+		return Id(a.Enum().Name)
+	}
+
 	return GraphqlResolverDataTypeFromDataType(a.Type)
 }
 
@@ -590,9 +2056,45 @@ func GraphqlResolverDataTypeFromRelation(r *Relation) *Statement {
 }
 
 // GraphqlResolverDataTypeFromGraphqlField returns the Golang data type
-// for the given Grapqhl type
+// for the given Grapqhl type. Input objects are passed as their
+// generated structs, or pointers to them when nullable or in lists, and
+// lists as slices. Enums of the model are passed as their Golang types.
+// Nullable values and lists are pointers
 func GraphqlResolverDataTypeFromGraphqlField(a *GraphqlField) *Statement {
-	return GraphqlResolverDataTypeFromDataType(a.DataType)
+	t := GraphqlResolverDataTypeFromDataType(a.DataType)
+	if a.Enum {
+		t = Id(a.DataType)
+	}
+
+	if a.Input {
+		t = Id(GraphqlInputStructName(a.DataType))
+		if !a.Required || a.Many {
+			t = Op("*").Add(t)
+		}
+	} else if !a.Required {
+		t = Op("*").Add(t)
+	}
+
+	if a.Many {
+		t = Index().Add(t)
+
+		if !a.ListRequired {
+			t = Op("*").Add(t)
+		}
+	}
+
+	return t
+}
+
+// GraphqlInputStructName returns the name of the Golang struct for the
+// given Graphql input type. By convention, all of them end in Input, so
+// that they do not clash with the types used by the repository
+func GraphqlInputStructName(input string) string {
+	if strings.HasSuffix(input, "Input") {
+		return input
+	}
+
+	return fmt.Sprintf("%sInput", input)
 }
 
 // GraphqlResolverDataTypeFromDataType returns the Golang data type
@@ -608,6 +2110,9 @@ func GraphqlResolverDataTypeFromDataType(d string) *Statement {
 	case "Int":
 		return Int32()
 
+	case "Float":
+		return Float64()
+
 	case "Boolean":
 		return Bool()
 	default:
@@ -645,6 +2150,46 @@ func CastFromGraphqlType(s *Statement, f *GraphqlField) *Statement {
 	}
 }
 
+// CastToGraphqlID transforms the given id of an instance of the given
+// entity into a Graphql ID, which is a global ID if the entity uses
+// them
+func CastToGraphqlID(s *Statement, e *Entity) *Statement {
+	if e != nil && e.UsesGlobalIDs() {
+		return Id("GlobalID").Call(Lit(e.Name), s)
+	}
+
+	return Qual("github.com/graph-gophers/graphql-go", "ID").Call(s)
+}
+
+// CastFromGraphqlID transforms the given Graphql ID of an instance of
+// the given entity into a plain Golang string, decoding it if the
+// entity uses global IDs
+func CastFromGraphqlID(s *Statement, e *Entity) *Statement {
+	if e != nil && e.UsesGlobalIDs() {
+		return Id("LocalID").Call(Lit(e.Name), s)
+	}
+
+	return String().Call(s)
+}
+
+// CastAttributeFromGraphql transforms the given Graphql value of an
+// attribute of the given entity into plain Golang types, decoding
+// global IDs
+func CastAttributeFromGraphql(s *Statement, e *Entity, a *Attribute) *Statement {
+	if a.Name == "ID" {
+		return CastFromGraphqlID(s, e)
+	}
+
+	return CastFromGraphqlType(s, GraphqlFieldFromAttribute(a))
+}
+
+// CastRelationFromGraphql transforms the given Graphql ID of the
+// instance pointed by the given relation into a plain Golang string,
+// decoding global IDs
+func CastRelationFromGraphql(s *Statement, r *Relation) *Statement {
+	return CastFromGraphqlID(s, r.target)
+}
+
 // MaybeReturnWrappedError produces the code that returns immediately
 // and wraps the error with a message
 func MaybeReturnWrappedError(msg string, g *Group) {
@@ -653,8 +2198,9 @@ func MaybeReturnWrappedError(msg string, g *Group) {
 	).Block(
 		Return(
 			Nil(),
-			Qual("github.com/pkg/errors", "Wrap").Call(
+			Id("GraphqlError").Call(
 				Err(),
+				Id("CodeInternal"),
 				Lit(msg),
 			),
 		),
@@ -670,14 +2216,23 @@ func TimeNow(g *Group) {
 // and wraps the error with a message. It also increments the counter
 // specified by the given name
 func MaybeReturnWrappedErrorAndIncrementCounter(msg string, counter string, g *Group) {
+	MaybeReturnCodedErrorAndIncrementCounter(msg, "CodeInternal", counter, g)
+}
+
+// MaybeReturnCodedErrorAndIncrementCounter produces the code that returns
+// immediately with a GraphQL error. Errors already classified by the
+// repository keep their code, others get the given one. It also
+// increments the counter specified by the given name
+func MaybeReturnCodedErrorAndIncrementCounter(msg string, code string, counter string, g *Group) {
 	g.If(
 		Err().Op("!=").Nil(),
 	).Block(
 		Id(counter).Dot("Inc").Call(),
 		Return(
 			Nil(),
-			Qual("github.com/pkg/errors", "Wrap").Call(
+			Id("GraphqlError").Call(
 				Err(),
+				Id(code),
 				Lit(msg),
 			),
 		),
@@ -695,22 +2250,34 @@ func ObserveDuration(histogram string, g *Group) {
 // MaybeAddHooks adds the code required to run after create hooks
 // for the given entity
 func MaybeAddHook(e *Entity, name string, lifecycle string, g *Group) {
-	if HasHook(e, name, lifecycle) {
-
-		hookFun := HookFunctionName(e, name, lifecycle)
-		g.Err().Op(HookErrorOp(lifecycle)).Id(hookFun).Call(
-			Id("r").Dot("Db"),
-			Id(HookArgumentVarName(e, name, lifecycle)),
-		)
+	op := MutationOperation(name)
+	if HasHook(e, op, lifecycle) {
+
+		hookFun := HookFunctionName(e, op, lifecycle)
+		g.Err().Op("=").Id(hookFun).CallFunc(func(g2 *Group) {
+			g2.Id("ctx")
+			g2.Id("tx")
+			for _, v := range HookArgumentVarNames(e, op, lifecycle) {
+				g2.Id(v)
+			}
+		})
 
-		MaybeReturnWrappedErrorAndIncrementCounter(
+		MaybeReturnCodedErrorAndIncrementCounter(
 			fmt.Sprintf("Error calling function %s", hookFun),
-			CreateMutationErrorCounterName(e),
+			"CodeHookRejected",
+			MutationErrorCounterName(e, name),
 			g,
 		)
 	}
 }
 
+// MutationOperation returns the operation of the given mutation. Bulk
+// mutations, such as createMany, share the operation of the single
+// instance ones, so they run the same hooks and generators
+func MutationOperation(mutation string) string {
+	return strings.TrimSuffix(mutation, "Many")
+}
+
 // HasHook returns whether or not the given entity has the given hook
 func HasHook(e *Entity, name string, lifecycle string) bool {
 	if hooks, ok := e.Hooks[name]; ok {
@@ -724,27 +2291,25 @@ func HasHook(e *Entity, name string, lifecycle string) bool {
 	return false
 }
 
-// HookErrorOp returns the appropriate kind of assignement for the error
-// variable returned by the hook
-func HookErrorOp(lifecycle string) string {
-	if lifecycle == "before" {
-		return ":="
-	} else {
-		return "="
-	}
-}
-
-// HookArgumentVarName returns the name of the variable to be passed to
-// the hook. In the case of create and update function, we have a fully
-// populated entity struct, however, when deleting, we simply have a
-// string id
-func HookArgumentVarName(e *Entity, name string, lifecycle string) string {
+// HookArgumentVarNames returns the names of the variables to be passed
+// to the hook. In the case of create and update function, we have a
+// fully populated entity struct, however, before deleting, we simply
+// have a string id. After deleting, hooks receive the deleted entity.
+// Patch hooks receive both the stored and the patched entity
+func HookArgumentVarNames(e *Entity, name string, lifecycle string) []string {
 	switch name {
 	case "delete":
-		return "id"
+		if lifecycle == "after" {
+			return []string{e.VarName()}
+		}
+
+		return []string{"id"}
+
+	case "patch":
+		return []string{"stored", "patched"}
 
 	default:
-		return e.VarName()
+		return []string{e.VarName()}
 
 	}
 }
@@ -779,21 +2344,17 @@ func MaybeAddGenerators(e *Entity, mutation string, g *Group) {
 // AddGeneratorForAttribute adds the generator code for the given
 // attribute in the context of the given mutation
 func AddGeneratorForAttribute(e *Entity, a *Attribute, mutation string, g *Group) {
-	funName := fmt.Sprintf(
-		"Generate%s%sOn%s",
-		e.Name,
-		a.Name,
-		strcase.ToCamel(mutation),
-	)
+	funName := GeneratorFunName(e, a.Name, MutationOperation(mutation))
 
 	g.List(Id(a.VarName()), Err()).Op(":=").Id(funName).Call(
-		Id("r").Dot("Db"),
+		Id("ctx"),
+		Id("tx"),
 		Id(e.VarName()),
 	)
 
 	MaybeReturnWrappedErrorAndIncrementCounter(
 		fmt.Sprintf("Error calling function %s", funName),
-		CreateMutationErrorCounterName(e),
+		MutationErrorCounterName(e, mutation),
 		g,
 	)
 
@@ -803,23 +2364,68 @@ func AddGeneratorForAttribute(e *Entity, a *Attribute, mutation string, g *Group
 // AddGeneratorForRelation adds the generator code for the given
 // relation in the context of the given mutation
 func AddGeneratorForRelation(e *Entity, r *Relation, mutation string, g *Group) {
-	funName := fmt.Sprintf(
+	funName := GeneratorFunName(e, r.Alias(), MutationOperation(mutation))
+
+	g.List(Id(r.VarName()), Err()).Op(":=").Id(funName).Call(
+		Id("ctx"),
+		Id("tx"),
+		Id(e.VarName()),
+	)
+
+	MaybeReturnWrappedErrorAndIncrementCounter(
+		fmt.Sprintf("Error calling function %s", funName),
+		MutationErrorCounterName(e, mutation),
+		g,
+	)
+
+	g.Id(e.VarName()).Dot(r.Alias()).Op("=").Id(r.VarName())
+}
+
+// GeneratorFunName returns the name of the user defined function that
+// generates the value of the given field of an entity, in the context
+// of the given mutation
+func GeneratorFunName(e *Entity, field string, mutation string) string {
+	return fmt.Sprintf(
 		"Generate%s%sOn%s",
 		e.Name,
-		r.Alias(),
+		field,
 		strcase.ToCamel(mutation),
 	)
+}
 
-	g.List(Id(r.VarName()), Err()).Op(":=").Id(funName).Call(
-		Id("r").Dot("Db"),
-		Id(e.VarName()),
+// MaybeAddPatchGenerators adds the update generators of the given
+// entity to a patch. Generated values are set in the patched entity in
+// scope, which the stored patch is built from
+func MaybeAddPatchGenerators(e *Entity, g *Group) {
+	for _, a := range PatchAttributes(e) {
+		if a.HasModifier("generated") {
+			AddPatchGenerator(e, a.Name, a.VarName(), g)
+		}
+	}
+
+	for _, r := range PatchRelations(e) {
+		if r.HasModifier("generated") {
+			AddPatchGenerator(e, r.Alias(), r.VarName(), g)
+		}
+	}
+}
+
+// AddPatchGenerator adds the code that calls the update generator of
+// the given field, and sets the generated value in the patched entity
+func AddPatchGenerator(e *Entity, field string, varName string, g *Group) {
+	funName := GeneratorFunName(e, field, "update")
+
+	g.List(Id(varName), Err()).Op(":=").Id(funName).Call(
+		Id("ctx"),
+		Id("tx"),
+		Id("patched"),
 	)
 
 	MaybeReturnWrappedErrorAndIncrementCounter(
 		fmt.Sprintf("Error calling function %s", funName),
-		CreateMutationErrorCounterName(e),
+		MutationErrorCounterName(e, "patch"),
 		g,
 	)
 
-	g.Id(e.VarName()).Dot(r.Alias()).Op("=").Id(r.VarName())
+	g.Id("patched").Dot(field).Op("=").Id(varName)
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 
@@ -35,7 +36,7 @@ func BuildSchema(m *Model) string {
 	s := &GraphqlSchema{}
 
 	for _, t := range m.Types {
-		if t.Type == "String" && len(t.Values) > 0 {
+		if t.IsEnum() {
 			s.Enums = append(s.Enums, GraphqlEnumFromUDType(t))
 		}
 
@@ -45,35 +46,115 @@ func BuildSchema(m *Model) string {
 
 	}
 
+	for _, sf := range ScalarFilters() {
+		s.Inputs = append(s.Inputs, GraphqlScalarFilterInput(sf))
+	}
+
+	for _, sf := range EnumFilters(m.Types) {
+		s.Inputs = append(s.Inputs, GraphqlScalarFilterInput(sf))
+	}
+
+	s.Enums = append(s.Enums, GraphqlSortDirectionEnum())
+
+	if m.SupportsCursorPagination() {
+		s.Types = append(s.Types, GraphqlPageInfoType())
+	}
+
+	if m.ImplementsNode() {
+		s.Interfaces = append(s.Interfaces, GraphqlNodeInterface())
+		s.Queries = append(s.Queries, GraphqlNodeQuery())
+		s.Queries = append(s.Queries, GraphqlNodesQuery())
+	}
+
 	for _, e := range m.Entities {
 		s.Types = append(s.Types, GraphqlSchemaTypeFromEntity(e))
 
 		if e.SupportsOperation("create") {
+			if HasCreateInput(e) {
+				s.Inputs = append(s.Inputs, GraphqlCreateInputFromEntity(e))
+			}
 			s.Mutations = append(s.Mutations, GraphqlCreateMutationFromEntity(e))
+			s.Mutations = append(s.Mutations, GraphqlCreateManyMutationFromEntity(e))
 		}
 
 		if e.SupportsOperation("update") {
+			s.Inputs = append(s.Inputs, GraphqlUpdateInputFromEntity(e))
 			s.Mutations = append(s.Mutations, GraphqlUpdateMutationFromEntity(e))
+			s.Mutations = append(s.Mutations, GraphqlUpdateManyMutationFromEntity(e))
+
+			if SupportsPatch(e) {
+				s.Mutations = append(s.Mutations, GraphqlPatchMutationFromEntity(e))
+			}
 		}
 
 		if e.SupportsOperation("delete") {
 			s.Mutations = append(s.Mutations, GraphqlDeleteMutationFromEntity(e))
+			s.Mutations = append(s.Mutations, GraphqlDeleteManyMutationFromEntity(e))
+		}
+
+		if e.SupportsOperation("upsert") {
+			s.Mutations = append(s.Mutations, GraphqlUpsertMutationFromEntity(e))
+		}
 
+		for _, event := range SubscriptionEvents(e) {
+			s.Subscriptions = append(s.Subscriptions, GraphqlSubscriptionFromEntity(e, event))
 		}
 
 		if e.SupportsOperation("find") {
+			s.Inputs = append(s.Inputs, GraphqlFilterInputFromEntity(e))
+			s.Enums = append(s.Enums, GraphqlSortFieldEnumFromEntity(e))
+			s.Inputs = append(s.Inputs, GraphqlOrderInputFromEntity(e))
+
 			for _, a := range e.Attributes {
 				if a.HasModifier("indexed") && a.HasModifier("unique") {
 					s.Queries = append(s.Queries, GraphqlFinderQueryFromAttribute(e, a))
+				} else if a.HasModifier("indexed") {
+					if e.SupportsOffsetPagination() {
+						s.Queries = append(s.Queries, GraphqlListFinderQueryFromAttribute(e, a))
+					}
+
+					if e.SupportsCursorPagination() {
+						s.Queries = append(s.Queries, GraphqlConnectionQueryFromAttribute(e, a))
+					}
 				}
 			}
+			if e.SupportsCursorPagination() {
+				s.Types = append(s.Types, GraphqlEdgeTypeFromEntity(e))
+				s.Types = append(s.Types, GraphqlConnectionTypeFromEntity(e))
+			}
+
 			for _, r := range e.Relations {
 				if r.HasModifier("hasOne") || r.HasModifier("belongsTo") {
-					s.Queries = append(s.Queries, GraphqlFinderQueryFromRelation(e, r))
+					if e.SupportsOffsetPagination() {
+						s.Queries = append(s.Queries, GraphqlFinderQueryFromRelation(e, r))
+					}
+
+					if e.SupportsCursorPagination() {
+						s.Queries = append(s.Queries, GraphqlConnectionQueryFromRelation(e, r))
+					}
 				}
 			}
 
-			s.Queries = append(s.Queries, GraphqlFinderQueryForAll(e))
+			if e.SupportsOffsetPagination() {
+				s.Queries = append(s.Queries, GraphqlFinderQueryForAll(e))
+			}
+
+			if e.SupportsCursorPagination() {
+				s.Queries = append(s.Queries, GraphqlConnectionQueryForAll(e))
+			}
+
+			s.Enums = append(s.Enums, GraphqlGroupFieldEnumFromEntity(e))
+			s.Types = append(s.Types, GraphqlGroupTypeFromEntity(e))
+			if len(AggregateAttributes(e)) > 0 {
+				s.Types = append(s.Types, GraphqlAggregateValuesTypeFromEntity(e))
+			}
+			s.Types = append(s.Types, GraphqlAggregateTypeFromEntity(e))
+			if SupportsSearch(e) {
+				s.Queries = append(s.Queries, GraphqlSearchQueryFromEntity(e))
+			}
+
+			s.Queries = append(s.Queries, GraphqlCountQueryFromEntity(e))
+			s.Queries = append(s.Queries, GraphqlAggregateQueryFromEntity(e))
 
 		}
 	}
@@ -86,6 +167,11 @@ func BuildSchema(m *Model) string {
 func GraphqlSchemaTypeFromEntity(e *Entity) *GraphqlType {
 	t := &GraphqlType{
 		Name: e.Name,
+		Desc: e.Description,
+	}
+
+	if e.ImplementsNode() {
+		t.Interfaces = append(t.Interfaces, "Node")
 	}
 
 	for _, a := range e.Attributes {
@@ -100,7 +186,7 @@ func GraphqlSchemaTypeFromEntity(e *Entity) *GraphqlType {
 }
 
 // GraphqlCreateMutationFromEntity returns a mutation that creates
-// instances of the given entity
+// instances of the given entity, from a single input
 func GraphqlCreateMutationFromEntity(e *Entity) *GraphqlFun {
 	m := &GraphqlFun{
 		Name: GraphqlCreateMutationName(e),
@@ -111,8 +197,29 @@ func GraphqlCreateMutationFromEntity(e *Entity) *GraphqlFun {
 		},
 	}
 
+	if HasCreateInput(e) {
+		m.Args = append(m.Args, GraphqlInputField(GraphqlCreateInputName(e)))
+	}
+
+	return m
+}
+
+// HasCreateInput returns whether clients give any value to create
+// instances of the given entity. Entities that only hold an ID assigned
+// by the server have none
+func HasCreateInput(e *Entity) bool {
+	return len(GraphqlCreateArgsFromEntity(e, "create")) > 0
+}
+
+// GraphqlCreateArgsFromEntity returns the fields that hold the values
+// needed by the given mutation to create instances of the given entity.
+// The ID is left out when it is generated by the server
+func GraphqlCreateArgsFromEntity(e *Entity, mutation string) []*GraphqlField {
+	args := []*GraphqlField{}
 	for _, a := range e.Attributes {
-		m.Args = append(m.Args, GraphqlFieldFromAttribute(a))
+		if a.Name != "ID" || ClientSuppliesID(e, mutation) {
+			args = append(args, GraphqlFieldFromAttribute(a))
+		}
 	}
 
 	for _, r := range e.Relations {
@@ -120,15 +227,15 @@ func GraphqlCreateMutationFromEntity(e *Entity) *GraphqlFun {
 			f := GraphqlFieldFromRelation(r)
 			f.DataType = "ID"
 
-			m.Args = append(m.Args, f)
+			args = append(args, f)
 		}
 	}
 
-	return m
+	return args
 }
 
 // GraphqlUpdateMutationFromEntity returns a mutation that updates
-// instances of the given entity
+// instances of the given entity, from a single input
 func GraphqlUpdateMutationFromEntity(e *Entity) *GraphqlFun {
 	m := &GraphqlFun{
 		Name: GraphqlUpdateMutationName(e),
@@ -139,8 +246,16 @@ func GraphqlUpdateMutationFromEntity(e *Entity) *GraphqlFun {
 		},
 	}
 
+	m.Args = append(m.Args, GraphqlInputField(GraphqlUpdateInputName(e)))
+	return m
+}
+
+// GraphqlUpdateArgsFromEntity returns the fields that hold the values
+// needed to update an instance of the given entity
+func GraphqlUpdateArgsFromEntity(e *Entity) []*GraphqlField {
+	args := []*GraphqlField{}
 	for _, a := range e.Attributes {
-		m.Args = append(m.Args, GraphqlFieldFromAttribute(a))
+		args = append(args, GraphqlFieldFromAttribute(a))
 	}
 
 	for _, r := range e.Relations {
@@ -148,10 +263,25 @@ func GraphqlUpdateMutationFromEntity(e *Entity) *GraphqlFun {
 			f := GraphqlFieldFromRelation(r)
 			f.DataType = "ID"
 
-			m.Args = append(m.Args, f)
+			args = append(args, f)
 		}
 	}
 
+	return args
+}
+
+// GraphqlPatchMutationFromEntity returns a mutation that partially
+// updates instances of the given entity. It takes the fields of the
+// update input as arguments, all of them optional but the id
+func GraphqlPatchMutationFromEntity(e *Entity) *GraphqlFun {
+	m := GraphqlUpdateMutationFromEntity(e)
+	m.Name = GraphqlPatchMutationName(e)
+	m.Args = GraphqlUpdateArgsFromEntity(e)
+
+	for _, a := range m.Args {
+		a.Required = a.Name == "id"
+	}
+
 	return m
 }
 
@@ -177,6 +307,193 @@ func GraphqlDeleteMutationFromEntity(e *Entity) *GraphqlFun {
 	return m
 }
 
+// GraphqlSubscriptionName returns the name of the subscription to the
+// given kind of change to instances of the given entity
+func GraphqlSubscriptionName(e *Entity, event string) string {
+	return fmt.Sprintf("%s%s", strcase.ToLowerCamel(e.Name), strings.Title(event))
+}
+
+// GraphqlSubscriptionFromEntity returns a subscription to the given
+// kind of change to instances of the given entity. Subscribers get
+// every change, unless they give the id of the instance, or the ids of
+// its parents
+func GraphqlSubscriptionFromEntity(e *Entity, event string) *GraphqlFun {
+	s := &GraphqlFun{
+		Name: GraphqlSubscriptionName(e, event),
+		Returns: &GraphqlField{
+			DataType: e.Name,
+			Required: true,
+			Many:     false,
+		},
+	}
+
+	if e.HasID() {
+		s.Args = append(s.Args, &GraphqlField{
+			Name:     "id",
+			DataType: "ID",
+			Required: false,
+			Many:     false,
+		})
+	}
+
+	for _, r := range SubscriptionRelations(e) {
+		s.Args = append(s.Args, &GraphqlField{
+			Name:     r.Alias(),
+			DataType: "ID",
+			Required: false,
+			Many:     false,
+		})
+	}
+
+	return s
+}
+
+// GraphqlUpsertMutationFromEntity returns a mutation that creates
+// instances of the given entity, or updates them if they already exist.
+// It takes the fields of the create input as arguments, and the ID
+// when it is the upsert key
+func GraphqlUpsertMutationFromEntity(e *Entity) *GraphqlFun {
+	m := GraphqlCreateMutationFromEntity(e)
+	m.Name = GraphqlUpsertMutationName(e)
+	m.Args = GraphqlCreateArgsFromEntity(e, "upsert")
+	return m
+}
+
+// GraphqlCreateInputFromEntity returns the input type that holds the
+// values needed to create an instance of the given entity. Related
+// instances can be given by id, or created along with it from a nested
+// input, so both fields are optional
+func GraphqlCreateInputFromEntity(e *Entity) *GraphqlInput {
+	fields := GraphqlCreateArgsFromEntity(e, "create")
+
+	for _, r := range e.Relations {
+		if !r.SupportsNestedCreate() {
+			continue
+		}
+
+		for _, f := range fields {
+			if f.Name == RelationGraphqlFieldName(r) {
+				f.Required = false
+			}
+		}
+
+		fields = append(fields, &GraphqlField{
+			Name:     NestedCreateFieldName(r),
+			DataType: GraphqlCreateInputName(r.target),
+			Input:    true,
+		})
+	}
+
+	return &GraphqlInput{
+		Name:   GraphqlCreateInputName(e),
+		Fields: fields,
+	}
+}
+
+// NestedCreateFieldName returns the name of the input field that holds
+// the related instance to be created along with an entity, for the
+// given relation
+func NestedCreateFieldName(r *Relation) string {
+	return fmt.Sprintf("create%s", r.Alias())
+}
+
+// GraphqlUpdateInputFromEntity returns the input type that holds the
+// values needed to update an instance of the given entity
+func GraphqlUpdateInputFromEntity(e *Entity) *GraphqlInput {
+	return &GraphqlInput{
+		Name:   GraphqlUpdateInputName(e),
+		Fields: GraphqlUpdateArgsFromEntity(e),
+	}
+}
+
+// GraphqlCreateManyMutationFromEntity returns a mutation that creates
+// a list of instances of the given entity at once
+func GraphqlCreateManyMutationFromEntity(e *Entity) *GraphqlFun {
+	m := &GraphqlFun{
+		Name: GraphqlCreateManyMutationName(e),
+		Returns: &GraphqlField{
+			DataType: e.Name,
+			Required: true,
+			Many:     true,
+		},
+	}
+
+	// without a create input, clients give the number of instances
+	if HasCreateInput(e) {
+		m.Args = append(m.Args, GraphqlInputListField(GraphqlCreateInputName(e)))
+	} else {
+		m.Args = append(m.Args, &GraphqlField{
+			Name:     "count",
+			DataType: "Int",
+			Required: true,
+		})
+	}
+
+	return m
+}
+
+// GraphqlUpdateManyMutationFromEntity returns a mutation that updates
+// a list of instances of the given entity at once
+func GraphqlUpdateManyMutationFromEntity(e *Entity) *GraphqlFun {
+	return &GraphqlFun{
+		Name: GraphqlUpdateManyMutationName(e),
+		Args: []*GraphqlField{
+			GraphqlInputListField(GraphqlUpdateInputName(e)),
+		},
+		Returns: &GraphqlField{
+			DataType: e.Name,
+			Required: true,
+			Many:     true,
+		},
+	}
+}
+
+// GraphqlDeleteManyMutationFromEntity returns a mutation that deletes
+// a list of instances of the given entity, by their ids
+func GraphqlDeleteManyMutationFromEntity(e *Entity) *GraphqlFun {
+	return &GraphqlFun{
+		Name: GraphqlDeleteManyMutationName(e),
+		Args: []*GraphqlField{
+			&GraphqlField{
+				Name:         "ids",
+				DataType:     "ID",
+				Required:     true,
+				Many:         true,
+				ListRequired: true,
+			},
+		},
+		Returns: &GraphqlField{
+			DataType: e.Name,
+			Required: true,
+			Many:     true,
+		},
+	}
+}
+
+// GraphqlInputField returns the argument of a mutation that takes a
+// single input of the given type
+func GraphqlInputField(input string) *GraphqlField {
+	return &GraphqlField{
+		Name:     "input",
+		DataType: input,
+		Required: true,
+		Input:    true,
+	}
+}
+
+// GraphqlInputListField returns the argument of a bulk mutation, which
+// is a required list of inputs of the given type
+func GraphqlInputListField(input string) *GraphqlField {
+	return &GraphqlField{
+		Name:         "input",
+		DataType:     input,
+		Required:     true,
+		Many:         true,
+		ListRequired: true,
+		Input:        true,
+	}
+}
+
 // GraphqlFinderQueryForAll returns a query that finds
 // all instances of an entity.
 func GraphqlFinderQueryForAll(e *Entity) *GraphqlFun {
@@ -203,9 +520,398 @@ func GraphqlFinderQueryForAll(e *Entity) *GraphqlFun {
 		Many:     false,
 	})
 
+	m.Args = append(m.Args, GraphqlFilterField(e))
+	m.Args = append(m.Args, GraphqlOrderByField(e))
+
+	return m
+}
+
+// GraphqlConnectionQueryForAll returns a query that finds a page of
+// instances of an entity, selected with a cursor
+func GraphqlConnectionQueryForAll(e *Entity) *GraphqlFun {
+	m := &GraphqlFun{
+		Name: GraphqlFindAllConnectionQueryName(e),
+		Returns: &GraphqlField{
+			DataType: EntityConnectionName(e),
+			Required: true,
+			Many:     false,
+		},
+	}
+
+	m.Args = append(m.Args, GraphqlPageFields()...)
+	m.Args = append(m.Args, GraphqlFilterField(e))
+	m.Args = append(m.Args, GraphqlOrderByField(e))
+
+	return m
+}
+
+// GraphqlConnectionQueryFromRelation returns a query that finds a page
+// of instances of entity by the ID of the related entity
+func GraphqlConnectionQueryFromRelation(e *Entity, r *Relation) *GraphqlFun {
+	m := &GraphqlFun{
+		Name: GraphqlFindByRelationConnectionQueryName(e, r),
+		Returns: &GraphqlField{
+			DataType: EntityConnectionName(e),
+			Required: true,
+			Many:     false,
+		},
+	}
+
+	m.Args = append(m.Args, &GraphqlField{
+		Name:     r.Alias(),
+		DataType: "ID",
+		Required: true,
+		Many:     false,
+	})
+
+	m.Args = append(m.Args, GraphqlPageFields()...)
+	m.Args = append(m.Args, GraphqlFilterField(e))
+	m.Args = append(m.Args, GraphqlOrderByField(e))
+
 	return m
 }
 
+// GraphqlPageFields returns the arguments that select a page of
+// results. First and After select results forwards, while Last and
+// Before select results backwards
+func GraphqlPageFields() []*GraphqlField {
+	return []*GraphqlField{
+		&GraphqlField{Name: "First", DataType: "Int"},
+		&GraphqlField{Name: "After", DataType: "String"},
+		&GraphqlField{Name: "Last", DataType: "Int"},
+		&GraphqlField{Name: "Before", DataType: "String"},
+	}
+}
+
+// GraphqlNestedPageFields returns the arguments that select a page of
+// a nested list of related entities. Both are optional, so that all
+// related entities are returned by default
+func GraphqlNestedPageFields() []*GraphqlField {
+	return []*GraphqlField{
+		&GraphqlField{Name: "Limit", DataType: "Int"},
+		&GraphqlField{Name: "Offset", DataType: "Int"},
+	}
+}
+
+// GraphqlPageInfoType returns the type that describes a page of
+// results
+func GraphqlPageInfoType() *GraphqlType {
+	return &GraphqlType{
+		Name: "PageInfo",
+		Fields: []*GraphqlField{
+			&GraphqlField{Name: "hasNextPage", DataType: "Boolean", Required: true},
+			&GraphqlField{Name: "hasPreviousPage", DataType: "Boolean", Required: true},
+			&GraphqlField{Name: "startCursor", DataType: "String"},
+			&GraphqlField{Name: "endCursor", DataType: "String"},
+		},
+	}
+}
+
+// GraphqlEdgeTypeFromEntity returns the type that holds an instance of
+// the given entity, and its cursor
+func GraphqlEdgeTypeFromEntity(e *Entity) *GraphqlType {
+	return &GraphqlType{
+		Name: EntityEdgeName(e),
+		Fields: []*GraphqlField{
+			&GraphqlField{Name: "cursor", DataType: "String", Required: true},
+			&GraphqlField{Name: "node", DataType: e.Name, Required: true},
+		},
+	}
+}
+
+// GraphqlConnectionTypeFromEntity returns the type that holds a page of
+// instances of the given entity
+func GraphqlConnectionTypeFromEntity(e *Entity) *GraphqlType {
+	return &GraphqlType{
+		Name: EntityConnectionName(e),
+		Fields: []*GraphqlField{
+			&GraphqlField{Name: "edges", DataType: EntityEdgeName(e), Required: true, Many: true, ListRequired: true},
+			&GraphqlField{Name: "pageInfo", DataType: "PageInfo", Required: true},
+		},
+	}
+}
+
+// GraphqlSearchQueryFromEntity returns a query that finds instances of
+// an entity by full text search, best matches first
+func GraphqlSearchQueryFromEntity(e *Entity) *GraphqlFun {
+	return &GraphqlFun{
+		Name: GraphqlSearchQueryName(e),
+		Args: []*GraphqlField{
+			&GraphqlField{Name: "Query", DataType: "String", Required: true},
+			&GraphqlField{Name: "Limit", DataType: "Int", Required: true},
+			&GraphqlField{Name: "Offset", DataType: "Int", Required: true},
+		},
+		Returns: &GraphqlField{
+			DataType: e.Name,
+			Required: false,
+			Many:     true,
+		},
+	}
+}
+
+// GraphqlCountQueryFromEntity returns a query that counts the
+// instances of an entity that match a filter
+func GraphqlCountQueryFromEntity(e *Entity) *GraphqlFun {
+	return &GraphqlFun{
+		Name: GraphqlCountQueryName(e),
+		Args: []*GraphqlField{GraphqlFilterField(e)},
+		Returns: &GraphqlField{
+			DataType: "Int",
+			Required: true,
+		},
+	}
+}
+
+// GraphqlAggregateQueryFromEntity returns a query that computes
+// aggregates of the instances of an entity that match a filter,
+// optionally grouped by some of its fields
+func GraphqlAggregateQueryFromEntity(e *Entity) *GraphqlFun {
+	return &GraphqlFun{
+		Name: GraphqlAggregateQueryName(e),
+		Args: []*GraphqlField{
+			GraphqlFilterField(e),
+			&GraphqlField{
+				Name:     "groupBy",
+				DataType: EntityGroupFieldName(e),
+				Required: true,
+				Many:     true,
+			},
+		},
+		Returns: &GraphqlField{
+			DataType: EntityAggregateName(e),
+			Required: true,
+			Many:     true,
+		},
+	}
+}
+
+// GraphqlGroupFieldEnumFromEntity returns the enum of fields instances
+// of the given entity can be grouped by
+func GraphqlGroupFieldEnumFromEntity(e *Entity) *GraphqlEnum {
+	t := &GraphqlEnum{
+		Name: EntityGroupFieldName(e),
+	}
+
+	for _, gf := range EntityGroupFields(e) {
+		t.Values = append(t.Values, gf.Name)
+	}
+
+	return t
+}
+
+// GraphqlGroupTypeFromEntity returns the type that holds the values
+// shared by a group of instances of the given entity. Fields are null
+// unless the group was built by them
+func GraphqlGroupTypeFromEntity(e *Entity) *GraphqlType {
+	t := &GraphqlType{
+		Name: EntityGroupName(e),
+	}
+
+	for _, gf := range EntityGroupFields(e) {
+		t.Fields = append(t.Fields, GraphqlGroupField(gf))
+	}
+
+	return t
+}
+
+// GraphqlGroupField returns the field of the group type that holds the
+// value of the given group field
+func GraphqlGroupField(gf *GroupField) *GraphqlField {
+	return &GraphqlField{
+		Name:     strcase.ToLowerCamel(gf.Field),
+		DataType: gf.DataType,
+		Enum:     gf.Enum,
+	}
+}
+
+// GraphqlAggregateValuesTypeFromEntity returns the type that holds a
+// numeric aggregate for each numeric attribute of the given entity
+func GraphqlAggregateValuesTypeFromEntity(e *Entity) *GraphqlType {
+	t := &GraphqlType{
+		Name: EntityAggregateValuesName(e),
+	}
+
+	for _, a := range AggregateAttributes(e) {
+		t.Fields = append(t.Fields, &GraphqlField{
+			Name:     AttributeGraphqlFieldName(a),
+			DataType: "Float",
+		})
+	}
+
+	return t
+}
+
+// GraphqlAggregateTypeFromEntity returns the type that holds the
+// aggregates of a group of instances of the given entity. Numeric
+// aggregates are only available for entities with numeric attributes
+func GraphqlAggregateTypeFromEntity(e *Entity) *GraphqlType {
+	t := &GraphqlType{
+		Name: EntityAggregateName(e),
+		Fields: []*GraphqlField{
+			&GraphqlField{Name: "group", DataType: EntityGroupName(e), Required: true},
+			&GraphqlField{Name: "count", DataType: "Int", Required: true},
+		},
+	}
+
+	if len(AggregateAttributes(e)) > 0 {
+		for _, fun := range AggregateFunctions() {
+			t.Fields = append(t.Fields, &GraphqlField{
+				Name:     strcase.ToLowerCamel(fun[0]),
+				DataType: EntityAggregateValuesName(e),
+				Required: true,
+			})
+		}
+	}
+
+	return t
+}
+
+// GraphqlFilterField returns the optional argument that list queries
+// of the given entity take, in order to filter results
+func GraphqlFilterField(e *Entity) *GraphqlField {
+	return &GraphqlField{
+		Name:     "filter",
+		DataType: EntityFilterName(e),
+		Required: false,
+		Many:     false,
+		Input:    true,
+	}
+}
+
+// GraphqlOrderByField returns the optional argument that list queries
+// of the given entity take, in order to sort results by one or more
+// fields
+func GraphqlOrderByField(e *Entity) *GraphqlField {
+	return &GraphqlField{
+		Name:     "orderBy",
+		DataType: EntityOrderName(e),
+		Required: true,
+		Many:     true,
+		Input:    true,
+	}
+}
+
+// GraphqlSortDirectionEnum returns the enum of directions results can
+// be sorted in
+func GraphqlSortDirectionEnum() *GraphqlEnum {
+	return &GraphqlEnum{
+		Name:   SortDirectionName(),
+		Values: SortDirections(),
+	}
+}
+
+// GraphqlSortFieldEnumFromEntity returns the enum of fields instances
+// of the given entity can be sorted by
+func GraphqlSortFieldEnumFromEntity(e *Entity) *GraphqlEnum {
+	t := &GraphqlEnum{
+		Name: EntitySortFieldName(e),
+	}
+
+	for _, a := range e.Attributes {
+		t.Values = append(t.Values, AttributeSortField(a))
+	}
+
+	return t
+}
+
+// GraphqlOrderInputFromEntity returns the input type that sorts
+// instances of the given entity by a single field. The direction
+// defaults to ascending
+func GraphqlOrderInputFromEntity(e *Entity) *GraphqlInput {
+	return &GraphqlInput{
+		Name: EntityOrderName(e),
+		Fields: []*GraphqlField{
+			&GraphqlField{
+				Name:     "field",
+				DataType: EntitySortFieldName(e),
+				Required: true,
+			},
+			&GraphqlField{
+				Name:     "direction",
+				DataType: SortDirectionName(),
+			},
+		},
+	}
+}
+
+// GraphqlFilterInputFromEntity returns the input type that holds
+// conditions on instances of the given entity. There is a field for
+// each attribute and relation, and lists of nested filters that are
+// combined with and/or
+func GraphqlFilterInputFromEntity(e *Entity) *GraphqlInput {
+	i := &GraphqlInput{
+		Name: EntityFilterName(e),
+	}
+
+	for _, name := range []string{"and", "or"} {
+		i.Fields = append(i.Fields, &GraphqlField{
+			Name:     name,
+			DataType: EntityFilterName(e),
+			Required: true,
+			Many:     true,
+			Input:    true,
+		})
+	}
+
+	for _, a := range e.Attributes {
+		i.Fields = append(i.Fields, &GraphqlField{
+			Name:     AttributeGraphqlFieldName(a),
+			DataType: AttributeScalarFilter(a).Name,
+			Input:    true,
+		})
+	}
+
+	for _, r := range e.Relations {
+		if r.HasModifier("belongsTo") || r.HasModifier("hasOne") {
+			i.Fields = append(i.Fields, &GraphqlField{
+				Name:     RelationGraphqlFieldName(r),
+				DataType: ScalarFilterForDataType("ID").Name,
+				Input:    true,
+			})
+		}
+	}
+
+	return i
+}
+
+// GraphqlScalarFilterInput returns the input type that holds
+// conditions on values of a scalar type
+func GraphqlScalarFilterInput(sf *ScalarFilter) *GraphqlInput {
+	i := &GraphqlInput{
+		Name: sf.Name,
+	}
+
+	for _, op := range ScalarFilterOperators(sf) {
+		i.Fields = append(i.Fields, &GraphqlField{
+			Name:     strcase.ToLowerCamel(op[0]),
+			DataType: sf.DataType,
+			Enum:     sf.Enum,
+		})
+	}
+
+	i.Fields = append(i.Fields, &GraphqlField{
+		Name:     "in",
+		DataType: sf.DataType,
+		Required: true,
+		Many:     true,
+		Enum:     sf.Enum,
+	})
+
+	if sf.Contains {
+		i.Fields = append(i.Fields, &GraphqlField{
+			Name:     "contains",
+			DataType: "String",
+		})
+	}
+
+	i.Fields = append(i.Fields, &GraphqlField{
+		Name:     "isNull",
+		DataType: "Boolean",
+	})
+
+	return i
+}
+
 // GraphqlFinderQueryFromAttribute returns a query that finds
 // a single instance of entity by an indexed and unique attribute
 func GraphqlFinderQueryFromAttribute(e *Entity, a *Attribute) *GraphqlFun {
@@ -222,6 +928,60 @@ func GraphqlFinderQueryFromAttribute(e *Entity, a *Attribute) *GraphqlFun {
 	return m
 }
 
+// GraphqlListFinderQueryFromAttribute returns a query that finds a list
+// of instances of entity by an indexed attribute that is not unique
+func GraphqlListFinderQueryFromAttribute(e *Entity, a *Attribute) *GraphqlFun {
+	m := &GraphqlFun{
+		Name: GraphqlFindListByAttributeQueryName(e, a),
+		Returns: &GraphqlField{
+			DataType: e.Name,
+			Required: false,
+			Many:     true,
+		},
+	}
+
+	m.Args = append(m.Args, GraphqlFieldFromAttribute(a))
+
+	m.Args = append(m.Args, &GraphqlField{
+		Name:     "Limit",
+		DataType: "Int",
+		Required: true,
+		Many:     false,
+	})
+
+	m.Args = append(m.Args, &GraphqlField{
+		Name:     "Offset",
+		DataType: "Int",
+		Required: true,
+		Many:     false,
+	})
+
+	m.Args = append(m.Args, GraphqlFilterField(e))
+	m.Args = append(m.Args, GraphqlOrderByField(e))
+
+	return m
+}
+
+// GraphqlConnectionQueryFromAttribute returns a query that finds a page
+// of instances of entity by an indexed attribute that is not unique
+func GraphqlConnectionQueryFromAttribute(e *Entity, a *Attribute) *GraphqlFun {
+	m := &GraphqlFun{
+		Name: GraphqlFindListByAttributeConnectionQueryName(e, a),
+		Returns: &GraphqlField{
+			DataType: EntityConnectionName(e),
+			Required: true,
+			Many:     false,
+		},
+	}
+
+	m.Args = append(m.Args, GraphqlFieldFromAttribute(a))
+	m.Args = append(m.Args, GraphqlPageFields()...)
+	m.Args = append(m.Args, GraphqlFilterField(e))
+	m.Args = append(m.Args, GraphqlOrderByField(e))
+
+	return m
+}
+
 // GraphqlFinderQueryFromRelation returns a query that finds
 // a list of instances of entity by the ID of the related entity
 func GraphqlFinderQueryFromRelation(e *Entity, r *Relation) *GraphqlFun {
@@ -255,6 +1015,9 @@ func GraphqlFinderQueryFromRelation(e *Entity, r *Relation) *GraphqlFun {
 		Many:     false,
 	})
 
+	m.Args = append(m.Args, GraphqlFilterField(e))
+	m.Args = append(m.Args, GraphqlOrderByField(e))
+
 	return m
 }
 
@@ -270,24 +1033,110 @@ func GraphqlUpdateMutationName(e *Entity) string {
 	return fmt.Sprintf("update%s", e.Name)
 }
 
+// GraphqlPatchMutationName returns the name of the mutation that
+// partially updates instances of the given entity
+func GraphqlPatchMutationName(e *Entity) string {
+	return fmt.Sprintf("patch%s", e.Name)
+}
+
 // GraphqlDeleteMutationName returns the name of the mutation that
 // deletes instances of the given entity
 func GraphqlDeleteMutationName(e *Entity) string {
 	return fmt.Sprintf("delete%s", e.Name)
 }
 
+// GraphqlUpsertMutationName returns the name of the mutation that
+// upserts instances of the given entity
+func GraphqlUpsertMutationName(e *Entity) string {
+	return fmt.Sprintf("upsert%s", e.Name)
+}
+
+// GraphqlCreateManyMutationName returns the name of the mutation that
+// creates a list of new instances of the given entity
+func GraphqlCreateManyMutationName(e *Entity) string {
+	return fmt.Sprintf("createMany%s", e.PluralName())
+}
+
+// GraphqlUpdateManyMutationName returns the name of the mutation that
+// updates a list of instances of the given entity
+func GraphqlUpdateManyMutationName(e *Entity) string {
+	return fmt.Sprintf("updateMany%s", e.PluralName())
+}
+
+// GraphqlDeleteManyMutationName returns the name of the mutation that
+// deletes a list of instances of the given entity
+func GraphqlDeleteManyMutationName(e *Entity) string {
+	return fmt.Sprintf("deleteMany%s", e.PluralName())
+}
+
+// GraphqlCreateInputName returns the name of the input type used to
+// create instances of the given entity
+func GraphqlCreateInputName(e *Entity) string {
+	return fmt.Sprintf("Create%sInput", e.Name)
+}
+
+// GraphqlUpdateInputName returns the name of the input type used to
+// update instances of the given entity
+func GraphqlUpdateInputName(e *Entity) string {
+	return fmt.Sprintf("Update%sInput", e.Name)
+}
+
 // GraphqlFindAllQueryName returns the name of the query
 // that finds all instances of the given entity
 func GraphqlFindAllQueryName(e *Entity) string {
 	return fmt.Sprintf("findAll%s", e.PluralName())
 }
 
+// GraphqlFindAllConnectionQueryName returns the name of the query that
+// finds a page of instances of the given entity
+func GraphqlFindAllConnectionQueryName(e *Entity) string {
+	return fmt.Sprintf("%sConnection", GraphqlFindAllQueryName(e))
+}
+
+// GraphqlFindByRelationConnectionQueryName returns the name of the
+// query that finds a page of instances of the given entity by relation
+func GraphqlFindByRelationConnectionQueryName(e *Entity, r *Relation) string {
+	return fmt.Sprintf("%sConnection", GraphqlFindByRelationQueryName(e, r))
+}
+
+// GraphqlSearchQueryName returns the name of the query that searches
+// instances of the given entity
+func GraphqlSearchQueryName(e *Entity) string {
+	return fmt.Sprintf("search%s", e.PluralName())
+}
+
+// GraphqlCountQueryName returns the name of the query that counts
+// instances of the given entity
+func GraphqlCountQueryName(e *Entity) string {
+	return fmt.Sprintf("count%s", e.PluralName())
+}
+
+// GraphqlAggregateQueryName returns the name of the query that computes
+// aggregates of instances of the given entity
+func GraphqlAggregateQueryName(e *Entity) string {
+	return fmt.Sprintf("aggregate%s", e.PluralName())
+}
+
 // GraphqlFindByAttributeQueryName returns the name of the query that
 // find instances of the given entity by the given attribute
 func GraphqlFindByAttributeQueryName(e *Entity, a *Attribute) string {
 	return fmt.Sprintf("find%sBy%s", e.Name, a.Name)
 }
 
+// GraphqlFindListByAttributeQueryName returns the name of the query
+// that finds a list of instances of the given entity by the given
+// attribute
+func GraphqlFindListByAttributeQueryName(e *Entity, a *Attribute) string {
+	return fmt.Sprintf("find%sBy%s", e.PluralName(), a.Name)
+}
+
+// GraphqlFindListByAttributeConnectionQueryName returns the name of the
+// query that finds a page of instances of the given entity by the given
+// attribute
+func GraphqlFindListByAttributeConnectionQueryName(e *Entity, a *Attribute) string {
+	return fmt.Sprintf("%sConnection", GraphqlFindListByAttributeQueryName(e, a))
+}
+
 // GraphqlFindByRelationQueryName returns the name of the query that
 // find instances of the given entity by the given attribute
 func GraphqlFindByRelationQueryName(e *Entity, r *Relation) string {
@@ -316,11 +1165,15 @@ func GraphqlFinderQueryByParent(e *Entity, r *Relation) *GraphqlFun {
 
 // GraphqlSchema is an internal simplified Graphql model
 type GraphqlSchema struct {
-	Enums     []*GraphqlEnum
-	Unions    []*GraphqlUnion
-	Types     []*GraphqlType
-	Queries   []*GraphqlFun
-	Mutations []*GraphqlFun
+	Enums      []*GraphqlEnum
+	Unions     []*GraphqlUnion
+	Interfaces []*GraphqlInterface
+	Types      []*GraphqlType
+	Inputs     []*GraphqlInput
+	Queries    []*GraphqlFun
+	Mutations  []*GraphqlFun
+
+	Subscriptions []*GraphqlFun
 }
 
 func (s *GraphqlSchema) String() string {
@@ -328,7 +1181,12 @@ func (s *GraphqlSchema) String() string {
 	chunks = append(chunks, `
 	schema {
         query: Query
-        mutation: Mutation
+        mutation: Mutation`)
+	if len(s.Subscriptions) > 0 {
+		chunks = append(chunks, `
+        subscription: Subscription`)
+	}
+	chunks = append(chunks, `
     }`)
 
 	for _, e := range s.Enums {
@@ -339,36 +1197,80 @@ func (s *GraphqlSchema) String() string {
 		chunks = append(chunks, fmt.Sprintf("%s\n", u.String()))
 	}
 	chunks = append(chunks, "\n\n")
+	for _, i := range s.Interfaces {
+		chunks = append(chunks, fmt.Sprintf("%s\n", i.String()))
+	}
+	chunks = append(chunks, "\n\n")
 	for _, t := range s.Types {
 		chunks = append(chunks, fmt.Sprintf("%s\n", t.String()))
 	}
 	chunks = append(chunks, "\n\n")
+	for _, i := range s.Inputs {
+		chunks = append(chunks, fmt.Sprintf("%s\n", i.String()))
+	}
+	chunks = append(chunks, "\n\n")
 	chunks = append(chunks, "type Mutation {\n")
 	for _, m := range s.Mutations {
+		chunks = append(chunks, GraphqlDescription(m.Desc, "  "))
 		chunks = append(chunks, fmt.Sprintf("  %s\n", m.String()))
 	}
 	chunks = append(chunks, "}\n")
 	chunks = append(chunks, "\n\n")
 	chunks = append(chunks, "type Query {\n")
 	for _, q := range s.Queries {
+		chunks = append(chunks, GraphqlDescription(q.Desc, "  "))
 		chunks = append(chunks, fmt.Sprintf("  %s\n", q.String()))
 	}
 	chunks = append(chunks, "}\n")
+	if len(s.Subscriptions) > 0 {
+		chunks = append(chunks, "\n\n")
+		chunks = append(chunks, "type Subscription {\n")
+		for _, sub := range s.Subscriptions {
+			chunks = append(chunks, GraphqlDescription(sub.Desc, "  "))
+			chunks = append(chunks, fmt.Sprintf("  %s\n", sub.String()))
+		}
+		chunks = append(chunks, "}\n")
+	}
 	return strings.Join(chunks, "")
 }
 
 // GraphqlType is an internal simplified Graphql model
 type GraphqlType struct {
-	Name   string
-	Desc   string
-	Fields []*GraphqlField
+	Name       string
+	Desc       string
+	Interfaces []string
+	Fields     []*GraphqlField
 }
 
 func (t *GraphqlType) String() string {
 	chunks := []string{}
-	chunks = append(chunks, fmt.Sprintf("type %s {\n", t.Name))
+	chunks = append(chunks, GraphqlDescription(t.Desc, ""))
+	if len(t.Interfaces) > 0 {
+		chunks = append(chunks, fmt.Sprintf("type %s implements %s {\n", t.Name, strings.Join(t.Interfaces, " & ")))
+	} else {
+		chunks = append(chunks, fmt.Sprintf("type %s {\n", t.Name))
+	}
 	for _, f := range t.Fields {
-		chunks = append(chunks, fmt.Sprintf("  %s: %s\n", f.Name, f.DataTypeString()))
+		chunks = append(chunks, GraphqlDescription(f.Desc, "  "))
+		chunks = append(chunks, fmt.Sprintf("  %s%s: %s\n", f.Name, f.ArgsString(), f.DataTypeString()))
+	}
+	chunks = append(chunks, "}\n")
+	return strings.Join(chunks, "")
+}
+
+// GraphqlInput is an internal simplified Graphql model for input
+// object types
+type GraphqlInput struct {
+	Name   string
+	Fields []*GraphqlField
+}
+
+func (i *GraphqlInput) String() string {
+	chunks := []string{}
+	chunks = append(chunks, fmt.Sprintf("input %s {\n", i.Name))
+	for _, f := range i.Fields {
+		chunks = append(chunks, GraphqlDescription(f.Desc, "  "))
+		chunks = append(chunks, fmt.Sprintf("  %s: %s\n", strcase.ToLowerCamel(f.Name), f.DataTypeString()))
 	}
 	chunks = append(chunks, "}\n")
 	return strings.Join(chunks, "")
@@ -383,6 +1285,10 @@ type GraphqlFun struct {
 }
 
 func (o *GraphqlFun) String() string {
+	if len(o.Args) == 0 {
+		return fmt.Sprintf("%s: %s", o.Name, o.Returns.DataTypeString())
+	}
+
 	args := []string{}
 	for _, a := range o.Args {
 		args = append(args, fmt.Sprintf("%s:%s", strcase.ToLowerCamel(a.Name), a.DataTypeString()))
@@ -395,12 +1301,22 @@ func (o *GraphqlFun) String() string {
 	)
 }
 
-// GraphqlField is an internal simplified Graphql model
+// GraphqlField is an internal simplified Graphql model. Required
+// applies to the values of the field, while ListRequired makes the list
+// itself non nullable, when Many is set. Input indicates the data type
+// is a generated input object, and Enum an enum of the model, which
+// has a Golang type of the same name. Fields of object types may take
+// Args
 type GraphqlField struct {
-	Name     string
-	DataType string
-	Required bool
-	Many     bool
+	Name         string
+	Desc         string
+	DataType     string
+	Required     bool
+	Many         bool
+	ListRequired bool
+	Input        bool
+	Enum         bool
+	Args         []*GraphqlField
 }
 
 // GraphqlFieldFromAttribute converts a model attribute into a more
@@ -408,21 +1324,106 @@ type GraphqlField struct {
 func GraphqlFieldFromAttribute(a *Attribute) *GraphqlField {
 	return &GraphqlField{
 		Name:     AttributeGraphqlFieldName(a),
+		Desc:     a.Description,
 		DataType: AttributeGraphqlFieldDataType(a),
 		Required: true,
 		Many:     false,
+		Enum:     a.Enum() != nil,
 	}
 }
 
 // GraphqlUnion represents a Graphql Union type.
 type GraphqlUnion struct {
 	Name   string
+	Desc   string
 	Values []string
 }
 
 // Generates the text representation of the Union type
 func (t *GraphqlUnion) String() string {
-	return fmt.Sprintf("union %s = %s", t.Name, strings.Join(t.Values, " | "))
+	return fmt.Sprintf("%sunion %s = %s", GraphqlDescription(t.Desc, ""), t.Name, strings.Join(t.Values, " | "))
+}
+
+// GraphqlInterface is an internal simplified Graphql model
+type GraphqlInterface struct {
+	Name   string
+	Desc   string
+	Fields []*GraphqlField
+}
+
+// Generates the text representation of the Interface type
+func (i *GraphqlInterface) String() string {
+	chunks := []string{}
+	chunks = append(chunks, GraphqlDescription(i.Desc, ""))
+	chunks = append(chunks, fmt.Sprintf("interface %s {\n", i.Name))
+	for _, f := range i.Fields {
+		chunks = append(chunks, GraphqlDescription(f.Desc, "  "))
+		chunks = append(chunks, fmt.Sprintf("  %s: %s\n", f.Name, f.DataTypeString()))
+	}
+	chunks = append(chunks, "}\n")
+	return strings.Join(chunks, "")
+}
+
+// GraphqlNodeInterface returns the Relay Node interface, implemented
+// by the types whose instances can be found by global ID
+func GraphqlNodeInterface() *GraphqlInterface {
+	return &GraphqlInterface{
+		Name: "Node",
+		Desc: "An object with a global ID",
+		Fields: []*GraphqlField{
+			&GraphqlField{
+				Name:     "id",
+				Desc:     "The global ID of the object",
+				DataType: "ID",
+				Required: true,
+			},
+		},
+	}
+}
+
+// GraphqlNodeQuery returns the Relay query that finds an instance of
+// any type that implements Node, by global ID
+func GraphqlNodeQuery() *GraphqlFun {
+	return &GraphqlFun{
+		Name: "node",
+		Desc: "Finds an object by its global ID",
+		Args: []*GraphqlField{
+			&GraphqlField{
+				Name:     "id",
+				DataType: "ID",
+				Required: true,
+			},
+		},
+		Returns: &GraphqlField{
+			DataType: "Node",
+			Required: false,
+		},
+	}
+}
+
+// GraphqlNodesQuery returns the query that finds a list of instances
+// of any type that implements Node, by global ID. Instances that are
+// not found are returned as null
+func GraphqlNodesQuery() *GraphqlFun {
+	return &GraphqlFun{
+		Name: "nodes",
+		Desc: "Finds objects by their global IDs, in the same order. Objects that are not found are null",
+		Args: []*GraphqlField{
+			&GraphqlField{
+				Name:         "ids",
+				DataType:     "ID",
+				Required:     true,
+				Many:         true,
+				ListRequired: true,
+			},
+		},
+		Returns: &GraphqlField{
+			DataType:     "Node",
+			Required:     false,
+			Many:         true,
+			ListRequired: true,
+		},
+	}
 }
 
 // GraphqlUnionFromUDType converts the given user defined type into a
@@ -430,20 +1431,26 @@ func (t *GraphqlUnion) String() string {
 func GraphqlUnionFromUDType(t *UDType) *GraphqlUnion {
 	return &GraphqlUnion{
 		Name:   t.Name,
+		Desc:   t.Description,
 		Values: t.Values,
 	}
 }
 
-// GraphqlEnum represents a Graphql Enum.
+// GraphqlEnum represents a Graphql Enum. ValueDescs holds the
+// descriptions of the values that have one
 type GraphqlEnum struct {
-	Name   string
-	Values []string
+	Name       string
+	Desc       string
+	Values     []string
+	ValueDescs map[string]string
 }
 
 func (t *GraphqlEnum) String() string {
 	chunks := []string{}
+	chunks = append(chunks, GraphqlDescription(t.Desc, ""))
 	chunks = append(chunks, fmt.Sprintf("enum %s {\n", t.Name))
 	for _, v := range t.Values {
+		chunks = append(chunks, GraphqlDescription(t.ValueDescs[v], "  "))
 		chunks = append(chunks, fmt.Sprintf("  %s\n", v))
 	}
 	chunks = append(chunks, "}\n")
@@ -453,10 +1460,33 @@ func (t *GraphqlEnum) String() string {
 // GraphqlEnumFromUDType converts the given user defined type into a
 // Graphql enum
 func GraphqlEnumFromUDType(t *UDType) *GraphqlEnum {
+	descs := map[string]string{}
+	for _, v := range t.Values {
+		if desc := t.ValueDescription(v); desc != "" {
+			descs[v] = desc
+		}
+	}
+
 	return &GraphqlEnum{
-		Name:   t.Name,
-		Values: t.Values,
+		Name:       t.Name,
+		Desc:       t.Description,
+		Values:     t.Values,
+		ValueDescs: descs,
+	}
+}
+
+// GraphqlDescription returns the given description as a Graphql
+// string, on its own line and with the given indentation, or an empty
+// string if there is no description. Json strings are valid Graphql
+// strings, with the same escapes
+func GraphqlDescription(desc string, indent string) string {
+	desc = strings.TrimSpace(desc)
+	if desc == "" {
+		return ""
 	}
+
+	quoted, _ := json.Marshal(desc)
+	return fmt.Sprintf("%s%s\n", indent, quoted)
 }
 
 // AttributeGraphqlFieldName returns the Graphql field name for the
@@ -485,14 +1515,24 @@ func AttributeGraphqlFieldDataType(a *Attribute) string {
 }
 
 // GraphqlFieldFromRelation converts a model relation into a more
-// convenient Graphql Field
+// convenient Graphql Field. Lists of related entities can be
+// paginated, filtered and sorted
 func GraphqlFieldFromRelation(r *Relation) *GraphqlField {
-	return &GraphqlField{
+	f := &GraphqlField{
 		Name:     RelationGraphqlFieldName(r),
+		Desc:     r.Description,
 		DataType: RelationGraphqlFieldDataType(r),
 		Required: true,
 		Many:     r.HasModifier("hasMany"),
 	}
+
+	if f.Many {
+		f.Args = append(f.Args, GraphqlNestedPageFields()...)
+		f.Args = append(f.Args, GraphqlFilterField(&Entity{Name: r.Entity}))
+		f.Args = append(f.Args, GraphqlOrderByField(&Entity{Name: r.Entity}))
+	}
+
+	return f
 }
 
 // GraphqlInputFieldFromRelation converts a model relation into a more
@@ -501,6 +1541,7 @@ func GraphqlFieldFromRelation(r *Relation) *GraphqlField {
 func GraphqlInputFieldFromRelation(r *Relation) *GraphqlField {
 	f := GraphqlFieldFromRelation(r)
 	f.DataType = "ID"
+	f.Args = nil
 	return f
 }
 
@@ -524,7 +1565,26 @@ func (f *GraphqlField) DataTypeString() string {
 
 	if f.Many {
 		s = fmt.Sprintf("[%s]", s)
+
+		if f.ListRequired {
+			s = fmt.Sprintf("%s!", s)
+		}
 	}
 
 	return s
 }
+
+// ArgsString returns the Graphql representation of the arguments of
+// the field, or an empty string if it takes none
+func (f *GraphqlField) ArgsString() string {
+	if len(f.Args) == 0 {
+		return ""
+	}
+
+	args := []string{}
+	for _, a := range f.Args {
+		args = append(args, fmt.Sprintf("%s:%s", strcase.ToLowerCamel(a.Name), a.DataTypeString()))
+	}
+
+	return fmt.Sprintf("(%s)", strings.Join(args, ", "))
+}
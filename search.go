@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	. "github.com/dave/jennifer/jen"
+)
+
+// SearchConfiguration is the postgres text search configuration used
+// to build and query search vectors. The simple configuration does
+// not depend on the language of the indexed text
+const SearchConfiguration = "simple"
+
+// SearchableAttributes returns the attributes of the given entity that
+// are indexed for full text search
+func SearchableAttributes(e *Entity) []*Attribute {
+	attributes := []*Attribute{}
+	for _, a := range e.Attributes {
+		if a.HasModifier("searchable") {
+			attributes = append(attributes, a)
+		}
+	}
+
+	return attributes
+}
+
+// SupportsSearch returns whether the given entity can be searched
+func SupportsSearch(e *Entity) bool {
+	return e.SupportsOperation("find") && len(SearchableAttributes(e)) > 0
+}
+
+// ModelSupportsSearch returns whether any entity in the given model can
+// be searched
+func ModelSupportsSearch(m *Model) bool {
+	for _, e := range m.Entities {
+		if SupportsSearch(e) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FailWithoutFts5 adds the code that exits with a clear message when
+// the schema could not be created because the sqlite3 driver was built
+// without FTS5, which search needs
+func FailWithoutFts5(g *Group) {
+	g.If(
+		Id("dialect").Op("==").Id(DialectName("sqlite3")).
+			Op("&&").Err().Op("!=").Nil().
+			Op("&&").Qual("strings", "Contains").Call(Err().Dot("Error").Call(), Lit("no such module: fts5")),
+	).Block(
+		Qual("log", "Fatal").Call(Lit("Error initializing database: the sqlite3 driver was built without FTS5, which search needs. Build the app with: go build -tags sqlite_fts5")),
+	)
+}
+
+// SearchTableName returns the name of the sqlite3 FTS5 table that
+// indexes the given entity
+func SearchTableName(e *Entity) string {
+	return fmt.Sprintf("%s_search", TableName(e))
+}
+
+// SearchColumnName returns the name of the postgres column that holds
+// the search vector of the given entity
+func SearchColumnName() string {
+	return "search_vector"
+}
+
+// SearchableColumns returns the columns of the searchable attributes of
+// the given entity, prefixed with the given string
+func SearchableColumns(e *Entity, prefix string) []string {
+	columns := []string{}
+	for _, a := range SearchableAttributes(e) {
+		columns = append(columns, prefix+AttributeColumnName(a))
+	}
+
+	return columns
+}
+
+// TableColumnForSearch builds the specification of the postgres
+// generated column that holds the search vector of the given entity
+func TableColumnForSearch(e *Entity) string {
+	values := []string{}
+	for _, c := range SearchableColumns(e, "") {
+		values = append(values, fmt.Sprintf("coalesce(%s, '')", c))
+	}
+
+	return fmt.Sprintf(
+		"%s tsvector GENERATED ALWAYS AS (to_tsvector('%s', %s)) STORED",
+		SearchColumnName(),
+		SearchConfiguration,
+		strings.Join(values, " || ' ' || "),
+	)
+}
+
+// AddEntitySearchIndex adds the statements that index the given entity
+// for full text search. On sqlite3, this is an external content FTS5
+// table, kept in sync by triggers. On postgres, this is a GIN index on
+// the generated search vector column
+func AddEntitySearchIndex(e *Entity, db string, g *Group) {
+	table := TableName(e)
+	search := SearchTableName(e)
+
+	if db != "sqlite3" {
+		g.Lit(fmt.Sprintf("CREATE INDEX %s ON %s USING GIN (%s)", search, table, SearchColumnName()))
+		return
+	}
+
+	columns := strings.Join(SearchableColumns(e, ""), ", ")
+	newValues := strings.Join(SearchableColumns(e, "new."), ", ")
+	oldValues := strings.Join(SearchableColumns(e, "old."), ", ")
+
+	insert := fmt.Sprintf("INSERT INTO %s(rowid, %s) VALUES (new.rowid, %s);", search, columns, newValues)
+	remove := fmt.Sprintf("INSERT INTO %s(%s, rowid, %s) VALUES ('delete', old.rowid, %s);", search, search, columns, oldValues)
+
+	g.Lit(fmt.Sprintf("CREATE VIRTUAL TABLE %s USING fts5(%s, content='%s', content_rowid='rowid')", search, columns, table))
+	g.Lit(fmt.Sprintf("CREATE TRIGGER %s_insert AFTER INSERT ON %s BEGIN %s END", search, table, insert))
+	g.Lit(fmt.Sprintf("CREATE TRIGGER %s_delete AFTER DELETE ON %s BEGIN %s END", search, table, remove))
+	g.Lit(fmt.Sprintf("CREATE TRIGGER %s_update AFTER UPDATE ON %s BEGIN %s %s END", search, table, remove, insert))
+}
+
+// SearchStatement generates the SELECT statement that finds instances
+// of the given entity matching a search query, bound as the first
+// parameter, with the best matches first
+func SearchStatement(e *Entity, db string) string {
+	table := TableName(e)
+	columns := []string{}
+	for _, c := range EntityColumns(e) {
+		columns = append(columns, fmt.Sprintf("%s.%s", table, c))
+	}
+
+	if db != "sqlite3" {
+		query := fmt.Sprintf("websearch_to_tsquery('%s', $1)", SearchConfiguration)
+		return fmt.Sprintf(
+			"SELECT %s FROM %s WHERE %s @@ %s ORDER BY ts_rank(%s, %s) DESC, %s.id ASC",
+			strings.Join(columns, ","),
+			table,
+			SearchColumnName(),
+			query,
+			SearchColumnName(),
+			query,
+			table,
+		)
+	}
+
+	search := SearchTableName(e)
+	return fmt.Sprintf(
+		"SELECT %s FROM %s JOIN %s ON %s.rowid = %s.rowid WHERE %s MATCH $1 ORDER BY %s.rank, %s.id ASC",
+		strings.Join(columns, ","),
+		table,
+		search,
+		search,
+		table,
+		search,
+		search,
+		table,
+	)
+}
+
+// SearchPageStatement generates the sql statement that selects a page
+// of instances of the given entity matching a search query. The limit
+// and offset are bound after the query
+func SearchPageStatement(e *Entity, db string) string {
+	return fmt.Sprintf("%s LIMIT $2 OFFSET $3", SearchStatement(e, db))
+}
+
+// AddSqlSearchQueryFun generates a function that turns the text typed
+// by users into a search query. On sqlite3, every word is quoted, so
+// that FTS5 syntax in the text is matched literally. Postgres parses
+// the text with websearch_to_tsquery, which never fails
+func AddSqlSearchQueryFun(f *File) {
+	funName := "SqlSearchQuery"
+
+	f.Comment(fmt.Sprintf("%s returns the search query, in the given dialect, that matches all words in the given text", funName))
+	f.Func().Id(funName).Params(
+		Id("dialect").Id("Dialect"),
+		Id("text").String(),
+	).String().Block(
+		DialectSwitch(Id("dialect"), func(db string, g *Group) {
+			if db != "sqlite3" {
+				g.Return(Id("text"))
+				return
+			}
+
+			g.Id("terms").Op(":=").Index().String().Values()
+			g.For(List(Id("_"), Id("word")).Op(":=").Range().Qual("strings", "Fields").Call(Id("text"))).Block(
+				Id("terms").Op("=").Append(
+					Id("terms"),
+					Lit(`"`).Op("+").Qual("strings", "ReplaceAll").Call(Id("word"), Lit(`"`), Lit(`""`)).Op("+").Lit(`"`),
+				),
+			)
+
+			g.Return(Qual("strings", "Join").Call(Id("terms"), Lit(" ")))
+		}),
+	)
+}
+
+// AddSearchFun produces a function that finds instances of the given
+// entity by full text search on its searchable attributes
+func AddSearchFun(e *Entity, f *File) {
+	funName := SearchFunName(e)
+
+	f.Comment(fmt.Sprintf("%s finds instances of type %s whose %s match all words in the given query, best matches first. If no row matches, then this function returns an empty slice", funName, e.Name, strings.Join(SearchableColumns(e, ""), ", ")))
+	f.Func().Id(funName).Params(
+		Id("ctx").Qual("context", "Context"),
+		Id("db").Id("DBTX"),
+		Id("stmts").Op("*").Id("Statements"),
+		Id("query").String(),
+		Id("limit").Int32(),
+		Id("offset").Int32(),
+	).Parens(List(
+		Op("[]").Op("*").Id(e.Name),
+		Error(),
+	)).BlockFunc(func(g *Group) {
+		ifErrReturn := If(Err().Op("!=").Nil()).Block(
+			Return(
+				Id(VarName(e.PluralName())),
+				Err(),
+			),
+		)
+
+		g.Id(VarName(e.PluralName())).Op(":=").Op("[]").Op("*").Id(e.Name).Values()
+
+		// an empty query would be a syntax error on sqlite3
+		g.Id("query").Op("=").Id("SqlSearchQuery").Call(Id("stmts").Dot("Dialect").Call(), Id("query"))
+		g.If(Id("query").Op("==").Lit("")).Block(
+			Return(Id(VarName(e.PluralName())), Nil()),
+		)
+
+		g.Var().Id("statement").String()
+		g.Add(DialectSwitch(Id("stmts").Dot("Dialect").Call(), func(db string, g2 *Group) {
+			g2.Id("statement").Op("=").Lit(SearchPageStatement(e, db))
+		}))
+
+		PrepareStatement(Id("statement"), g)
+		g.Add(ifErrReturn)
+		DeferCall("stmt", "Close", g)
+
+		g.List(
+			Id("rows"),
+			Err(),
+		).Op(":=").Id("stmt").Dot("QueryContext").Call(Id("ctx"), Id("query"), Id("limit"), Id("offset"))
+		g.Add(ifErrReturn)
+		DeferCall("rows", "Close", g)
+
+		g.For(
+			Id("rows").Dot("Next").Call(),
+		).BlockFunc(func(g2 *Group) {
+			g2.Add(EmptyStructForEntity(e))
+			g2.Err().Op(":=").Id("rows").Dot("Scan").Call(ListFunc(
+				ScanRowIntoEntityStruct(e),
+			))
+			g2.Add(ifErrReturn)
+			g2.Id(VarName(e.PluralName())).Op("=").Append(Id(VarName(e.PluralName())), Id(e.VarName()))
+		})
+
+		g.Return(List(
+			Id(VarName(e.PluralName())),
+			Nil(),
+		))
+	})
+}
+
+// SearchFunName returns the name of the function that searches
+// instances of the given entity
+func SearchFunName(e *Entity) string {
+	return fmt.Sprintf("Search%s", e.PluralName())
+}
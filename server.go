@@ -18,22 +18,36 @@ func AddSetupServerFun(f *File) {
 
 	funName := "SetupServer"
 
-	f.Comment(fmt.Sprintf("%s glues the given schema string, and the given resolver interface and configures the http handlers that serve both the GraphQL api and the GraphQi UI", funName))
+	f.Comment(fmt.Sprintf("%s glues the given schema string, and the given resolver interface and configures the http handlers that serve both the GraphQL api and the GraphQi UI. Subscriptions are served over websockets, on the same path as the api", funName))
 	f.Func().Id(funName).Params(
 		Id("s").Id("string"),
 		Id("r").Id("interface").Values(Dict{}),
 	).BlockFunc(func(g *Group) {
 
+		// every item of a list is resolved concurrently, up to this
+		// limit, so that a whole batch can wait on the same loader.
+		// Descriptions are written as strings, rather than comments
 		g.Id("schema").Op(":=").Qual("github.com/graph-gophers/graphql-go", "MustParseSchema").Call(
 			Id("s"),
 			Id("r"),
+			Qual("github.com/graph-gophers/graphql-go", "MaxParallelism").Call(Id("LoaderMaxBatch")),
+			Qual("github.com/graph-gophers/graphql-go", "UseStringDescriptions").Call(),
 		)
 
+		// websocket connections are long lived, so they are served
+		// without the loaders of a request, which would never expire
 		g.Qual("net/http", "Handle").Call(
 			Lit("/graphql"),
-			Op("&").Qual("github.com/graph-gophers/graphql-go/relay", "Handler").Values(Dict{
-				Id("Schema"): Id("schema"),
-			}))
+			Id("SubscriptionsHandler").Call(
+				Id("schema"),
+				Id("ReplicasHandler").Call(
+					Id("LoadersHandler").Call(
+						Op("&").Qual("github.com/graph-gophers/graphql-go/relay", "Handler").Values(Dict{
+							Id("Schema"): Id("schema"),
+						}),
+					),
+				),
+			))
 
 		g.Qual("net/http", "Handle").Call(
 			Lit("/"),
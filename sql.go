@@ -13,33 +13,104 @@ import (
 func CreateSql(p *Package) error {
 	f := NewFile(p.Name)
 
-	AddNewDbFun(p.Database, f)
-	AddSqlSchemaFun(p.Model, p.Database, f)
+	AddDialectType(f)
+	AddDialectFromDsnFun(f)
+	AddNewDbFun(f)
+	AddSqlMaxParamsFun(f)
+	AddSqlSchemaFun(p.Model, f)
 
 	return f.Save(p.Filename)
 }
 
-// AddDbFun builds the function that initializes the database
-func AddNewDbFun(db string, f *File) {
+// Dialects returns the SQL dialects supported by the generated code.
+// The first one is the default, used for connection strings without
+// a known scheme
+func Dialects() []string {
+	return []string{"sqlite3", "postgres"}
+}
+
+// DialectName returns the name of the constant for the given dialect
+func DialectName(db string) string {
+	switch db {
+	case "postgres":
+		return "Postgres"
+	default:
+		return "Sqlite3"
+	}
+}
+
+// DialectSwitch produces a switch on the given dialect, with a case for
+// every supported dialect, whose body is generated by the given
+// function. The default dialect is handled by the default case
+func DialectSwitch(dialect Code, body func(db string, g *Group)) *Statement {
+	return Switch(dialect).BlockFunc(func(g *Group) {
+		for _, db := range Dialects()[1:] {
+			db := db
+			g.Case(Id(DialectName(db))).BlockFunc(func(g2 *Group) {
+				body(db, g2)
+			})
+		}
+
+		g.Default().BlockFunc(func(g2 *Group) {
+			body(Dialects()[0], g2)
+		})
+	})
+}
+
+// AddDialectType generates the type of SQL dialects, and a constant
+// for every supported one. Dialects are named after their drivers
+func AddDialectType(f *File) {
+	f.Comment("Dialect is a dialect of SQL, named after its database driver")
+	f.Type().Id("Dialect").String()
+
+	f.Const().DefsFunc(func(g *Group) {
+		for _, db := range Dialects() {
+			g.Id(DialectName(db)).Id("Dialect").Op("=").Lit(DatabaseDriver(db))
+		}
+	})
+}
+
+// AddDialectFromDsnFun generates the function that selects the dialect
+// of a database from its connection string, so that the same binary
+// can run against any supported database
+func AddDialectFromDsnFun(f *File) {
+	funName := "DialectFromDsn"
+
+	f.Comment(fmt.Sprintf("%s returns the dialect of the database with the given connection string, from its scheme. Schemes postgres:// and postgresql:// select Postgres, and any other connection string, eg. a file name, selects Sqlite3", funName))
+	f.Func().Id(funName).Params(
+		Id("conn").String(),
+	).Id("Dialect").Block(
+		Id("conn").Op("=").Qual("strings", "ToLower").Call(Id("conn")),
+		If(
+			Qual("strings", "HasPrefix").Call(Id("conn"), Lit("postgres://")).Op("||").
+				Qual("strings", "HasPrefix").Call(Id("conn"), Lit("postgresql://")),
+		).Block(
+			Return(Id(DialectName("postgres"))),
+		),
+		Return(Id(DialectName(Dialects()[0]))),
+	)
+}
+
+// AddDbFun builds the function that initializes the database. The
+// drivers of all supported dialects are linked in
+func AddNewDbFun(f *File) {
 
 	funName := "NewDb"
 
-	f.Anon(DatabaseImport(db))
+	for _, db := range Dialects() {
+		f.Anon(DatabaseImport(db))
+	}
 
-	f.Comment(fmt.Sprintf("%s initializes a new database handle", funName))
+	f.Comment(fmt.Sprintf("%s initializes a new database handle, with the driver of the dialect of the given connection string", funName))
 	f.Func().Id(funName).Params(
 		Id("conn").String(),
 	).Parens(List(
 		Op("*").Qual("database/sql", "DB"),
 		Error(),
 	)).Block(
-
-		// For now we support only Sqlite3, but here we can
-		// add parameters, flags and adopt different strategies and
-		// the rest of the application should not be aware
 		Return(
 			Id("sql").Dot("Open").Call(
-				Lit(DatabaseDriver(db)),
+				String().Call(Id("DialectFromDsn").Call(Id("conn"))),
 				Id("conn"),
 			),
 		),
@@ -69,32 +140,68 @@ func DatabaseDriver(db string) string {
 	}
 }
 
-// AddSqlSchemaFun builds the function that returns the list of SQL
-// statements that initialize the database
-func AddSqlSchemaFun(m *Model, db string, f *File) {
-	funName := "SqlSchema"
-	f.Comment(fmt.Sprintf("%s returns the database Sql schema, as a list of statements", funName))
-	f.Func().Id(funName).Params().Op("[]").Id("string").Block(
-		Return(Op("[]").Id("string").ValuesFunc(func(g *Group) {
+// AddSqlMaxParamsFun builds the function that returns the maximum
+// number of parameters that can be bound to a single SQL statement, in
+// the given dialect. Bulk operations use it to split rows in batches
+func AddSqlMaxParamsFun(f *File) {
+	funName := "SqlMaxParams"
 
-			for _, e := range m.Entities {
-				AddEntityDropTable(e, db, g)
-				AddEntityCreateTable(e, m, db, g)
-			}
+	f.Comment(fmt.Sprintf("%s returns the maximum number of parameters bound to a single statement, in the given dialect", funName))
+	f.Func().Id(funName).Params(Id("dialect").Id("Dialect")).Int().Block(
+		DialectSwitch(Id("dialect"), func(db string, g *Group) {
+			g.Return(Lit(DatabaseMaxParams(db)))
+		}),
+	)
+}
 
-			for _, e := range m.Entities {
-				AddEntityIndices(e, db, g)
-				AddEntityForeignKeyConstraints(e, m, db, g)
-			}
+// DatabaseMaxParams returns the maximum number of parameters supported
+// in a single statement, by the given database. For sqlite3, we use the
+// limit of older versions, which is the most conservative
+func DatabaseMaxParams(db string) int {
+	switch db {
+	case "postgres":
+		return 65535
+	default:
+		return 999
+	}
+}
 
-			AddExtraSqlInitialization(db, g)
+// AddSqlSchemaFun builds the function that returns the list of SQL
+// statements that initialize the database, in the given dialect
+func AddSqlSchemaFun(m *Model, f *File) {
+	funName := "SqlSchema"
+	f.Comment(fmt.Sprintf("%s returns the database Sql schema in the given dialect, as a list of statements", funName))
+	f.Func().Id(funName).Params(Id("dialect").Id("Dialect")).Op("[]").Id("string").Block(
+		DialectSwitch(Id("dialect"), func(db string, g *Group) {
+			g.Return(Op("[]").Id("string").ValuesFunc(func(g2 *Group) {
+				for _, e := range m.Entities {
+					AddEntityDropTable(e, db, g2)
+					AddEntityCreateTable(e, m, db, g2)
+				}
+
+				for _, e := range m.Entities {
+					AddEntityIndices(e, db, g2)
+					AddEntityForeignKeyConstraints(e, m, db, g2)
+					AddEntityComments(e, db, g2)
+
+					if len(SearchableAttributes(e)) > 0 {
+						AddEntitySearchIndex(e, db, g2)
+					}
+				}
+
+				AddExtraSqlInitialization(db, g2)
+			}))
 		}),
-		))
+	)
 }
 
 // AddEntityDropTable adds a DROP TABLE statement to the schema, for the
-// given entity
+// given entity, and its search table if any
 func AddEntityDropTable(e *Entity, db string, g *Group) {
+	if db == "sqlite3" && len(SearchableAttributes(e)) > 0 {
+		g.Lit(fmt.Sprintf("DROP TABLE IF EXISTS %s", SearchTableName(e)))
+	}
+
 	g.Lit(DropTableStatement(e, db))
 }
 
@@ -115,14 +222,20 @@ func AddEntityCreateTable(e *Entity, m *Model, db string, g *Group) {
 
 	colsChunks := []string{}
 	for _, a := range e.Attributes {
-		colsChunks = append(colsChunks, TableColumnFromAttribute(a))
+		colsChunks = append(colsChunks, TableColumnFromAttribute(e, a, db))
 	}
 	for _, r := range e.Relations {
 		if r.HasModifier("belongsTo") || r.HasModifier("hasOne") {
-			colsChunks = append(colsChunks, TableColumnFromRelation(r))
+			colsChunks = append(colsChunks, TableColumnFromRelation(r, m, db))
 		}
 	}
 
+	// postgres keeps the search vector up to date in a generated
+	// column
+	if db != "sqlite3" && len(SearchableAttributes(e)) > 0 {
+		colsChunks = append(colsChunks, TableColumnForSearch(e))
+	}
+
 	// sqlite3 does not support ALTER table statements,
 	// so we need to inline forein keys inside the table definition
 	if db == "sqlite3" {
@@ -179,6 +292,38 @@ func AddEntityForeignKeyConstraints(e *Entity, m *Model, db string, g *Group) {
 
 }
 
+// AddEntityComments adds COMMENT ON statements to the schema, with the
+// descriptions of the given entity, and of its attributes and
+// relations stored as columns. Sqlite3 does not support comments
+func AddEntityComments(e *Entity, db string, g *Group) {
+	if db == "sqlite3" {
+		return
+	}
+
+	tableName := TableName(e)
+
+	if e.Description != "" {
+		g.Lit(fmt.Sprintf("COMMENT ON TABLE %s IS %s", tableName, SqlString(e.Description)))
+	}
+
+	for _, a := range e.Attributes {
+		if a.Description != "" {
+			g.Lit(fmt.Sprintf("COMMENT ON COLUMN %s.%s IS %s", tableName, AttributeColumnName(a), SqlString(a.Description)))
+		}
+	}
+
+	for _, r := range e.Relations {
+		if (r.HasModifier("belongsTo") || r.HasModifier("hasOne")) && r.Description != "" {
+			g.Lit(fmt.Sprintf("COMMENT ON COLUMN %s.%s IS %s", tableName, RelationColumnName(r), SqlString(r.Description)))
+		}
+	}
+}
+
+// SqlString quotes the given text as a SQL string literal
+func SqlString(s string) string {
+	return fmt.Sprintf("'%s'", strings.Replace(strings.TrimSpace(s), "'", "''", -1))
+}
+
 // AddExtraSqlInitialization adds extra database initialization steps
 func AddExtraSqlInitialization(db string, g *Group) {
 	switch db {
@@ -193,25 +338,73 @@ func AddExtraSqlInitialization(db string, g *Group) {
 }
 
 // TableColumnFromAttribute builds the column specification for the
-// given attribute.
-func TableColumnFromAttribute(a *Attribute) string {
-	dataType := AttributeSqlType(a)
+// given attribute of the given entity, in the given dialect.
+func TableColumnFromAttribute(e *Entity, a *Attribute, db string) string {
+	if a.Name == "ID" {
+		return TableColumnForID(e, db)
+	}
+
+	dataType := AttributeSqlType(a, db)
 	spec := fmt.Sprintf("%s %s", AttributeColumnName(a), dataType)
 	if dataType == "varchar" {
 		spec = fmt.Sprintf("%s NOT NULL", spec)
 	}
 
-	if a.Name == "ID" {
-		spec = fmt.Sprintf("%s PRIMARY KEY", spec)
+	return spec
+}
+
+// TableColumnForID builds the column specification for the primary
+// key of the given entity, in the given dialect. IDs from a sequence
+// are assigned by the database when rows are inserted
+func TableColumnForID(e *Entity, db string) string {
+	spec := fmt.Sprintf("id %s", IDSqlType(e, db))
+
+	if e.GeneratesIDInDatabase() {
+		if db == "sqlite3" {
+			return fmt.Sprintf("%s PRIMARY KEY AUTOINCREMENT", spec)
+		}
+
+		return fmt.Sprintf("%s GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY", spec)
 	}
 
-	return spec
+	if IDSqlType(e, db) == "varchar" {
+		spec = fmt.Sprintf("%s NOT NULL", spec)
+	}
+
+	return fmt.Sprintf("%s PRIMARY KEY", spec)
+}
+
+// IDSqlType returns the SQL datatype of the IDs of the given entity,
+// in the given dialect, according to its ID strategy.
+func IDSqlType(e *Entity, db string) string {
+	switch e.IDStrategy {
+	case "uuidv4", "uuidv7":
+		if db == "sqlite3" {
+			return "varchar"
+		}
+		return "uuid"
+
+	case "ulid":
+		return "char(26)"
+
+	case "ksuid":
+		return "char(27)"
+
+	case "sequence":
+		if db == "sqlite3" {
+			return "integer"
+		}
+		return "bigint"
+
+	default:
+		return "varchar"
+	}
 }
 
 // TableColumnFromRelation builds the column specification for the given
-// relation.
-func TableColumnFromRelation(r *Relation) string {
-	return fmt.Sprintf("%s %s", RelationColumnName(r), RelationSqlType(r))
+// relation, in the given dialect.
+func TableColumnFromRelation(r *Relation, m *Model, db string) string {
+	return fmt.Sprintf("%s %s", RelationColumnName(r), RelationSqlType(r, m, db))
 }
 
 // ForeignKeyContraintName returns the name of the foreign key for the
@@ -255,20 +448,28 @@ func RelationColumnName(r *Relation) string {
 	return fmt.Sprintf("%s_id", strings.ToLower(strcase.ToSnake(r.Alias())))
 }
 
-// AttributeSqlType returns the SQL datatype for an attribute.
-func AttributeSqlType(a *Attribute) string {
+// AttributeSqlType returns the SQL datatype for an attribute, in the
+// given dialect. Numbers are stored in numeric columns, so that the
+// database compares and sorts them as numbers
+func AttributeSqlType(a *Attribute, db string) string {
 	switch a.Type {
 
 	case "Int":
 		return "integer"
 
+	case "Float":
+		if db == "sqlite3" {
+			return "real"
+		}
+		return "double precision"
+
 	default:
 		return "varchar"
 	}
 }
 
-// RelationSqlType returns the SQL datatype for a relation. We will
-// always use the varchar data type for references between entities.
-func RelationSqlType(r *Relation) string {
-	return "varchar"
+// RelationSqlType returns the SQL datatype for a relation, which is
+// the datatype of the IDs of the related entity.
+func RelationSqlType(r *Relation, m *Model, db string) string {
+	return IDSqlType(m.EntityForNameOrPanic(r.Entity), db)
 }
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	. "github.com/dave/jennifer/jen"
+)
+
+// listStatementFormat is the format of SELECT statements for a list of
+// instances, given the query, the ORDER BY expressions, and the
+// placeholders for the limit and the offset
+const listStatementFormat = "%s ORDER BY %s LIMIT $%v OFFSET $%v"
+
+// pageStatementFormat is the format of SELECT statements for a page of
+// instances, given the query, the ORDER BY expressions, and the
+// placeholder for the limit
+const pageStatementFormat = "%s ORDER BY %s LIMIT $%v"
+
+// CreateStatements generates a Golang file that contains the registry
+// of prepared statements shared by all repository functions
+func CreateStatements(p *Package) error {
+	f := NewFile(p.Name)
+
+	f.PackageComment(fmt.Sprintf("%s contains all the library code for the platform", p.Name))
+	f.PackageComment("This file contains the registry of prepared statements")
+	f.PackageComment(" ** THIS CODE IS MACHINE GENERATED. DO NOT EDIT MANUALLY ** ")
+
+	f.ImportAlias("database/sql", "sql")
+
+	AddSqlMaxStatementsConst(f)
+	AddStatementsStruct(f)
+	AddStmtStruct(f)
+	AddPrepareStatementsFun(f)
+	AddStatementsPrepareFun(f)
+	AddStatementsDialectFun(f)
+	AddStatementsCloseFun(f)
+	AddSqlStatementsFun(p.Model, f)
+
+	return f.Save(p.Filename)
+}
+
+// AddSqlMaxStatementsConst declares the maximum number of statements
+// kept in the registry. Queries built at runtime, eg. with filters,
+// vary with the request, so the registry must not grow unbounded
+func AddSqlMaxStatementsConst(f *File) {
+	name := "SqlMaxStatements"
+
+	f.Comment(fmt.Sprintf("%s is the maximum number of statements kept prepared. Queries beyond it are prepared and closed on every call", name))
+	f.Const().Id(name).Op("=").Lit(1000)
+}
+
+// AddStatementsStruct generates the registry of prepared statements
+func AddStatementsStruct(f *File) {
+	f.Comment("Statements is a registry of prepared statements, keyed by their SQL. The statements returned by SqlStatements are prepared at startup, and any other query the first time it is run")
+	f.Type().Id("Statements").Struct(
+		Id("db").Op("*").Qual("database/sql", "DB"),
+		Id("dialect").Id("Dialect"),
+		Id("mu").Qual("sync", "RWMutex"),
+		Id("stmts").Map(String()).Op("*").Qual("database/sql", "Stmt"),
+	)
+}
+
+// AddStmtStruct generates the statement handed to repository
+// functions. Closing it leaves the statements of the registry open, so
+// that repository functions can close every statement they use
+func AddStmtStruct(f *File) {
+	f.Comment("Stmt is a statement prepared for a single call. Closing it releases statements bound to a transaction, or prepared for that call only, but leaves statements of the registry open")
+	f.Type().Id("Stmt").Struct(
+		Op("*").Qual("database/sql", "Stmt"),
+		Id("shared").Bool(),
+	)
+
+	f.Comment("Close closes the statement, unless it is shared by the registry")
+	f.Func().Parens(Id("s").Op("*").Id("Stmt")).Id("Close").Params().Error().Block(
+		If(Id("s").Dot("shared")).Block(
+			Return(Nil()),
+		),
+		Return(Id("s").Dot("Stmt").Dot("Close").Call()),
+	)
+}
+
+// AddPrepareStatementsFun generates the function that builds the
+// registry at startup, so that statements that cannot be prepared,
+// eg. because the schema is out of date, are reported before any
+// request is served
+func AddPrepareStatementsFun(f *File) {
+	funName := "PrepareStatements"
+
+	f.Comment(fmt.Sprintf("%s prepares all statements returned by SqlStatements on the given database, in the given dialect. An error is returned for the first statement that cannot be prepared", funName))
+	f.Func().Id(funName).Params(
+		Id("ctx").Qual("context", "Context"),
+		Id("db").Op("*").Qual("database/sql", "DB"),
+		Id("dialect").Id("Dialect"),
+	).Parens(List(
+		Op("*").Id("Statements"),
+		Error(),
+	)).BlockFunc(func(g *Group) {
+		g.Id("s").Op(":=").Op("&").Id("Statements").Values(Dict{
+			Id("db"):      Id("db"),
+			Id("dialect"): Id("dialect"),
+			Id("stmts"):   Map(String()).Op("*").Qual("database/sql", "Stmt").Values(),
+		})
+
+		g.For(List(Id("_"), Id("query")).Op(":=").Range().Id("SqlStatements").Call(Id("dialect"))).Block(
+			List(Id("stmt"), Err()).Op(":=").Id("db").Dot("PrepareContext").Call(Id("ctx"), Id("query")),
+			If(Err().Op("!=").Nil()).Block(
+				Id("s").Dot("Close").Call(),
+				Return(Nil(), Qual("fmt", "Errorf").Call(Lit("Error preparing statement %s: %v"), Id("query"), Err())),
+			),
+			Id("s").Dot("stmts").Index(Id("query")).Op("=").Id("stmt"),
+		)
+
+		g.Return(Id("s"), Nil())
+	})
+}
+
+// AddStatementsPrepareFun generates the method that returns the
+// statement for a query, to be run on the given database handle.
+// Statements of the registry are prepared on the database, and bound
+// to transactions with tx.Stmt, so they are reused by both
+func AddStatementsPrepareFun(f *File) {
+	f.Comment("Prepare returns a statement for the given query, to be run on the given handle. Statements of the registry are reused, and bound to the handle when it is a transaction. A nil registry prepares a new statement on every call")
+	f.Func().Parens(Id("s").Op("*").Id("Statements")).Id("Prepare").Params(
+		Id("ctx").Qual("context", "Context"),
+		Id("db").Id("DBTX"),
+		Id("query").String(),
+	).Parens(List(
+		Op("*").Id("Stmt"),
+		Error(),
+	)).BlockFunc(func(g *Group) {
+		g.If(Id("s").Op("!=").Nil()).BlockFunc(func(g2 *Group) {
+			g2.List(Id("stmt"), Err()).Op(":=").Id("s").Dot("stmt").Call(Id("ctx"), Id("query"))
+			g2.If(Err().Op("!=").Nil()).Block(
+				Return(Nil(), Err()),
+			)
+
+			g2.If(Id("stmt").Op("!=").Nil()).Block(
+				Switch(Id("h").Op(":=").Id("db").Assert(Type())).Block(
+					Case(Op("*").Qual("database/sql", "Tx")).Block(
+						Return(Op("&").Id("Stmt").Values(Dict{
+							Id("Stmt"): Id("h").Dot("StmtContext").Call(Id("ctx"), Id("stmt")),
+						}), Nil()),
+					),
+					Case(Op("*").Qual("database/sql", "DB")).Block(
+						If(Id("h").Op("==").Id("s").Dot("db")).Block(
+							Return(Op("&").Id("Stmt").Values(Dict{
+								Id("Stmt"):   Id("stmt"),
+								Id("shared"): True(),
+							}), Nil()),
+						),
+					),
+				),
+			)
+		})
+
+		g.List(Id("stmt"), Err()).Op(":=").Id("db").Dot("PrepareContext").Call(Id("ctx"), Id("query"))
+		g.If(Err().Op("!=").Nil()).Block(
+			Return(Nil(), Err()),
+		)
+
+		g.Return(Op("&").Id("Stmt").Values(Dict{Id("Stmt"): Id("stmt")}), Nil())
+	})
+
+	f.Comment("stmt returns the statement of the registry for the given query, preparing it if there is room left. A nil statement is returned when the registry is full")
+	f.Func().Parens(Id("s").Op("*").Id("Statements")).Id("stmt").Params(
+		Id("ctx").Qual("context", "Context"),
+		Id("query").String(),
+	).Parens(List(
+		Op("*").Qual("database/sql", "Stmt"),
+		Error(),
+	)).BlockFunc(func(g *Group) {
+		g.Id("s").Dot("mu").Dot("RLock").Call()
+		g.List(Id("stmt"), Id("ok")).Op(":=").Id("s").Dot("stmts").Index(Id("query"))
+		g.Id("s").Dot("mu").Dot("RUnlock").Call()
+		g.If(Id("ok")).Block(
+			Return(Id("stmt"), Nil()),
+		)
+
+		g.Id("s").Dot("mu").Dot("Lock").Call()
+		g.Defer().Id("s").Dot("mu").Dot("Unlock").Call()
+
+		// another call may have prepared it in the meantime
+		g.If(List(Id("stmt"), Id("ok")).Op(":=").Id("s").Dot("stmts").Index(Id("query")), Id("ok")).Block(
+			Return(Id("stmt"), Nil()),
+		)
+
+		g.If(Len(Id("s").Dot("stmts")).Op(">=").Id("SqlMaxStatements")).Block(
+			Return(Nil(), Nil()),
+		)
+
+		g.List(Id("stmt"), Err()).Op(":=").Id("s").Dot("db").Dot("PrepareContext").Call(Id("ctx"), Id("query"))
+		g.If(Err().Op("!=").Nil()).Block(
+			Return(Nil(), Err()),
+		)
+
+		g.Id("s").Dot("stmts").Index(Id("query")).Op("=").Id("stmt")
+		g.Return(Id("stmt"), Nil())
+	})
+}
+
+// AddStatementsDialectFun generates the method that returns the
+// dialect of the statements, for repository functions whose SQL
+// depends on it
+func AddStatementsDialectFun(f *File) {
+	f.Comment(fmt.Sprintf("Dialect returns the dialect of the statements. A nil registry has the %s dialect, like connection strings without a known scheme", DialectName(Dialects()[0])))
+	f.Func().Parens(Id("s").Op("*").Id("Statements")).Id("Dialect").Params().Id("Dialect").Block(
+		If(Id("s").Op("==").Nil()).Block(
+			Return(Id(DialectName(Dialects()[0]))),
+		),
+		Return(Id("s").Dot("dialect")),
+	)
+}
+
+// AddStatementsCloseFun generates the method that closes all
+// statements of the registry
+func AddStatementsCloseFun(f *File) {
+	f.Comment("Close closes all statements of the registry")
+	f.Func().Parens(Id("s").Op("*").Id("Statements")).Id("Close").Params().Error().BlockFunc(func(g *Group) {
+		g.Id("s").Dot("mu").Dot("Lock").Call()
+		g.Defer().Id("s").Dot("mu").Dot("Unlock").Call()
+
+		g.Var().Id("first").Error()
+		g.For(List(Id("query"), Id("stmt")).Op(":=").Range().Id("s").Dot("stmts")).Block(
+			If(Err().Op(":=").Id("stmt").Dot("Close").Call(), Err().Op("!=").Nil().Op("&&").Id("first").Op("==").Nil()).Block(
+				Id("first").Op("=").Err(),
+			),
+			Delete(Id("s").Dot("stmts"), Id("query")),
+		)
+
+		g.Return(Id("first"))
+	})
+}
+
+// AddSqlStatementsFun generates the function that lists the statements
+// to prepare at startup. These are all statements that do not depend
+// on the request, and the list queries with neither filters nor sort
+// orders. Only search statements differ between dialects
+func AddSqlStatementsFun(m *Model, f *File) {
+	funName := "SqlStatements"
+
+	f.Comment(fmt.Sprintf("%s returns the statements, in the given dialect, that are prepared at startup", funName))
+	f.Func().Id(funName).Params(Id("dialect").Id("Dialect")).Index().String().BlockFunc(func(g *Group) {
+		g.Id("stmts").Op(":=").Index().String().ValuesFunc(func(g2 *Group) {
+			// statements may coincide, eg. counts and aggregates of
+			// entities without numeric attributes
+			seen := map[string]bool{}
+			for _, e := range m.Entities {
+				for _, stmt := range EntityStatements(e) {
+					if !seen[stmt] {
+						seen[stmt] = true
+						g2.Line().Lit(stmt)
+					}
+				}
+			}
+			g2.Line()
+		})
+
+		if ModelSupportsSearch(m) {
+			g.Add(DialectSwitch(Id("dialect"), func(db string, g2 *Group) {
+				g2.Id("stmts").Op("=").Append(Id("stmts"), ListFunc(func(g3 *Group) {
+					for _, e := range m.Entities {
+						if SupportsSearch(e) {
+							g3.Line().Lit(SearchPageStatement(e, db))
+						}
+					}
+					g3.Line()
+				}))
+			}))
+		}
+
+		g.Return(Id("stmts"))
+	})
+}
+
+// EntityStatements returns the statements of the given entity that do
+// not depend on the request, in any dialect. These must be built
+// exactly like the repository functions build them, or they would
+// never be reused
+func EntityStatements(e *Entity) []string {
+	stmts := []string{}
+
+	if e.SupportsOperation("create") {
+		stmts = append(stmts, InsertEntityStatement(e))
+	}
+
+	if e.SupportsOperation("update") {
+		stmts = append(stmts, UpdateStatement(e))
+	}
+
+	if e.SupportsOperation("delete") {
+		stmts = append(stmts, SelectByColumnFromStatement(e, "id"), DeleteStatement(e))
+	}
+
+	if e.SupportsOperation("upsert") {
+		stmts = append(stmts, UpsertStatement(e))
+	}
+
+	if !e.SupportsOperation("find") {
+		return stmts
+	}
+
+	lists := func(query string, args int) {
+		stmts = append(stmts, ListStatement(e, query, args))
+		if e.SupportsCursorPagination() {
+			stmts = append(stmts, PageStatement(e, query, args))
+		}
+	}
+
+	for _, a := range e.Attributes {
+		if a.HasModifier("unique") && a.HasModifier("indexed") {
+			stmts = append(stmts, SelectByColumnFromAttributeStatement(e, a))
+		} else if a.HasModifier("indexed") {
+			lists(SelectByColumnFromAttributeStatement(e, a), 1)
+		}
+	}
+
+	for _, r := range e.Relations {
+		if r.HasModifier("hasOne") || r.HasModifier("belongsTo") {
+			lists(SelectByColumnFromRelationStatement(e, r), 1)
+		}
+	}
+
+	lists(SelectAllStatement(e), 0)
+
+	stmts = append(stmts, CountStatement(e))
+	stmts = append(stmts, fmt.Sprintf("SELECT %s FROM %s", strings.Join(AggregateExpressions(e), ", "), TableName(e)))
+
+	return stmts
+}
+
+// ListStatement returns the SELECT statement for a list of instances
+// of the given entity, in the default order, given the query and the
+// number of its arguments
+func ListStatement(e *Entity, query string, args int) string {
+	order := fmt.Sprintf("%s %s", AttributeColumnName(e.PreferredSort()), SortDirections()[0])
+	return fmt.Sprintf(listStatementFormat, query, order, args+1, args+2)
+}
+
+// PageStatement returns the SELECT statement for a page of instances
+// of the given entity, in the default order, given the query and the
+// number of its arguments. The default order is completed with the id,
+// like KeysetOrders does
+func PageStatement(e *Entity, query string, args int) string {
+	id := &Attribute{Name: "ID"}
+	orders := []string{fmt.Sprintf("%s %s", AttributeColumnName(e.PreferredSort()), SortDirections()[0])}
+	if AttributeSortField(e.PreferredSort()) != AttributeSortField(id) {
+		orders = append(orders, fmt.Sprintf("%s %s", AttributeColumnName(id), SortDirections()[0]))
+	}
+
+	return fmt.Sprintf(pageStatementFormat, query, strings.Join(orders, ", "), args+1)
+}
+
+// CountStatement returns the SELECT statement that counts all
+// instances of the given entity
+func CountStatement(e *Entity) string {
+	return fmt.Sprintf("SELECT COUNT(*) FROM %s", TableName(e))
+}
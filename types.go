@@ -11,8 +11,10 @@ import (
 
 // Model describes the application model
 type Model struct {
-	Types    []*UDType
-	Entities []*Entity
+	Types      []*UDType
+	Entities   []*Entity
+	IDStrategy string `yaml:"idStrategy"`
+	Relay      bool
 }
 
 // ReadModelFromFile reads a model from a yaml file in the local
@@ -28,7 +30,9 @@ func ReadModelFromFile(path string) (*Model, error) {
 	m.ImplementTraits()
 	m.ResolveTypes()
 	m.ResolveOperations()
+	m.ResolveIDStrategies()
 	m.ResolveRelations()
+	m.ResolveGlobalIDs()
 	return m, err
 }
 
@@ -38,7 +42,6 @@ type Package struct {
 	Name     string
 	Filename string
 	Model    *Model
-	Database string
 }
 
 // ImplementTraits traverses all entities in the model, and for each
@@ -56,9 +59,33 @@ func (m *Model) ImplementTraits() {
 func (m *Model) ResolveOperations() {
 	for _, e := range m.Entities {
 		e.ResolveOperations()
+		e.ResolvePagination()
+		e.ResolveSearch()
 	}
 }
 
+// ResolveIDStrategies traverses all entities in the model, and for
+// each entity with an ID, it ensures that the ID strategy is valid.
+// Entities that define none use the one of the model, or let clients
+// supply IDs by default
+func (m *Model) ResolveIDStrategies() {
+	for _, e := range m.Entities {
+		e.ResolveIDStrategy(m.IDStrategy)
+	}
+}
+
+// SupportsCursorPagination returns whether any entity in the model
+// supports cursor based pagination
+func (m *Model) SupportsCursorPagination() bool {
+	for _, e := range m.Entities {
+		if e.SupportsCursorPagination() {
+			return true
+		}
+	}
+
+	return false
+}
+
 // ResolveRelations traverses all relations and
 // resolves the variable name for each relation.
 func (m *Model) ResolveRelations() {
@@ -66,8 +93,30 @@ func (m *Model) ResolveRelations() {
 		for _, r := range e.Relations {
 			r.Variable = r.ResolveVariable(m)
 			r.Name = r.ResolveAlias(m)
+			r.target = m.EntityForNameOrPanic(r.Entity)
+		}
+	}
+}
+
+// ResolveGlobalIDs traverses all entities in the model, and marks the
+// ones whose IDs are exposed as Relay global IDs, which are all the
+// entities with an ID, if the model follows Relay conventions
+func (m *Model) ResolveGlobalIDs() {
+	for _, e := range m.Entities {
+		e.globalIDs = m.Relay && e.HasID()
+	}
+}
+
+// ImplementsNode returns whether any entity in the model implements
+// the Relay Node interface
+func (m *Model) ImplementsNode() bool {
+	for _, e := range m.Entities {
+		if e.ImplementsNode() {
+			return true
 		}
 	}
+
+	return false
 }
 
 // EntityForName returns the entity of the given name, in the model, or
@@ -117,6 +166,7 @@ func (m *Model) ResolveTypes() {
 				if t2 := m.TypeForName(v); t2 != nil {
 					for _, v2 := range t2.Values {
 						newValues = append(newValues, v2)
+						t.describeValue(v2, t2.ValueDescription(v2))
 					}
 					newType = "String"
 				} else {
@@ -127,6 +177,14 @@ func (m *Model) ResolveTypes() {
 			t.Values = newValues
 		}
 	}
+
+	for _, e := range m.Entities {
+		for _, a := range e.Attributes {
+			if t := m.TypeForName(a.Type); t != nil && t.IsEnum() {
+				a.enum = t
+			}
+		}
+	}
 }
 
 // VarName converts the given name, into a golang variable name. The
@@ -138,9 +196,80 @@ func VarName(name string) string {
 // UDType represents a user defined type. This will allow for
 // extensibility
 type UDType struct {
-	Name   string
-	Type   string
-	Values []string
+	Name        string
+	Type        string
+	Description string
+	Values      []string
+
+	valueDescriptions map[string]string
+}
+
+// UDTypeValue is a value of a user defined type, as written in the
+// model. It is either a plain name, or a name with a description
+type UDTypeValue struct {
+	Name        string
+	Description string
+}
+
+// UnmarshalYAML reads a value of a user defined type, from either a
+// plain name or a mapping
+func (v *UDTypeValue) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := unmarshal(&v.Name); err == nil {
+		return nil
+	}
+
+	type plain UDTypeValue
+	return unmarshal((*plain)(v))
+}
+
+// UnmarshalYAML reads a user defined type, keeping the names of its
+// values, and their descriptions apart
+func (t *UDType) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	raw := struct {
+		Name        string
+		Type        string
+		Description string
+		Values      []*UDTypeValue
+	}{}
+
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+
+	t.Name = raw.Name
+	t.Type = raw.Type
+	t.Description = raw.Description
+	t.Values = []string{}
+	for _, v := range raw.Values {
+		t.Values = append(t.Values, v.Name)
+		t.describeValue(v.Name, v.Description)
+	}
+
+	return nil
+}
+
+// IsEnum returns whether the type is an enum, which is a string type
+// restricted to a list of values
+func (t *UDType) IsEnum() bool {
+	return t.Type == "String" && len(t.Values) > 0
+}
+
+// ValueDescription returns the description of the given value of the
+// type, or an empty string if it has none
+func (t *UDType) ValueDescription(v string) string {
+	return t.valueDescriptions[v]
+}
+
+// describeValue sets the description of the given value of the type
+func (t *UDType) describeValue(v string, description string) {
+	if description == "" {
+		return
+	}
+
+	if t.valueDescriptions == nil {
+		t.valueDescriptions = map[string]string{}
+	}
+	t.valueDescriptions[v] = description
 }
 
 // Entity represents a persisted datatype, such as an Organization, a
@@ -160,14 +289,20 @@ type UDType struct {
 // - owner: adds an Onwer relation
 //
 type Entity struct {
-	Name       string
-	Variable   string
-	Plural     string
-	Attributes []*Attribute
-	Relations  []*Relation
-	Traits     []string
-	Hooks      map[string][]string
-	Operations []string
+	Name        string
+	Variable    string
+	Plural      string
+	Description string
+	Attributes  []*Attribute
+	Relations   []*Relation
+	Traits      []string
+	Hooks       map[string][]string
+	Operations  []string
+	UpsertKey   string `yaml:"upsertKey"`
+	Pagination  string
+	IDStrategy  string `yaml:"idStrategy"`
+
+	globalIDs bool
 }
 
 // VarName returns the variable name representation for the
@@ -191,6 +326,12 @@ func (e *Entity) PluralName() string {
 }
 
 var entityOps = []string{
+	"create", "update", "delete", "find", "upsert",
+}
+
+// defaultEntityOps are the operations assigned to entities that do not
+// define any
+var defaultEntityOps = []string{
 	"create", "update", "delete", "find",
 }
 
@@ -198,7 +339,7 @@ var entityOps = []string{
 // operations defined
 func (e *Entity) ResolveOperations() {
 	if len(e.Operations) == 0 {
-		e.Operations = entityOps
+		e.Operations = defaultEntityOps
 		return
 	}
 
@@ -210,6 +351,158 @@ func (e *Entity) ResolveOperations() {
 		}
 
 	}
+
+	if e.SupportsOperation("upsert") {
+		a := e.UpsertAttribute()
+		if a.Name != "ID" && !a.HasModifier("unique") {
+			panic(fmt.Sprintf("Upsert key %s in %s is not unique", a.Name, e.Name))
+		}
+	}
+}
+
+// entityPaginations are the supported styles of pagination of list
+// queries. Offset pagination is used by default
+var entityPaginations = []string{
+	"offset", "cursor", "both",
+}
+
+// ResolvePagination ensures that the entity has a valid pagination
+// style defined
+func (e *Entity) ResolvePagination() {
+	if len(e.Pagination) == 0 {
+		e.Pagination = entityPaginations[0]
+		return
+	}
+
+	for _, p := range entityPaginations {
+		if p == e.Pagination {
+			return
+		}
+	}
+
+	panic(fmt.Sprintf("Invalid pagination %s in %s", e.Pagination, e.Name))
+}
+
+// ResolveSearch ensures that only text attributes of the entity are
+// indexed for full text search
+func (e *Entity) ResolveSearch() {
+	for _, a := range SearchableAttributes(e) {
+		for _, db := range Dialects() {
+			if AttributeSqlType(a, db) != "varchar" {
+				panic(fmt.Sprintf("Searchable attribute %s in %s is not text", a.Name, e.Name))
+			}
+		}
+	}
+}
+
+// idStrategies are the supported ways of assigning an ID to new
+// instances. Clients supply IDs by default
+var idStrategies = []string{
+	"client", "uuidv4", "uuidv7", "ulid", "ksuid", "sequence",
+}
+
+// ResolveIDStrategy ensures that the entity has a valid ID strategy
+// defined, falling back to the given one of the model
+func (e *Entity) ResolveIDStrategy(fallback string) {
+	if !e.HasID() {
+		if len(e.IDStrategy) > 0 {
+			panic(fmt.Sprintf("ID strategy %s in %s requires an ID", e.IDStrategy, e.Name))
+		}
+		return
+	}
+
+	if len(e.IDStrategy) == 0 {
+		e.IDStrategy = fallback
+	}
+
+	if len(e.IDStrategy) == 0 {
+		e.IDStrategy = idStrategies[0]
+	}
+
+	valid := false
+	for _, s := range idStrategies {
+		if s == e.IDStrategy {
+			valid = true
+		}
+	}
+
+	if !valid {
+		panic(fmt.Sprintf("Invalid ID strategy %s in %s", e.IDStrategy, e.Name))
+	}
+
+	if e.GeneratesIDInDatabase() && e.SupportsOperation("upsert") && e.UpsertAttribute().Name == "ID" {
+		panic(fmt.Sprintf("Upsert key ID in %s is assigned by the database", e.Name))
+	}
+}
+
+// HasID returns whether the entity has an ID attribute
+func (e *Entity) HasID() bool {
+	for _, a := range e.Attributes {
+		if a.Name == "ID" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// UsesGlobalIDs returns whether the IDs of the entity are exposed as
+// Relay global IDs, which qualify them with the name of the entity
+func (e *Entity) UsesGlobalIDs() bool {
+	return e.globalIDs
+}
+
+// ImplementsNode returns whether the entity implements the Relay Node
+// interface, so that its instances can be found by global ID
+func (e *Entity) ImplementsNode() bool {
+	return e.UsesGlobalIDs() && e.SupportsOperation("find")
+}
+
+// GeneratesID returns whether the IDs of new instances of the entity
+// are assigned by the server, rather than supplied by clients
+func (e *Entity) GeneratesID() bool {
+	return e.HasID() && e.IDStrategy != "client"
+}
+
+// GeneratesIDInDatabase returns whether the IDs of new instances of
+// the entity are assigned by the database, from a sequence
+func (e *Entity) GeneratesIDInDatabase() bool {
+	return e.IDStrategy == "sequence"
+}
+
+// SupportsOffsetPagination returns whether list queries of the entity
+// take limit and offset arguments
+func (e *Entity) SupportsOffsetPagination() bool {
+	return e.Pagination == "offset" || e.Pagination == "both"
+}
+
+// SupportsCursorPagination returns whether list queries of the entity
+// return Relay connections
+func (e *Entity) SupportsCursorPagination() bool {
+	return e.Pagination == "cursor" || e.Pagination == "both"
+}
+
+// UpsertAttribute returns the attribute used to detect conflicts when
+// upserting instances of this entity. This is the attribute named by
+// the upsert key, or the first unique attribute, or the ID
+func (e *Entity) UpsertAttribute() *Attribute {
+	if len(e.UpsertKey) > 0 {
+		for _, a := range e.Attributes {
+			if a.Name == e.UpsertKey {
+				return a
+			}
+		}
+
+		panic(fmt.Sprintf("Upsert key %s not found in %s", e.UpsertKey, e.Name))
+	}
+
+	for _, a := range e.Attributes {
+		if a.Name != "ID" && a.HasModifier("unique") {
+			return a
+		}
+	}
+
+	return &Attribute{Name: "ID"}
 }
 
 // SupportsOperation returns whether the given operation is supported by
@@ -354,6 +647,18 @@ func (e *Entity) HasGenerators() bool {
 	return false
 }
 
+// HasEnums returns whether this entity has attributes whose type is an
+// enum of the model
+func (e *Entity) HasEnums() bool {
+	for _, a := range e.Attributes {
+		if a.Enum() != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
 // RelationForEntityOrPanic looks for a relation that has
 // the given entity as target entity.
 func (e *Entity) RelationForEntityOrPanic(target *Entity) *Relation {
@@ -392,10 +697,14 @@ func EntityInitialization(e *Entity) *Statement {
 //			 across all instances of the entity
 // - required: indicate the attribute is not nullable
 // - indexed: indicate an database index should be created on this field
+// - searchable: indicate the attribute is indexed for full text search
 type Attribute struct {
-	Name      string
-	Type      string
-	Modifiers []string
+	Name        string
+	Type        string
+	Description string
+	Modifiers   []string
+
+	enum *UDType
 }
 
 // WithType defines the datatype for the given attribute
@@ -426,6 +735,12 @@ func (a *Attribute) VarName() string {
 	return strings.ToLower(a.Name)
 }
 
+// Enum returns the enum type of the attribute, or nil if its type is
+// not an enum
+func (a *Attribute) Enum() *UDType {
+	return a.enum
+}
+
 // Relation represents a relation to a foreign entity.
 //
 // The relation can be aliased with a user defined name,
@@ -439,10 +754,13 @@ func (a *Attribute) VarName() string {
 // - hasOne
 //
 type Relation struct {
-	Name      string
-	Variable  string
-	Entity    string
-	Modifiers []string
+	Name        string
+	Variable    string
+	Entity      string
+	Description string
+	Modifiers   []string
+
+	target *Entity
 }
 
 // Alias returns the name of the relation. If it has an alias,
@@ -527,6 +845,19 @@ func (r *Relation) VarName() string {
 	return r.Variable
 }
 
+// SupportsNestedCreate returns whether the entity pointed by the
+// relation can be created along with the entity that holds it. This is
+// the case for relations stored as a column, that are not generated,
+// when the related entity supports the create operation, and has a
+// create input
+func (r *Relation) SupportsNestedCreate() bool {
+	if r.target == nil || r.HasModifier("generated") {
+		return false
+	}
+
+	return (r.HasModifier("belongsTo") || r.HasModifier("hasOne")) && r.target.SupportsOperation("create") && HasCreateInput(r.target)
+}
+
 // HasModifier returns true, if the relation has the given modifier
 func (r *Relation) HasModifier(m string) bool {
 	for _, mod := range r.Modifiers {
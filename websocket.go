@@ -0,0 +1,366 @@
+package main
+
+import (
+	"fmt"
+
+	. "github.com/dave/jennifer/jen"
+)
+
+// CreateWebsocket generates a Golang file that contains the websocket
+// transport of the Graphql api, which implements the graphql-ws
+// protocol, so that clients can subscribe to changes
+func CreateWebsocket(p *Package) error {
+	f := NewFile(p.Name)
+
+	f.PackageComment(fmt.Sprintf("%s contains all the library code for the platform", p.Name))
+	f.PackageComment("This file contains the websocket transport of subscriptions")
+	f.PackageComment(" ** THIS CODE IS MACHINE GENERATED. DO NOT EDIT MANUALLY ** ")
+
+	AddSubscriptionsVars(f)
+	AddSubscriptionMessageStructs(f)
+	AddSubscriptionsHandlerFun(f)
+	AddSubscriptionsConnStruct(f)
+	AddSubscriptionsServeFun(f)
+	AddSubscriptionsSubscribeFun(f)
+	AddSubscriptionsStreamFun(f)
+	AddSubscriptionsRemoveFun(f)
+	AddSubscriptionsSendFun(f)
+	AddSubscriptionsCloseFun(f)
+
+	return f.Save(p.Filename)
+}
+
+// AddSubscriptionsVars declares the subprotocol of the graphql-ws
+// protocol, and how long clients have to initialise a connection
+func AddSubscriptionsVars(f *File) {
+	f.Comment("SubscriptionsProtocol is the websocket subprotocol of the graphql-ws protocol")
+	f.Const().Id("SubscriptionsProtocol").Op("=").Lit("graphql-transport-ws")
+
+	f.Comment("SubscriptionsInitTimeout is the time a client has to initialise a websocket connection")
+	f.Var().Id("SubscriptionsInitTimeout").Op("=").Lit(10).Op("*").Qual("time", "Second")
+
+	f.Var().Id("subscriptionsUpgrader").Op("=").Qual("github.com/gorilla/websocket", "Upgrader").Values(Dict{
+		Id("Subprotocols"): Index().String().Values(Id("SubscriptionsProtocol")),
+	})
+}
+
+// AddSubscriptionMessageStructs generates the structs of the messages
+// of the graphql-ws protocol
+func AddSubscriptionMessageStructs(f *File) {
+	f.Comment("SubscriptionMessage is a message of the graphql-ws protocol")
+	f.Type().Id("SubscriptionMessage").Struct(
+		Id("ID").String().Tag(map[string]string{"json": "id,omitempty"}),
+		Id("Type").String().Tag(map[string]string{"json": "type"}),
+		Id("Payload").Qual("encoding/json", "RawMessage").Tag(map[string]string{"json": "payload,omitempty"}),
+	)
+
+	f.Comment("SubscriptionRequest is the payload of a subscribe message")
+	f.Type().Id("SubscriptionRequest").Struct(
+		Id("Query").String().Tag(map[string]string{"json": "query"}),
+		Id("OperationName").String().Tag(map[string]string{"json": "operationName"}),
+		Id("Variables").Map(String()).Interface().Tag(map[string]string{"json": "variables"}),
+	)
+}
+
+// AddSubscriptionsHandlerFun generates the http middleware that serves
+// websocket requests with the graphql-ws protocol
+func AddSubscriptionsHandlerFun(f *File) {
+	funName := "SubscriptionsHandler"
+
+	f.Comment(fmt.Sprintf("%s wraps the given handler, so that websocket requests are served with the graphql-ws protocol, and all others by the given handler. Subscriptions read from the primary, so that related instances are never behind the changes streamed", funName))
+	f.Func().Id(funName).Params(
+		Id("schema").Op("*").Qual("github.com/graph-gophers/graphql-go", "Schema"),
+		Id("h").Qual("net/http", "Handler"),
+	).Qual("net/http", "Handler").Block(
+		Return(Qual("net/http", "HandlerFunc").Call(
+			Func().Params(
+				Id("w").Qual("net/http", "ResponseWriter"),
+				Id("r").Op("*").Qual("net/http", "Request"),
+			).Block(
+				If(Op("!").Qual("github.com/gorilla/websocket", "IsWebSocketUpgrade").Call(Id("r"))).Block(
+					Id("h").Dot("ServeHTTP").Call(Id("w"), Id("r")),
+					Return(),
+				),
+
+				// the upgrader replies to failed upgrades
+				List(Id("conn"), Err()).Op(":=").Id("subscriptionsUpgrader").Dot("Upgrade").Call(Id("w"), Id("r"), Nil()),
+				If(Err().Op("!=").Nil()).Block(
+					Return(),
+				),
+
+				Id("c").Op(":=").Op("&").Id("SubscriptionsConn").Values(Dict{
+					Id("conn"):          Id("conn"),
+					Id("schema"):        Id("schema"),
+					Id("subscriptions"): Map(String()).Op("*").Id("SubscriptionOperation").Values(),
+				}),
+
+				If(Id("conn").Dot("Subprotocol").Call().Op("!=").Id("SubscriptionsProtocol")).Block(
+					Id("c").Dot("Close").Call(Lit(4406), Lit("Subprotocol not acceptable")),
+					Return(),
+				),
+
+				Id("c").Dot("Serve").Call(Id("WithReadYourWrites").Call(Id("r").Dot("Context").Call(), True())),
+			),
+		)),
+	)
+}
+
+// AddSubscriptionsConnStruct generates the struct of a websocket
+// connection, and of the operations it runs
+func AddSubscriptionsConnStruct(f *File) {
+	f.Comment("SubscriptionsConn is a websocket connection served with the graphql-ws protocol")
+	f.Type().Id("SubscriptionsConn").Struct(
+		Id("conn").Op("*").Qual("github.com/gorilla/websocket", "Conn"),
+		Id("schema").Op("*").Qual("github.com/graph-gophers/graphql-go", "Schema"),
+		Id("writeMu").Qual("sync", "Mutex"),
+		Id("mu").Qual("sync", "Mutex"),
+		Id("subscriptions").Map(String()).Op("*").Id("SubscriptionOperation"),
+	)
+
+	f.Comment("SubscriptionOperation is an operation run by a websocket connection, until it completes or is cancelled")
+	f.Type().Id("SubscriptionOperation").Struct(
+		Id("cancel").Qual("context", "CancelFunc"),
+	)
+}
+
+// AddSubscriptionsServeFun generates the method that reads the
+// messages of a connection, until it is closed
+func AddSubscriptionsServeFun(f *File) {
+	funName := "Serve"
+
+	f.Comment(fmt.Sprintf("%s reads the messages of the connection, until it is closed or breaks the protocol. All of its operations are cancelled then", funName))
+	f.Func().Params(Id("c").Op("*").Id("SubscriptionsConn")).Id(funName).Params(
+		Id("ctx").Qual("context", "Context"),
+	).Block(
+		List(Id("ctx"), Id("cancel")).Op(":=").Qual("context", "WithCancel").Call(Id("ctx")),
+		Defer().Id("cancel").Call(),
+		Defer().Id("c").Dot("conn").Dot("Close").Call(),
+
+		Id("acked").Op(":=").False(),
+		Id("c").Dot("conn").Dot("SetReadDeadline").Call(
+			Qual("time", "Now").Call().Dot("Add").Call(Id("SubscriptionsInitTimeout")),
+		),
+
+		For().Block(
+			List(Id("_"), Id("data"), Err()).Op(":=").Id("c").Dot("conn").Dot("ReadMessage").Call(),
+			If(Err().Op("!=").Nil()).Block(
+				Var().Id("netErr").Qual("net", "Error"),
+				If(Op("!").Id("acked").Op("&&").Qual("errors", "As").Call(Err(), Op("&").Id("netErr")).Op("&&").Id("netErr").Dot("Timeout").Call()).Block(
+					Id("c").Dot("Close").Call(Lit(4408), Lit("Connection initialisation timeout")),
+				),
+				Return(),
+			),
+
+			Id("msg").Op(":=").Id("SubscriptionMessage").Values(),
+			If(Err().Op(":=").Qual("encoding/json", "Unmarshal").Call(Id("data"), Op("&").Id("msg")), Err().Op("!=").Nil()).Block(
+				Id("c").Dot("Close").Call(Lit(4400), Lit("Invalid message")),
+				Return(),
+			),
+
+			Switch(Id("msg").Dot("Type")).Block(
+				Case(Lit("connection_init")).Block(
+					If(Id("acked")).Block(
+						Id("c").Dot("Close").Call(Lit(4429), Lit("Too many initialisation requests")),
+						Return(),
+					),
+					Id("acked").Op("=").True(),
+					Id("c").Dot("conn").Dot("SetReadDeadline").Call(Qual("time", "Time").Values()),
+					Id("c").Dot("Send").Call(Lit(""), Lit("connection_ack"), Nil()),
+				),
+
+				Case(Lit("ping")).Block(
+					Id("c").Dot("Send").Call(Lit(""), Lit("pong"), Nil()),
+				),
+
+				Case(Lit("pong")).Block(),
+
+				Case(Lit("subscribe")).Block(
+					If(Op("!").Id("acked")).Block(
+						Id("c").Dot("Close").Call(Lit(4401), Lit("Unauthorized")),
+						Return(),
+					),
+					If(Op("!").Id("c").Dot("Subscribe").Call(Id("ctx"), Id("msg"))).Block(
+						Return(),
+					),
+				),
+
+				Case(Lit("complete")).Block(
+					If(Id("op").Op(":=").Id("c").Dot("remove").Call(Id("msg").Dot("ID"), Nil()), Id("op").Op("!=").Nil()).Block(
+						Id("op").Dot("cancel").Call(),
+					),
+				),
+
+				Default().Block(
+					Id("c").Dot("Close").Call(Lit(4400), Qual("fmt", "Sprintf").Call(Lit("Invalid message type %s"), Id("msg").Dot("Type"))),
+					Return(),
+				),
+			),
+		),
+	)
+}
+
+// AddSubscriptionsSubscribeFun generates the method that starts the
+// operation of a subscribe message
+func AddSubscriptionsSubscribeFun(f *File) {
+	funName := "Subscribe"
+
+	f.Comment(fmt.Sprintf("%s starts the operation of the given subscribe message, and streams its results. It closes the connection and returns false if the message is invalid, or its id is in use", funName))
+	f.Func().Params(Id("c").Op("*").Id("SubscriptionsConn")).Id(funName).Params(
+		Id("ctx").Qual("context", "Context"),
+		Id("msg").Id("SubscriptionMessage"),
+	).Bool().Block(
+		Id("req").Op(":=").Id("SubscriptionRequest").Values(),
+		If(Id("msg").Dot("ID").Op("==").Lit("").Op("||").Qual("encoding/json", "Unmarshal").Call(Id("msg").Dot("Payload"), Op("&").Id("req")).Op("!=").Nil()).Block(
+			Id("c").Dot("Close").Call(Lit(4400), Lit("Invalid subscribe message")),
+			Return(False()),
+		),
+
+		List(Id("ctx"), Id("cancel")).Op(":=").Qual("context", "WithCancel").Call(Id("ctx")),
+		Id("op").Op(":=").Op("&").Id("SubscriptionOperation").Values(Dict{
+			Id("cancel"): Id("cancel"),
+		}),
+
+		Id("c").Dot("mu").Dot("Lock").Call(),
+		List(Id("_"), Id("exists")).Op(":=").Id("c").Dot("subscriptions").Index(Id("msg").Dot("ID")),
+		If(Op("!").Id("exists")).Block(
+			Id("c").Dot("subscriptions").Index(Id("msg").Dot("ID")).Op("=").Id("op"),
+		),
+		Id("c").Dot("mu").Dot("Unlock").Call(),
+
+		If(Id("exists")).Block(
+			Id("cancel").Call(),
+			Id("c").Dot("Close").Call(Lit(4409), Qual("fmt", "Sprintf").Call(Lit("Subscriber for %s already exists"), Id("msg").Dot("ID"))),
+			Return(False()),
+		),
+
+		List(Id("responses"), Err()).Op(":=").Id("c").Dot("schema").Dot("Subscribe").Call(
+			Id("ctx"),
+			Id("req").Dot("Query"),
+			Id("req").Dot("OperationName"),
+			Id("req").Dot("Variables"),
+		),
+		If(Err().Op("!=").Nil()).Block(
+			Id("c").Dot("remove").Call(Id("msg").Dot("ID"), Id("op")),
+			Id("cancel").Call(),
+			Id("c").Dot("Send").Call(
+				Id("msg").Dot("ID"),
+				Lit("error"),
+				Index().Op("*").Qual("github.com/graph-gophers/graphql-go/errors", "QueryError").Values(
+					Qual("github.com/graph-gophers/graphql-go/errors", "Errorf").Call(Lit("%s"), Err()),
+				),
+			),
+			Return(True()),
+		),
+
+		Go().Id("c").Dot("stream").Call(Id("msg").Dot("ID"), Id("op"), Id("responses")),
+
+		Return(True()),
+	)
+}
+
+// AddSubscriptionsStreamFun generates the method that sends the
+// results of an operation to the client
+func AddSubscriptionsStreamFun(f *File) {
+	funName := "stream"
+
+	f.Comment(fmt.Sprintf("%s sends the given responses of an operation to the client, until there are no more. Operations that fail before running are reported with an error message, and the others are completed, unless the client completed them", funName))
+	f.Func().Params(Id("c").Op("*").Id("SubscriptionsConn")).Id(funName).Params(
+		Id("id").String(),
+		Id("op").Op("*").Id("SubscriptionOperation"),
+		Id("responses").Op("<-").Chan().Interface(),
+	).Block(
+		Defer().Id("op").Dot("cancel").Call(),
+
+		For(Id("response").Op(":=").Range().Id("responses")).Block(
+			Id("res").Op(":=").Id("response").Assert(Op("*").Qual("github.com/graph-gophers/graphql-go", "Response")),
+			If(Id("res").Dot("Data").Op("==").Nil().Op("&&").Len(Id("res").Dot("Errors")).Op(">").Lit(0)).Block(
+				If(Id("c").Dot("remove").Call(Id("id"), Id("op")).Op("!=").Nil()).Block(
+					Id("c").Dot("Send").Call(Id("id"), Lit("error"), Id("res").Dot("Errors")),
+				),
+				Return(),
+			),
+
+			Id("c").Dot("Send").Call(Id("id"), Lit("next"), Id("res")),
+		),
+
+		If(Id("c").Dot("remove").Call(Id("id"), Id("op")).Op("!=").Nil()).Block(
+			Id("c").Dot("Send").Call(Id("id"), Lit("complete"), Nil()),
+		),
+	)
+}
+
+// AddSubscriptionsRemoveFun generates the method that forgets an
+// operation of a connection
+func AddSubscriptionsRemoveFun(f *File) {
+	funName := "remove"
+
+	f.Comment(fmt.Sprintf("%s forgets the operation with the given id, and returns it. If an operation is given, it is only forgotten if it is still the one with that id. Nil is returned if nothing is forgotten", funName))
+	f.Func().Params(Id("c").Op("*").Id("SubscriptionsConn")).Id(funName).Params(
+		Id("id").String(),
+		Id("op").Op("*").Id("SubscriptionOperation"),
+	).Op("*").Id("SubscriptionOperation").Block(
+		Id("c").Dot("mu").Dot("Lock").Call(),
+		Defer().Id("c").Dot("mu").Dot("Unlock").Call(),
+
+		List(Id("stored"), Id("ok")).Op(":=").Id("c").Dot("subscriptions").Index(Id("id")),
+		If(Op("!").Id("ok").Op("||").Parens(Id("op").Op("!=").Nil().Op("&&").Id("op").Op("!=").Id("stored"))).Block(
+			Return(Nil()),
+		),
+
+		Delete(Id("c").Dot("subscriptions"), Id("id")),
+		Return(Id("stored")),
+	)
+}
+
+// AddSubscriptionsSendFun generates the method that sends a message to
+// the client
+func AddSubscriptionsSendFun(f *File) {
+	funName := "Send"
+
+	f.Comment(fmt.Sprintf("%s sends a message of the given type, for the operation with the given id, to the client. The payload is left out if nil", funName))
+	f.Func().Params(Id("c").Op("*").Id("SubscriptionsConn")).Id(funName).Params(
+		Id("id").String(),
+		Id("kind").String(),
+		Id("payload").Interface(),
+	).Error().Block(
+		Id("msg").Op(":=").Id("SubscriptionMessage").Values(Dict{
+			Id("ID"):   Id("id"),
+			Id("Type"): Id("kind"),
+		}),
+
+		If(Id("payload").Op("!=").Nil()).Block(
+			List(Id("data"), Err()).Op(":=").Qual("encoding/json", "Marshal").Call(Id("payload")),
+			If(Err().Op("!=").Nil()).Block(
+				Return(Err()),
+			),
+			Id("msg").Dot("Payload").Op("=").Id("data"),
+		),
+
+		Id("c").Dot("writeMu").Dot("Lock").Call(),
+		Defer().Id("c").Dot("writeMu").Dot("Unlock").Call(),
+
+		Return(Id("c").Dot("conn").Dot("WriteJSON").Call(Id("msg"))),
+	)
+}
+
+// AddSubscriptionsCloseFun generates the method that closes a
+// connection with a code of the graphql-ws protocol
+func AddSubscriptionsCloseFun(f *File) {
+	funName := "Close"
+
+	f.Comment(fmt.Sprintf("%s closes the connection with the given code and reason", funName))
+	f.Func().Params(Id("c").Op("*").Id("SubscriptionsConn")).Id(funName).Params(
+		Id("code").Int(),
+		Id("reason").String(),
+	).Block(
+		Id("c").Dot("writeMu").Dot("Lock").Call(),
+		Defer().Id("c").Dot("writeMu").Dot("Unlock").Call(),
+
+		Id("c").Dot("conn").Dot("WriteControl").Call(
+			Qual("github.com/gorilla/websocket", "CloseMessage"),
+			Qual("github.com/gorilla/websocket", "FormatCloseMessage").Call(Id("code"), Id("reason")),
+			Qual("time", "Now").Call().Dot("Add").Call(Qual("time", "Second")),
+		),
+		Id("c").Dot("conn").Dot("Close").Call(),
+	)
+}